@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	sbomTypes "github.com/CodeClarityCE/plugin-sbom-javascript/src/types/sbom/js"
+	plugin "github.com/CodeClarityCE/plugin-sca-patching/src"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/ecosystem"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/exceptionManager"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/knowledgeStore"
+	outputGenerator "github.com/CodeClarityCE/plugin-sca-patching/src/outputGenerator"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/policy"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/resultCache"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/tracing"
+	patchingTypes "github.com/CodeClarityCE/plugin-sca-patching/src/types"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+	vulnerabilityFinder "github.com/CodeClarityCE/plugin-sca-vuln-finder/src/types"
+	codeclarity "github.com/CodeClarityCE/utility-types/codeclarity_db"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+// Output formats of the patch command
+const (
+	FORMAT_JSON      = "json"
+	FORMAT_SARIF     = "sarif"
+	FORMAT_CYCLONEDX = "cyclonedx"
+	FORMAT_MARKDOWN  = "markdown"
+	FORMAT_HTML      = "html"
+	FORMAT_CSV       = "csv"
+)
+
+// patchCommandOptions are the flags of the patch command
+type patchCommandOptions struct {
+	Sbom        string
+	Vulns       string
+	Knowledge   string
+	Snapshot    string
+	Record      string
+	Policy      string
+	Language    string
+	Format      string
+	Output      string
+	ProjectRoot string
+	Previous    string
+}
+
+// runPatchCommand runs the patching on the outputs of js-sbom and vuln-finder stored on disk,
+// without the dispatcher, and prints the result in the requested format. It returns the exit code.
+func runPatchCommand(args []string, stdout io.Writer, stderr io.Writer) int {
+	options := patchCommandOptions{}
+	flags := flag.NewFlagSet("patch", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&options.Sbom, "sbom", "sbom.json", "output of the SBOM plugin")
+	flags.StringVar(&options.Vulns, "vulns", "vulns.json", "output of the vuln-finder plugin")
+	flags.StringVar(&options.Knowledge, "knowledge", os.Getenv("KNOWLEDGE_DSN"), "DSN of the knowledge database, defaults to $KNOWLEDGE_DSN")
+	flags.StringVar(&options.Snapshot, "snapshot", "", "knowledge snapshot to run offline instead of the knowledge database")
+	flags.StringVar(&options.Record, "record-snapshot", "", "file to save the knowledge used by the analysis to, for later offline runs")
+	flags.StringVar(&options.Policy, "policy", "", `upgrade policy options as a JSON object, for example {"max_major_jump": 0}`)
+	flags.StringVar(&options.Language, "language", patchingTypes.JS, "language of the project, JS or PYTHON")
+	flags.StringVar(&options.Format, "format", FORMAT_JSON, "output format: json, sarif, cyclonedx, markdown, html or csv")
+	flags.StringVar(&options.Output, "output", "", "file to write the result to, defaults to stdout (a directory for csv)")
+	flags.StringVar(&options.ProjectRoot, "project-root", ".", "root of the project, used to read its remediation rules and to locate manifests in SARIF results")
+	flags.StringVar(&options.Previous, "previous", "", "JSON output of a previous run, whose unchanged entries are carried forward")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	// Spans are exported as selected by OTEL_TRACES_EXPORTER, the console exporter writing to stderr
+	shutdownTracing, err := tracing.Setup(context.Background(), stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "patch: %s\n", err)
+		return 1
+	}
+	defer shutdownTracing(context.Background())
+
+	if err := patchCommand(options, stdout); err != nil {
+		fmt.Fprintf(stderr, "patch: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+func patchCommand(options patchCommandOptions, stdout io.Writer) error {
+	if options.Knowledge == "" && options.Snapshot == "" {
+		return fmt.Errorf("a knowledge database is required, use -knowledge, $KNOWLEDGE_DSN or -snapshot")
+	}
+	if options.Format == FORMAT_CSV && options.Output == "" {
+		return fmt.Errorf("the csv format writes two files, use -output to choose their directory")
+	}
+
+	ctx, span := tracing.Start(context.Background(), "patch")
+	defer span.End()
+
+	sbom := sbomTypes.Output{}
+	if err := readJSONFile(options.Sbom, &sbom); err != nil {
+		return err
+	}
+	vulns := vulnerabilityFinder.Output{}
+	if err := readJSONFile(options.Vulns, &vulns); err != nil {
+		return err
+	}
+
+	policyOptions := map[string]any{}
+	if options.Policy != "" {
+		if err := json.Unmarshal([]byte(options.Policy), &policyOptions); err != nil {
+			return fmt.Errorf("invalid policy: %w", err)
+		}
+	}
+	upgradePolicy, err := policy.Resolve(nil, policyOptions)
+	if err != nil {
+		return err
+	}
+	projectRules, err := policy.LoadProjectRules(options.ProjectRoot)
+	if err != nil {
+		return err
+	}
+	upgradePolicy.Rules = policy.MergeRules(projectRules, upgradePolicy.Rules)
+
+	var store knowledgeStore.KnowledgeStore
+	if options.Snapshot != "" {
+		snapshot, err := knowledgeStore.LoadSnapshot(options.Snapshot)
+		if err != nil {
+			return err
+		}
+		store = snapshot
+	} else {
+		knowledge := bun.NewDB(sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN(options.Knowledge))), pgdialect.New())
+		defer knowledge.Close()
+		store = knowledgeStore.Postgres{DB: knowledge}
+	}
+	var recorder *knowledgeStore.Recorder
+	if options.Record != "" {
+		recorder = knowledgeStore.NewRecorder(store)
+		store = recorder
+	}
+
+	var previous *patching.Output
+	if options.Previous != "" {
+		stored := map[string]any{}
+		if err := readJSONFile(options.Previous, &stored); err != nil {
+			return err
+		}
+		previous = &patching.Output{}
+		if err := resultCache.DecodeOutput(stored, previous); err != nil {
+			return fmt.Errorf("invalid %s: %w", options.Previous, err)
+		}
+	}
+
+	output := plugin.Start(ctx, store, sbom, vulns, options.Language, upgradePolicy, previous, exceptionManager.NewCollector(), time.Now())
+
+	if options.Format == FORMAT_CSV {
+		if err := outputGenerator.WriteCSV(output, options.Output); err != nil {
+			return err
+		}
+	} else if err := writeOutput(output, options, store, stdout); err != nil {
+		return err
+	}
+
+	if recorder != nil {
+		if err := recorder.Save(options.Record); err != nil {
+			return err
+		}
+	}
+	// The output of a failed analysis is still written, it holds its errors
+	if output.AnalysisInfo.Status == codeclarity.FAILURE {
+		descriptions := []string{}
+		for _, publicError := range output.AnalysisInfo.PublicErrors {
+			descriptions = append(descriptions, publicError.Description)
+		}
+		return fmt.Errorf("the analysis failed: %s", strings.Join(descriptions, "; "))
+	}
+	return nil
+}
+
+func writeOutput(output patching.Output, options patchCommandOptions, store knowledgeStore.KnowledgeStore, stdout io.Writer) error {
+	content, err := formatOutput(output, options, store)
+	if err != nil {
+		return err
+	}
+	if options.Output == "" {
+		_, err = stdout.Write(content)
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(options.Output), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(options.Output, content, 0644)
+}
+
+func formatOutput(output patching.Output, options patchCommandOptions, store knowledgeStore.KnowledgeStore) ([]byte, error) {
+	switch options.Format {
+	case FORMAT_JSON:
+		return marshalIndent(output)
+	case FORMAT_SARIF:
+		entries, err := store.NVDEntries(outputVulnerabilityIds(output))
+		if err != nil {
+			return nil, err
+		}
+		return marshalIndent(outputGenerator.SarifOutput(output, entries, options.ProjectRoot))
+	case FORMAT_CYCLONEDX:
+		packageEcosystem, err := ecosystem.ForLanguage(options.Language, store)
+		if err != nil {
+			return nil, err
+		}
+		vulnerabilityIds := outputVulnerabilityIds(output)
+		entries, err := store.NVDEntries(vulnerabilityIds)
+		if err != nil {
+			return nil, err
+		}
+		kev, err := store.KEV(vulnerabilityIds)
+		if err != nil {
+			return nil, err
+		}
+		return marshalIndent(outputGenerator.CycloneDXOutput(output, packageEcosystem, entries, kev))
+	case FORMAT_MARKDOWN:
+		return outputGenerator.MarkdownReport(output)
+	case FORMAT_HTML:
+		return outputGenerator.HTMLReport(output)
+	}
+	return nil, fmt.Errorf("unknown format %s", options.Format)
+}
+
+func outputVulnerabilityIds(output patching.Output) []string {
+	vulnerabilityIds := []string{}
+	for _, workspace := range output.WorkSpaces {
+		for vulnerabilityId := range workspace.Vulnerabilities {
+			vulnerabilityIds = append(vulnerabilityIds, vulnerabilityId)
+		}
+		for _, suppressed := range workspace.Suppressed {
+			vulnerabilityIds = append(vulnerabilityIds, suppressed.VulnerabilityId)
+		}
+	}
+	return vulnerabilityIds
+}
+
+func readJSONFile(path string, value any) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, value); err != nil {
+		return fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return nil
+}
+
+func marshalIndent(value any) ([]byte, error) {
+	content, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(content, '\n'), nil
+}
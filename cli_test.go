@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+	codeclarity "github.com/CodeClarityCE/utility-types/codeclarity_db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPatchCommand runs the patch command offline on the knowledge snapshot of a golden fixture
+func TestPatchCommand(t *testing.T) {
+	folder := filepath.Join("tests", "fixtures", "npmv2")
+	args := []string{
+		"-sbom", filepath.Join(folder, "sbom.json"),
+		"-vulns", filepath.Join(folder, "vulns.json"),
+		"-snapshot", filepath.Join(folder, "knowledge.json"),
+		"-project-root", t.TempDir(),
+	}
+
+	stdout, stderr := bytes.Buffer{}, bytes.Buffer{}
+	require.Equal(t, 0, runPatchCommand(args, &stdout, &stderr), stderr.String())
+	output := patching.Output{}
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &output))
+	assert.Equal(t, codeclarity.SUCCESS, output.AnalysisInfo.Status)
+	patch := output.WorkSpaces["."].Patches["mkdirp@0.5.5"]
+	assert.Equal(t, patching.FULL, patch.IsPatchable)
+	assert.Equal(t, "0.5.6", patch.UpdateVersion())
+
+	// A failed analysis still prints its output, with a non-zero exit code
+	failedSbom := filepath.Join(t.TempDir(), "sbom.json")
+	require.NoError(t, os.WriteFile(failedSbom, []byte(`{"analysis_info": {"status": "failure"}}`), 0644))
+	args[1] = failedSbom
+	stdout.Reset()
+	stderr.Reset()
+	assert.Equal(t, 1, runPatchCommand(args, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "the analysis failed")
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &output))
+	assert.Equal(t, codeclarity.FAILURE, output.AnalysisInfo.Status)
+}
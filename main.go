@@ -2,23 +2,30 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
+	"os"
 	"time"
 
 	sbomTypes "github.com/CodeClarityCE/plugin-sbom-javascript/src/types/sbom/js"
 	plugin "github.com/CodeClarityCE/plugin-sca-patching/src"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/exceptionManager"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/knowledgeStore"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/metrics"
+	outputGenerator "github.com/CodeClarityCE/plugin-sca-patching/src/outputGenerator"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/policy"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/resultCache"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/stageResolver"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/tracing"
 	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
 	vulnerabilityFinder "github.com/CodeClarityCE/plugin-sca-vuln-finder/src/types"
 	"github.com/CodeClarityCE/utility-boilerplates"
-	dbhelper "github.com/CodeClarityCE/utility-dbhelper/helper"
 	types_amqp "github.com/CodeClarityCE/utility-types/amqp"
 	codeclarity "github.com/CodeClarityCE/utility-types/codeclarity_db"
 	"github.com/CodeClarityCE/utility-types/exceptions"
-	exceptionManager "github.com/CodeClarityCE/utility-types/exceptions"
 	plugin_db "github.com/CodeClarityCE/utility-types/plugin_db"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // JSPatchingAnalysisHandler implements the AnalysisHandler interface
@@ -35,13 +42,34 @@ func (h *JSPatchingAnalysisHandler) StartAnalysis(
 }
 
 // main is the entry point of the program.
+// "patch" runs the patching on files instead of listening to the dispatcher.
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "patch" {
+		os.Exit(runPatchCommand(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	pluginBase, err := boilerplates.CreatePluginBase()
 	if err != nil {
 		log.Fatalf("Failed to initialize plugin base: %v", err)
 	}
 	defer pluginBase.Close()
 
+	// Expose the metrics of the analyses when METRICS_ADDRESS is set, for example to ":9090"
+	if address := os.Getenv("METRICS_ADDRESS"); address != "" {
+		go func() {
+			if err := metrics.Serve(address); err != nil {
+				log.Printf("Failed to serve metrics: %v", err)
+			}
+		}()
+	}
+
+	// Export the spans of the analyses as selected by OTEL_TRACES_EXPORTER
+	shutdownTracing, err := tracing.Setup(context.Background(), os.Stderr)
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
 	// Start the plugin with our analysis handler
 	handler := &JSPatchingAnalysisHandler{}
 	err = pluginBase.Listen(handler)
@@ -58,71 +86,151 @@ func main() {
 // - analysis_document: Analysis document containing information about the analysis.
 // It returns a map[string]any containing the result of the analysis, the analysis status, and an error if any.
 func startAnalysis(databases *boilerplates.PluginDatabases, dispatcherMessage types_amqp.DispatcherPluginMessage, config plugin_db.Plugin, analysis_document codeclarity.Analysis) (map[string]any, codeclarity.AnalysisStatus, error) {
-	// Prepare the arguments for the plugin
-
-	// Get sbomKey from previous stage
-	sbomKey := uuid.UUID{}
-	vulnKey := uuid.UUID{}
-	for _, stage := range analysis_document.Steps {
-		for _, step := range stage {
-			if step.Name == "js-sbom" {
-				sbomKeyUUID, err := uuid.Parse(step.Result["sbomKey"].(string))
-				if err != nil {
-					panic(err)
-				}
-				sbomKey = sbomKeyUUID
-				break
-			} else if step.Name == "vuln-finder" {
-				vulnKeyUUID, err := uuid.Parse(step.Result["vulnKey"].(string))
-				if err != nil {
-					panic(err)
-				}
-				vulnKey = vulnKeyUUID
-				break
-			}
-		}
+	start := time.Now()
+	defer metrics.ObservePhase(metrics.PHASE_ANALYSIS, start)
+	ctx, span := tracing.Start(context.Background(), "startAnalysis", attribute.String("analysis", analysis_document.Id.String()))
+	defer span.End()
+	// Errors are collected per analysis, the plugin handling many of them
+	errors := exceptionManager.NewCollector()
+
+	// Find the results of the SBOM producer and of the vulnerability finders
+	previousStages, warnings, err := stageResolver.Resolve(analysis_document.Steps)
+	for _, warning := range warnings {
+		errors.AddWarning(exceptions.GENERIC_ERROR, "", warning)
+	}
+	if err != nil {
+		errors.AddError(
+			exceptions.FAILED_TO_READ_PREVIOUS_STAGE_OUTPUT,
+			"The outputs of the previous stages could not be found",
+			fmt.Sprintf("Error when resolving the previous stages: %s", err),
+		)
+		return storeOutput(databases, dispatcherMessage, config, outputGenerator.FailureOutput(sbomTypes.AnalysisInfo{}, errors, start))
 	}
 
-	var patchingOutput patching.Output
-
-	start := time.Now()
+	// Resolve the upgrade policy from the plugin defaults and the options of the analysis
+	analysisOptions, _ := analysis_document.Config[config.Name].(map[string]any)
+	upgradePolicy, err := policy.Resolve(config.Config, analysisOptions)
+	if err != nil {
+		errors.AddError(exceptions.GENERIC_ERROR, "", fmt.Sprintf("Error in the upgrade policy: %s", err))
+		return storeOutput(databases, dispatcherMessage, config, outputGenerator.FailureOutput(sbomTypes.AnalysisInfo{}, errors, start))
+	}
 
 	// Retrieve the sbom from the previous stage
-	sbom, err := getSbom(sbomKey, databases)
+	sbomLoadStart := time.Now()
+	sbom := sbomTypes.Output{}
+	if err := readResult(previousStages.SbomKey, databases, &sbom); err != nil {
+		errors.AddError(exceptions.FAILED_TO_READ_PREVIOUS_STAGE_OUTPUT, "", fmt.Sprintf("Error when reading the sbom output of %s: %s", previousStages.SbomProducer, err))
+		return storeOutput(databases, dispatcherMessage, config, outputGenerator.FailureOutput(sbomTypes.AnalysisInfo{}, errors, start))
+	}
+
+	// Rules given with the analysis take precedence over the ones of the project
+	projectRules, err := policy.LoadProjectRules(sbom.AnalysisInfo.WorkingDirectory)
 	if err != nil {
-		exceptionManager.AddError(
-			"", exceptions.GENERIC_ERROR,
-			fmt.Sprintf("Error when reading sbom output: %s", err), exceptions.FAILED_TO_READ_PREVIOUS_STAGE_OUTPUT,
-		)
-		return nil, codeclarity.FAILURE, err
+		errors.AddError(exceptions.GENERIC_ERROR, "", fmt.Sprintf("Error in the remediation rules of the project: %s", err))
+		return storeOutput(databases, dispatcherMessage, config, outputGenerator.FailureOutput(sbom.AnalysisInfo, errors, start))
+	}
+	upgradePolicy.Rules = policy.MergeRules(projectRules, upgradePolicy.Rules)
+
+	// Retrieve the vulnerabilities found by each vulnerability finder
+	vulnsOutputs := []vulnerabilityFinder.Output{}
+	for _, vulnKey := range previousStages.VulnKeys {
+		vulns := vulnerabilityFinder.Output{}
+		if err := readResult(vulnKey, databases, &vulns); err != nil {
+			errors.AddError(exceptions.FAILED_TO_READ_PREVIOUS_STAGE_OUTPUT, "", fmt.Sprintf("Error when reading vulns output %s: %s", vulnKey, err))
+			return storeOutput(databases, dispatcherMessage, config, outputGenerator.FailureOutput(sbom.AnalysisInfo, errors, start))
+		}
+		vulnsOutputs = append(vulnsOutputs, vulns)
 	}
+	vulns := stageResolver.MergeVulns(vulnsOutputs)
+	metrics.ObservePhase(metrics.PHASE_SBOM_LOAD, sbomLoadStart)
+
+	store := knowledgeStore.NewInstrumented(knowledgeStore.Postgres{DB: databases.Knowledge})
 
-	// Retrieve the vulnerabilities from the previous stage
-	vulns, err := getVulns(vulnKey, databases)
+	// Analyses with the same inputs reuse the output of the first one
+	cacheKey, err := analysisCacheKey(store, config, previousStages.Language, sbom, vulns, upgradePolicy, start)
 	if err != nil {
-		exceptionManager.AddError(
-			"", exceptions.GENERIC_ERROR,
-			fmt.Sprintf("Error when reading vulns output: %s", err), exceptions.FAILED_TO_READ_PREVIOUS_STAGE_OUTPUT,
-		)
-		return nil, codeclarity.FAILURE, err
+		errors.AddWarning(exceptions.GENERIC_ERROR, "", fmt.Sprintf("Error when computing the cache key, the result is not cached: %s", err))
+	} else if cached, cachedResult, hit, err := resultCache.Lookup(databases.Codeclarity, config.Name, cacheKey); err != nil {
+		metrics.CountCacheLookup(metrics.CACHE_ERROR)
+		errors.AddWarning(exceptions.GENERIC_ERROR, "", fmt.Sprintf("Error when looking up the result cache: %s", err))
+	} else if hit {
+		metrics.CountCacheLookup(metrics.CACHE_HIT)
+		return storeOutput(databases, dispatcherMessage, config, outputGenerator.CachedOutput(cached, cachedResult.String(), errors, start))
+	} else {
+		metrics.CountCacheLookup(metrics.CACHE_MISS)
 	}
 
-	patchingOutput = plugin.Start(databases.Knowledge, sbom, vulns, dbhelper.Config.Collection.JS, start)
+	// Entries whose inputs did not change since the last analysis of the project are carried forward
+	previous, previousResult := previousOutput(databases, config, analysis_document, errors)
 
+	patchingOutput := plugin.Start(ctx, store, sbom, vulns, previousStages.Language, upgradePolicy, previous, errors, start)
+	patchingOutput.AnalysisInfo.PluginVersion = config.Version
+	if previous != nil {
+		patchingOutput.AnalysisInfo.PreviousResult = previousResult
+	}
+	// Only complete outputs are reused, not the ones degraded by errors
+	if cacheKey != "" && patchingOutput.AnalysisInfo.Status == codeclarity.SUCCESS && len(patchingOutput.AnalysisInfo.Errors) == 0 {
+		patchingOutput.AnalysisInfo.CacheKey = cacheKey
+	}
+	return storeOutput(databases, dispatcherMessage, config, patchingOutput)
+}
+
+// previousOutput returns the last result of the project when it was produced by the same version of the plugin
+func previousOutput(databases *boilerplates.PluginDatabases, config plugin_db.Plugin, analysis_document codeclarity.Analysis, errors *exceptionManager.Collector) (*patching.Output, string) {
+	if analysis_document.ProjectId == nil {
+		return nil, ""
+	}
+	previous, previousResult, found, err := resultCache.LatestForProject(databases.Codeclarity, config.Name, *analysis_document.ProjectId, analysis_document.Id)
+	if err != nil {
+		errors.AddWarning(exceptions.GENERIC_ERROR, "", fmt.Sprintf("Error when reading the previous result of the project, all entries are computed again: %s", err))
+		return nil, ""
+	}
+	if !found || previous.SchemaVersion != patching.SCHEMA_VERSION || previous.AnalysisInfo.PluginVersion != config.Version {
+		return nil, ""
+	}
+	return &previous, previousResult.String()
+}
+
+// analysisCacheKey addresses the inputs of an analysis, see resultCache.Key
+func analysisCacheKey(store knowledgeStore.KnowledgeStore, config plugin_db.Plugin, languageId string, sbom sbomTypes.Output, vulns vulnerabilityFinder.Output, upgradePolicy patching.UpgradePolicy, start time.Time) (string, error) {
+	dataVersion, err := store.DataVersion()
+	if err != nil {
+		return "", err
+	}
+	exploitabilityVersion, err := plugin.ExploitabilityFilesVersion()
+	if err != nil {
+		return "", err
+	}
+	_, expiredSuppressions := policy.ActiveSuppressions(upgradePolicy.Rules.Suppressions, start)
+	return resultCache.Key(resultCache.Inputs{
+		PluginVersion:         config.Version,
+		Language:              languageId,
+		Sbom:                  sbom,
+		Vulns:                 vulns,
+		Policy:                upgradePolicy,
+		ExpiredSuppressions:   expiredSuppressions,
+		DataVersion:           dataVersion,
+		ExploitabilityVersion: exploitabilityVersion,
+	})
+}
+
+// storeOutput saves the output of an analysis, successful or not, and returns the result of the step
+func storeOutput(databases *boilerplates.PluginDatabases, dispatcherMessage types_amqp.DispatcherPluginMessage, config plugin_db.Plugin, patchingOutput patching.Output) (map[string]any, codeclarity.AnalysisStatus, error) {
+	metrics.CountOutput(patchingOutput)
 	patch_result := codeclarity.Result{
 		Result:     patching.ConvertOutputToMap(patchingOutput),
 		AnalysisId: dispatcherMessage.AnalysisId,
 		Plugin:     config.Name,
 		CreatedOn:  time.Now(),
 	}
-	_, err = databases.Codeclarity.NewInsert().Model(&patch_result).Exec(context.Background())
+	_, err := databases.Codeclarity.NewInsert().Model(&patch_result).Exec(context.Background())
 	if err != nil {
-		panic(err)
+		return nil, codeclarity.FAILURE, fmt.Errorf("failed to store the patching output: %w", err)
 	}
 
 	// Prepare the result to store in step
-	// In this case we only store the sbomKey
-	// The other plugins will use this key to get the sbom
+	// In this case we only store the patchKey
+	// The other plugins will use this key to get the patching output
 	result := make(map[string]any)
 	result["patchKey"] = patch_result.Id
 
@@ -130,30 +238,14 @@ func startAnalysis(databases *boilerplates.PluginDatabases, dispatcherMessage ty
 	return result, patchingOutput.AnalysisInfo.Status, nil
 }
 
-func getVulns(vulnsKey uuid.UUID, databases *boilerplates.PluginDatabases) (vulnerabilityFinder.Output, error) {
-	res := codeclarity.Result{
-		Id: vulnsKey,
-	}
-	err := databases.Codeclarity.NewSelect().Model(&res).Where("id = ?", vulnsKey).Scan(context.Background())
-	if err != nil {
-		panic(err)
-	}
-	vulns := vulnerabilityFinder.Output{}
-	err = json.Unmarshal(res.Result.([]byte), &vulns)
-
-	return vulns, err
-}
-
-func getSbom(sbomKey uuid.UUID, databases *boilerplates.PluginDatabases) (sbomTypes.Output, error) {
+// readResult reads the result of a previous stage into output
+func readResult(key uuid.UUID, databases *boilerplates.PluginDatabases, output any) error {
 	res := codeclarity.Result{
-		Id: sbomKey,
+		Id: key,
 	}
-	err := databases.Codeclarity.NewSelect().Model(&res).Where("id = ?", sbomKey).Scan(context.Background())
+	err := databases.Codeclarity.NewSelect().Model(&res).Where("id = ?", key).Scan(context.Background())
 	if err != nil {
-		panic(err)
+		return err
 	}
-	sbom := sbomTypes.Output{}
-	err = json.Unmarshal(res.Result.([]byte), &sbom)
-
-	return sbom, err
+	return resultCache.Decode(res.Result, output)
 }
@@ -0,0 +1,8 @@
+-- Index of the result cache of the patching plugin, searched by resultCache.Lookup
+-- on the plugin and the cache key of the analysis info. Only the results with a cache key are indexed.
+-- The result table belongs to the codeclarity database, this migration is applied with its migrations:
+-- the plugin never changes the schema. CONCURRENTLY keeps the inserts of the other plugins going
+-- while the index is built, so the statement cannot run inside a transaction.
+CREATE INDEX CONCURRENTLY IF NOT EXISTS result_cache_key_idx
+    ON result (plugin, (result->'analysis_info'->>'cache_key'))
+    WHERE result->'analysis_info'->>'cache_key' IS NOT NULL;
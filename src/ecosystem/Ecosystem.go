@@ -0,0 +1,107 @@
+package ecosystem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/knowledgeStore"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types"
+	"github.com/CodeClarityCE/utility-node-semver/versions"
+)
+
+// ErrInvalidConstraint is returned when a constraint cannot be understood by the ecosystem.
+var ErrInvalidConstraint = errors.New("invalid constraint")
+
+// ErrNoSatisfyingVersion is returned when no known version satisfies a constraint.
+var ErrNoSatisfyingVersion = errors.New("no satisfying version")
+
+// Ecosystem abstracts everything the patcher needs to know about a package ecosystem:
+// how versions are ordered, how constraints are matched and rewritten,
+// how dependency keys are built and where versions and vulnerabilities are looked up.
+type Ecosystem interface {
+	// Name returns the name of the ecosystem, e.g. "npm" or "PyPI".
+	Name() string
+
+	// Key joins a dependency name and version the way the SBOM does ("name@version").
+	Key(name string, version string) string
+	// SplitKey splits a dependency key into its name and version.
+	SplitKey(key string) (string, string)
+	// Purl returns the package URL identifying a release, e.g. "pkg:npm/%40babel/core@7.24.0".
+	Purl(name string, version string) string
+
+	// Compare returns -1, 0 or 1 depending on whether version a sorts before, equal to or after version b.
+	Compare(a string, b string) (int, error)
+	// SortVersions sorts versions in ascending order.
+	SortVersions(versions []string) ([]string, error)
+	// IsPrerelease reports whether a version is a pre-release.
+	IsPrerelease(version string) bool
+	// ParseVersion converts a version into the semver structure used in the patching output.
+	ParseVersion(version string) (versions.Semver, error)
+
+	// Satisfies reports whether a version satisfies a constraint.
+	Satisfies(version string, constraint string) (bool, error)
+	// MaxSatisfying returns the highest version satisfying a constraint.
+	MaxSatisfying(versions []string, constraint string) (string, error)
+	// UpdateConstraint rewrites a manifest constraint so that it admits version.
+	// It also reports whether the old constraint already admitted it,
+	// in which case refreshing the lock file is enough.
+	UpdateConstraint(constraint string, version string) (string, bool, error)
+
+	// Versions returns every known version of a package, in ascending order.
+	Versions(name string) ([]string, error)
+	// Dependencies returns the production and development dependencies of a release,
+	// as maps of dependency name to constraint.
+	Dependencies(name string, version string) (map[string]string, map[string]string, error)
+	// Vulnerabilities returns the identifiers of the vulnerabilities affecting a release.
+	Vulnerabilities(name string, version string) ([]string, error)
+}
+
+// ForLanguage returns the ecosystem matching the languageId given to the plugin.
+func ForLanguage(languageId string, store knowledgeStore.KnowledgeStore) (Ecosystem, error) {
+	switch strings.ToUpper(languageId) {
+	case types.JS, "JAVASCRIPT", "NPM":
+		return Npm{Store: store}, nil
+	case types.PYTHON, "PYPI":
+		return Python{Store: store}, nil
+	}
+	return nil, fmt.Errorf("unsupported language: %s", languageId)
+}
+
+// Traced returns the driver with its knowledge queries traced under the span of ctx
+// when its store is traced, the driver unchanged otherwise.
+func Traced(driver Ecosystem, ctx context.Context) Ecosystem {
+	switch driver := driver.(type) {
+	case Npm:
+		if traced, ok := driver.Store.(knowledgeStore.Traced); ok {
+			driver.Store = knowledgeStore.NewTraced(ctx, traced.Store)
+		}
+		return driver
+	case Python:
+		if traced, ok := driver.Store.(knowledgeStore.Traced); ok {
+			driver.Store = knowledgeStore.NewTraced(ctx, traced.Store)
+		}
+		return driver
+	}
+	return driver
+}
+
+// purl builds a package URL, percent-encoding the scope, name and version as the purl specification requires.
+func purl(packageType string, name string, version string) string {
+	segments := strings.Split(name, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return "pkg:" + packageType + "/" + strings.Join(segments, "/") + "@" + url.PathEscape(version)
+}
+
+// splitOnLastAt splits "name@version" keys, keeping the "@" of scoped npm packages in the name.
+func splitOnLastAt(key string) (string, string) {
+	index := strings.LastIndex(key, "@")
+	if index <= 0 {
+		return key, ""
+	}
+	return key[:index], key[index+1:]
+}
@@ -0,0 +1,34 @@
+package ecosystem
+
+import (
+	"context"
+	"testing"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/knowledgeStore"
+)
+
+type contextKey struct{}
+
+func TestTraced(t *testing.T) {
+	store := knowledgeStore.NewSnapshot()
+	analysisCtx := context.WithValue(context.Background(), contextKey{}, "analysis")
+	candidateCtx := context.WithValue(context.Background(), contextKey{}, "candidate")
+
+	driver := Traced(Npm{Store: knowledgeStore.NewTraced(analysisCtx, store)}, candidateCtx)
+	traced, ok := driver.(Npm).Store.(knowledgeStore.Traced)
+	if !ok {
+		t.Fatalf("Expected the store to stay traced, got %T", driver.(Npm).Store)
+	}
+	if traced.Context.Value(contextKey{}) != "candidate" {
+		t.Errorf("Expected the queries to be traced under the candidate context")
+	}
+	if traced.Store != knowledgeStore.KnowledgeStore(store) {
+		t.Errorf("Expected the traced store to wrap the original store once")
+	}
+
+	// Stores that are not traced are left alone
+	driver = Traced(Python{Store: store}, candidateCtx)
+	if driver.(Python).Store != knowledgeStore.KnowledgeStore(store) {
+		t.Errorf("Expected an untraced store to be left unchanged")
+	}
+}
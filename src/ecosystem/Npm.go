@@ -0,0 +1,140 @@
+package ecosystem
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/knowledgeStore"
+	semver "github.com/CodeClarityCE/utility-node-semver"
+	"github.com/CodeClarityCE/utility-node-semver/constraints"
+	"github.com/CodeClarityCE/utility-node-semver/versions"
+)
+
+// Npm is the npm ecosystem, backed by the knowledge store and NVD.
+type Npm struct {
+	Store knowledgeStore.KnowledgeStore
+}
+
+// npmConstraintPattern captures the range operator and version of simple npm constraints like "^1.2.3".
+var npmConstraintPattern = regexp.MustCompile(`^\s*(\^|~|>=|>|=)?\s*v?(\d+(?:\.\d+){0,2})(?:[-+][0-9A-Za-z.-]*)?\s*$`)
+
+func (npm Npm) Name() string {
+	return knowledgeStore.NPM
+}
+
+func (npm Npm) Key(name string, version string) string {
+	return name + "@" + version
+}
+
+func (npm Npm) SplitKey(key string) (string, string) {
+	return splitOnLastAt(key)
+}
+
+func (npm Npm) Purl(name string, version string) string {
+	// url.PathEscape leaves "@" untouched, the purl of a scoped package starts with "%40"
+	return strings.Replace(purl("npm", name, version), "pkg:npm/@", "pkg:npm/%40", 1)
+}
+
+func (npm Npm) Compare(a string, b string) (int, error) {
+	if a == b {
+		return 0, nil
+	}
+	sorted, err := semver.SortStrings(1, []string{a, b})
+	if err != nil {
+		return 0, err
+	}
+	if sorted[0] == a {
+		return -1, nil
+	}
+	return 1, nil
+}
+
+func (npm Npm) SortVersions(versions []string) ([]string, error) {
+	return semver.SortStrings(1, versions)
+}
+
+func (npm Npm) IsPrerelease(version string) bool {
+	return strings.Contains(version, "-")
+}
+
+func (npm Npm) ParseVersion(version string) (versions.Semver, error) {
+	return semver.ParseSemver(version)
+}
+
+func (npm Npm) Satisfies(version string, constraint string) (bool, error) {
+	parsed, err := semver.ParseConstraint(constraint)
+	if err != nil {
+		if err == constraints.ErrInvalidVersion {
+			return false, ErrInvalidConstraint
+		}
+		return false, err
+	}
+	satisfying_version, err := semver.MaxSatisfyingStrings([]string{version}, parsed, false)
+	if err != nil {
+		return false, nil
+	}
+	return satisfying_version.String() == version, nil
+}
+
+// MaxSatisfying returns ErrNoSatisfyingVersion for local ("file:") dependencies, which have no registry versions.
+func (npm Npm) MaxSatisfying(versions []string, constraint string) (string, error) {
+	if strings.Contains(constraint, "file:") {
+		return "", fmt.Errorf("%w: %s is file managed", ErrNoSatisfyingVersion, constraint)
+	}
+	parsed, err := semver.ParseConstraint(constraint)
+	if err != nil {
+		if err == constraints.ErrInvalidVersion {
+			return "", ErrInvalidConstraint
+		}
+		return "", err
+	}
+	satisfying_version, err := semver.MaxSatisfyingStrings(versions, parsed, false)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrNoSatisfyingVersion, err)
+	}
+	return satisfying_version.String(), nil
+}
+
+// UpdateConstraint keeps the range operator of simple constraints ("^1.2.3" becomes "^1.4.0").
+// Wildcards and tags are left alone since they already admit any version.
+func (npm Npm) UpdateConstraint(constraint string, version string) (string, bool, error) {
+	trimmed := strings.TrimSpace(constraint)
+	if trimmed == "" || trimmed == "*" || trimmed == "latest" || trimmed == "x" {
+		return constraint, true, nil
+	}
+
+	reapply, err := npm.Satisfies(version, trimmed)
+	if err != nil {
+		return "", false, err
+	}
+	match := npmConstraintPattern.FindStringSubmatch(trimmed)
+	if match == nil {
+		// Complex ranges ("1.x || >=2.5", hyphen ranges) are replaced by a caret on the new version
+		return "^" + version, reapply, nil
+	}
+	operator := match[1]
+	if operator == ">" {
+		operator = ">="
+	}
+	return operator + version, reapply, nil
+}
+
+func (npm Npm) Versions(name string) ([]string, error) {
+	versions, err := npm.Store.Versions(npm.Name(), name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sort the retrieved versions using the semver package.
+	return npm.SortVersions(versions)
+}
+
+func (npm Npm) Dependencies(name string, version string) (map[string]string, map[string]string, error) {
+	return npm.Store.Dependencies(npm.Name(), name, version)
+}
+
+// Vulnerabilities returns the identifiers of the NVD records affecting an npm release.
+func (npm Npm) Vulnerabilities(name string, version string) ([]string, error) {
+	return npm.Store.Vulnerabilities(npm.Name(), name, version)
+}
@@ -0,0 +1,21 @@
+package ecosystem
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNpmMaxSatisfying(t *testing.T) {
+	npm := Npm{}
+	version, err := npm.MaxSatisfying([]string{"1.0.0", "1.2.0", "2.0.0"}, "^1.0.0")
+	if err != nil || version != "1.2.0" {
+		t.Errorf("Expected 1.2.0, got %q (%v)", version, err)
+	}
+
+	// Constraints nothing satisfies are reported like in every other ecosystem
+	for _, constraint := range []string{"^3.0.0", "file:../local"} {
+		if _, err := npm.MaxSatisfying([]string{"1.0.0", "2.0.0"}, constraint); !errors.Is(err, ErrNoSatisfyingVersion) {
+			t.Errorf("Expected ErrNoSatisfyingVersion for %s, got %v", constraint, err)
+		}
+	}
+}
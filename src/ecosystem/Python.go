@@ -0,0 +1,188 @@
+package ecosystem
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/knowledgeStore"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/pep440"
+	"github.com/CodeClarityCE/utility-node-semver/versions"
+)
+
+// Python is the PyPI ecosystem, backed by the knowledge store and OSV.
+// Versions follow PEP 440 and constraints are PEP 508 requirements.
+type Python struct {
+	Store knowledgeStore.KnowledgeStore
+}
+
+func (python Python) Name() string {
+	return knowledgeStore.PYPI
+}
+
+func (python Python) Key(name string, version string) string {
+	return name + "@" + version
+}
+
+func (python Python) SplitKey(key string) (string, string) {
+	return splitOnLastAt(key)
+}
+
+// Purl uses the PEP 503 normalized name, as the pypi purl type requires.
+func (python Python) Purl(name string, version string) string {
+	return purl("pypi", pep440.NormalizeName(name), version)
+}
+
+func (python Python) Compare(a string, b string) (int, error) {
+	versionA, err := pep440.Parse(a)
+	if err != nil {
+		return 0, err
+	}
+	versionB, err := pep440.Parse(b)
+	if err != nil {
+		return 0, err
+	}
+	return versionA.Compare(versionB), nil
+}
+
+// SortVersions sorts versions according to PEP 440, dropping the ones that are not valid PEP 440 versions.
+func (python Python) SortVersions(versions []string) ([]string, error) {
+	return pep440.SortStrings(versions), nil
+}
+
+func (python Python) IsPrerelease(version string) bool {
+	parsed, err := pep440.Parse(version)
+	return err == nil && parsed.IsPrerelease()
+}
+
+// ParseVersion wraps a PEP 440 version in the semver structure used by PatchInfo.
+func (python Python) ParseVersion(version string) (versions.Semver, error) {
+	parsed, err := pep440.Parse(version)
+	if err != nil {
+		return versions.Semver{}, err
+	}
+	return versions.Semver{Version: parsed.String()}, nil
+}
+
+func (python Python) Satisfies(version string, constraint string) (bool, error) {
+	specifiers, err := parseRequirementConstraint(constraint)
+	if err != nil {
+		return false, err
+	}
+	parsed, err := pep440.Parse(version)
+	if err != nil {
+		return false, err
+	}
+	return specifiers.Contains(parsed, true), nil
+}
+
+func (python Python) MaxSatisfying(versions []string, constraint string) (string, error) {
+	specifiers, err := parseRequirementConstraint(constraint)
+	if err != nil {
+		return "", err
+	}
+	candidates := []pep440.Version{}
+	for _, version := range versions {
+		parsed, err := pep440.Parse(version)
+		if err != nil {
+			continue
+		}
+		candidates = append(candidates, parsed)
+	}
+	satisfying_version, err := specifiers.MaxSatisfying(candidates, false)
+	if err != nil {
+		return "", ErrNoSatisfyingVersion
+	}
+	return satisfying_version.String(), nil
+}
+
+// parseRequirementConstraint parses the version part of a PEP 508 requirement,
+// ignoring environment markers and the parentheses allowed around specifiers.
+func parseRequirementConstraint(constraint string) (pep440.SpecifierSet, error) {
+	constraint, _, _ = strings.Cut(constraint, ";")
+	constraint = strings.Trim(strings.TrimSpace(constraint), "()")
+	specifiers, err := pep440.ParseSpecifierSet(constraint)
+	if err != nil {
+		return nil, ErrInvalidConstraint
+	}
+	return specifiers, nil
+}
+
+// UpdateConstraint rewrites a constraint so that it admits version.
+// PEP 440 specifiers (requirements.txt, PEP 621 pyproject.toml) and Poetry's
+// caret and tilde constraints are supported.
+func (python Python) UpdateConstraint(constraint string, version string) (string, bool, error) {
+	parsed, err := pep440.Parse(version)
+	if err != nil {
+		return "", false, err
+	}
+	constraint = strings.TrimSpace(constraint)
+
+	if constraint == "" || constraint == "*" {
+		return constraint, true, nil
+	}
+
+	// Poetry caret and tilde requirements
+	if strings.HasPrefix(constraint, "^") || (strings.HasPrefix(constraint, "~") && !strings.HasPrefix(constraint, "~=")) {
+		operator := constraint[:1]
+		old, err := pep440.Parse(constraint[1:])
+		if err != nil {
+			return "", false, err
+		}
+		precision := len(old.Release)
+		release := make([]string, precision)
+		for i := range release {
+			if i < len(parsed.Release) {
+				release[i] = fmt.Sprint(parsed.Release[i])
+			} else {
+				release[i] = "0"
+			}
+		}
+		reapply := parsed.Compare(old) >= 0 && parsed.Major() == old.Major()
+		if operator == "~" && precision > 1 {
+			reapply = reapply && parsed.Minor() == old.Minor()
+		}
+		return operator + strings.Join(release, "."), reapply, nil
+	}
+
+	specifiers, err := pep440.ParseSpecifierSet(constraint)
+	if err != nil {
+		return "", false, err
+	}
+	return specifiers.Bump(parsed).String(), specifiers.Contains(parsed, false), nil
+}
+
+// Versions retrieves the PyPI releases of a package, ordered according to PEP 440.
+func (python Python) Versions(name string) ([]string, error) {
+	versions, err := python.Store.Versions(python.Name(), pep440.NormalizeName(name))
+	if err != nil {
+		return nil, err
+	}
+	return python.SortVersions(versions)
+}
+
+// Dependencies returns the requirements of a PyPI release keyed by normalized project name.
+// Direct references (URLs, local paths) cannot be resolved against the registry and are left out.
+func (python Python) Dependencies(name string, version string) (map[string]string, map[string]string, error) {
+	dependencies, devDependencies, err := python.Store.Dependencies(python.Name(), pep440.NormalizeName(name), version)
+	if err != nil {
+		return nil, nil, err
+	}
+	return normalizeRequirements(dependencies), normalizeRequirements(devDependencies), nil
+}
+
+func normalizeRequirements(requirements map[string]string) map[string]string {
+	normalized := map[string]string{}
+	for dep_name, dep_specifier := range requirements {
+		name, specifiers, err := pep440.ParseRequirement(dep_name + dep_specifier)
+		if err != nil {
+			continue
+		}
+		normalized[name] = specifiers.String()
+	}
+	return normalized
+}
+
+// Vulnerabilities returns the identifiers of the OSV advisories affecting a PyPI release.
+func (python Python) Vulnerabilities(name string, version string) ([]string, error) {
+	return python.Store.Vulnerabilities(python.Name(), pep440.NormalizeName(name), version)
+}
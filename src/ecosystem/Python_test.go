@@ -0,0 +1,51 @@
+package ecosystem
+
+import (
+	"testing"
+)
+
+func TestPythonUpdateConstraint(t *testing.T) {
+	python := Python{}
+	tests := []struct {
+		constraint string
+		version    string
+		expected   string
+		reapply    bool
+	}{
+		{"==2.19.1", "2.31.0", "==2.31.0", false},
+		{">=2.19,<3", "2.31.0", ">=2.31.0,<3", true},
+		{"^2.19", "2.31.0", "^2.31", true},
+		{"^2.19", "3.1.0", "^3.1", false},
+		{"~1.4.2", "1.4.9", "~1.4.9", true},
+		{"*", "1.0", "*", true},
+	}
+	for _, test := range tests {
+		constraint, reapply, err := python.UpdateConstraint(test.constraint, test.version)
+		if err != nil {
+			t.Errorf("UpdateConstraint(%q, %q) returned error: %v", test.constraint, test.version, err)
+			continue
+		}
+		if constraint != test.expected || reapply != test.reapply {
+			t.Errorf("UpdateConstraint(%q, %q) = (%q, %t), expected (%q, %t)", test.constraint, test.version, constraint, reapply, test.expected, test.reapply)
+		}
+	}
+}
+
+func TestPurl(t *testing.T) {
+	tests := []struct {
+		ecosystem Ecosystem
+		name      string
+		version   string
+		want      string
+	}{
+		{Npm{}, "lodash", "4.17.21", "pkg:npm/lodash@4.17.21"},
+		{Npm{}, "@babel/core", "7.24.0", "pkg:npm/%40babel/core@7.24.0"},
+		{Python{}, "Django_Rest.Framework", "3.15.1", "pkg:pypi/django-rest-framework@3.15.1"},
+		{Python{}, "torch", "2.3.0+cpu", "pkg:pypi/torch@2.3.0+cpu"},
+	}
+	for _, test := range tests {
+		if got := test.ecosystem.Purl(test.name, test.version); got != test.want {
+			t.Errorf("Purl(%q, %q) = %q, want %q", test.name, test.version, got, test.want)
+		}
+	}
+}
@@ -1,41 +1,71 @@
 package exceptionManager
 
 import (
+	"sync"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
 	errorTypes "github.com/CodeClarityCE/utility-types/exceptions"
 )
 
-var public_errors []errorTypes.PublicError = []errorTypes.PublicError{}
-var private_errors []errorTypes.PrivateError = []errorTypes.PrivateError{}
-
-// AddPublicError adds a public error to the list of public errors.
-// It takes a description string and an error type as parameters.
-// The description parameter specifies the description of the error.
-// The error_type parameter specifies the type of the error.
-func AddPublicError(description string, error_type errorTypes.ERROR_TYPE) {
-	public_error := errorTypes.PublicError{}
-	public_error.Description = description
-	public_error.Type = error_type
-	public_errors = append(public_errors, public_error)
-}
-
-// AddPrivateError adds a private error to the exception manager.
-// It takes a description string and an error type as parameters.
-// The private error is created with the given description and type,
-// and then appended to the list of private errors.
-func AddPrivateError(description string, error_type errorTypes.ERROR_TYPE) {
-	private_error := errorTypes.PrivateError{}
-	private_error.Description = description
-	private_error.Type = error_type
-	private_errors = append(private_errors, private_error)
-}
-
-// GetPublicErrors returns a slice of public errors.
-func GetPublicErrors() []errorTypes.PublicError {
+// Collector gathers the errors of a single analysis.
+// The plugin runs analyses one after the other in the same process, and sometimes concurrently,
+// so each analysis creates its own collector and passes it along.
+// It is safe for concurrent use.
+type Collector struct {
+	mutex   sync.Mutex
+	entries []patching.AnalysisError
+}
+
+// NewCollector returns an empty collector
+func NewCollector() *Collector {
+	return &Collector{entries: []patching.AnalysisError{}}
+}
+
+// Add records an error with its context
+func (collector *Collector) Add(entry patching.AnalysisError) {
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+	collector.entries = append(collector.entries, entry)
+}
+
+// AddError records an error that prevents part of the analysis from completing.
+// The description is shown to users and the detail kept for maintainers, either can be empty.
+func (collector *Collector) AddError(code errorTypes.ERROR_TYPE, description string, detail string) {
+	collector.Add(patching.AnalysisError{Code: code, Severity: patching.SEVERITY_ERROR, Description: description, Detail: detail})
+}
+
+// AddWarning records an error the analysis recovered from
+func (collector *Collector) AddWarning(code errorTypes.ERROR_TYPE, description string, detail string) {
+	collector.Add(patching.AnalysisError{Code: code, Severity: patching.SEVERITY_WARNING, Description: description, Detail: detail})
+}
+
+// Entries returns the recorded errors in the order they were added
+func (collector *Collector) Entries() []patching.AnalysisError {
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+	entries := make([]patching.AnalysisError, len(collector.entries))
+	copy(entries, collector.entries)
+	return entries
+}
+
+// GetPublicErrors returns the descriptions of the recorded errors
+func (collector *Collector) GetPublicErrors() []errorTypes.PublicError {
+	public_errors := []errorTypes.PublicError{}
+	for _, entry := range collector.Entries() {
+		if entry.Description != "" {
+			public_errors = append(public_errors, errorTypes.PublicError{Description: entry.Description, Type: entry.Code})
+		}
+	}
 	return public_errors
 }
 
-// GetPrivateErrors returns a slice of private errors.
-// It retrieves the private_errors variable from the ExceptionManager package.
-func GetPrivateErrors() []errorTypes.PrivateError {
+// GetPrivateErrors returns the details of the recorded errors
+func (collector *Collector) GetPrivateErrors() []errorTypes.PrivateError {
+	private_errors := []errorTypes.PrivateError{}
+	for _, entry := range collector.Entries() {
+		if entry.Detail != "" {
+			private_errors = append(private_errors, errorTypes.PrivateError{Description: entry.Detail, Type: entry.Code})
+		}
+	}
 	return private_errors
 }
@@ -1,97 +1,94 @@
 package exceptionManager
 
 import (
+	"sync"
 	"testing"
 
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
 	errorTypes "github.com/CodeClarityCE/utility-types/exceptions"
 )
 
-func TestAddPublicError(t *testing.T) {
+func TestAddError(t *testing.T) {
 	description := "mock description"
+	detail := "mock detail"
 	errorType := errorTypes.ERROR_TYPE("mock error type")
 
-	AddPublicError(description, errorType)
+	collector := NewCollector()
+	collector.AddError(errorType, description, detail)
 
-	// Verify that the public_errors slice contains the added public error
-	if len(public_errors) != 1 {
-		t.Errorf("Expected 1 public error, got %d", len(public_errors))
+	// Verify that the collector contains the added error
+	entries := collector.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 error, got %d", len(entries))
 	}
 
-	// Verify the description and error type of the added public error
-	if public_errors[0].Description != description {
-		t.Errorf("Expected description '%s', got '%s'", description, public_errors[0].Description)
+	// Verify the description, detail, type and severity of the added error
+	if entries[0].Description != description {
+		t.Errorf("Expected description '%s', got '%s'", description, entries[0].Description)
 	}
-	if public_errors[0].Type != errorType {
-		t.Errorf("Expected error type '%s', got '%s'", errorType, public_errors[0].Type)
+	if entries[0].Detail != detail {
+		t.Errorf("Expected detail '%s', got '%s'", detail, entries[0].Detail)
 	}
-}
-
-func TestAddPrivateError(t *testing.T) {
-	description := "mock description"
-	errorType := errorTypes.ERROR_TYPE("mock error type")
-
-	AddPrivateError(description, errorType)
-
-	// Verify that the private_errors slice contains the added private error
-	if len(private_errors) != 1 {
-		t.Errorf("Expected 1 private error, got %d", len(private_errors))
-	}
-
-	// Verify the description and error type of the added private error
-	if private_errors[0].Description != description {
-		t.Errorf("Expected description '%s', got '%s'", description, private_errors[0].Description)
+	if entries[0].Code != errorType {
+		t.Errorf("Expected error type '%s', got '%s'", errorType, entries[0].Code)
 	}
-	if private_errors[0].Type != errorType {
-		t.Errorf("Expected error type '%s', got '%s'", errorType, private_errors[0].Type)
+	if entries[0].Severity != patching.SEVERITY_ERROR {
+		t.Errorf("Expected severity '%s', got '%s'", patching.SEVERITY_ERROR, entries[0].Severity)
 	}
 }
 
 func TestGetPublicErrors(t *testing.T) {
-	// Create a mock public error
-	mockError := errorTypes.PublicError{
-		Description: "mock description",
-		Type:        errorTypes.ERROR_TYPE("mock error type"),
+	collector := NewCollector()
+	collector.AddError(errorTypes.GENERIC_ERROR, "mock description", "")
+	collector.AddWarning(errorTypes.GENERIC_ERROR, "", "mock detail")
+
+	// Only errors with a description are public
+	result := collector.GetPublicErrors()
+	if len(result) != 1 {
+		t.Fatalf("Expected 1 public error, got %d", len(result))
 	}
-
-	// Add the mock public error to the public_errors slice
-	public_errors = append(public_errors, mockError)
-
-	// Call the GetPublicErrors function
-	result := GetPublicErrors()
-
-	// Verify that the result contains the mock public error
-	if len(result) != 2 {
-		t.Errorf("Expected 2 public errors, got %d", len(result))
+	if result[0].Description != "mock description" {
+		t.Errorf("Expected description '%s', got '%s'", "mock description", result[0].Description)
 	}
-	if result[0].Description != mockError.Description {
-		t.Errorf("Expected description '%s', got '%s'", mockError.Description, result[0].Description)
-	}
-	if result[0].Type != mockError.Type {
-		t.Errorf("Expected error type '%s', got '%s'", mockError.Type, result[0].Type)
+	if result[0].Type != errorTypes.GENERIC_ERROR {
+		t.Errorf("Expected error type '%s', got '%s'", errorTypes.GENERIC_ERROR, result[0].Type)
 	}
 }
 
 func TestGetPrivateErrors(t *testing.T) {
-	// Create a mock private error
-	mockError := errorTypes.PrivateError{
-		Description: "mock description",
-		Type:        errorTypes.ERROR_TYPE("mock error type"),
+	collector := NewCollector()
+	collector.AddError(errorTypes.GENERIC_ERROR, "mock description", "")
+	collector.AddWarning(errorTypes.GENERIC_ERROR, "", "mock detail")
+
+	// Only errors with a detail are private
+	result := collector.GetPrivateErrors()
+	if len(result) != 1 {
+		t.Fatalf("Expected 1 private error, got %d", len(result))
 	}
+	if result[0].Description != "mock detail" {
+		t.Errorf("Expected description '%s', got '%s'", "mock detail", result[0].Description)
+	}
+}
 
-	// Add the mock private error to the private_errors slice
-	private_errors = append(private_errors, mockError)
-
-	// Call the GetPrivateErrors function
-	result := GetPrivateErrors()
-
-	// Verify that the result contains the mock private error
-	if len(result) != 2 {
-		t.Errorf("Expected 2 private errors, got %d", len(result))
+func TestCollectorsAreIndependent(t *testing.T) {
+	first := NewCollector()
+	second := NewCollector()
+
+	var wg sync.WaitGroup
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			first.Add(patching.AnalysisError{Code: errorTypes.GENERIC_ERROR, Severity: patching.SEVERITY_WARNING, Workspace: ".", Dependency: "lodash@4.17.20"})
+		}()
 	}
-	if result[0].Description != mockError.Description {
-		t.Errorf("Expected description '%s', got '%s'", mockError.Description, result[0].Description)
+	wg.Wait()
+
+	// Errors of one analysis do not leak into another
+	if len(first.Entries()) != 100 {
+		t.Errorf("Expected 100 errors, got %d", len(first.Entries()))
 	}
-	if result[0].Type != mockError.Type {
-		t.Errorf("Expected error type '%s', got '%s'", mockError.Type, result[0].Type)
+	if len(second.Entries()) != 0 {
+		t.Errorf("Expected no errors, got %d", len(second.Entries()))
 	}
 }
@@ -0,0 +1,137 @@
+package exploitability
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+)
+
+// DEFAULT_SEVERITY is the weight of a vulnerability whose severity is unknown
+const DEFAULT_SEVERITY = 5.0
+
+// HIGH_EPSS is the EPSS probability from which a vulnerability is considered likely to be exploited
+const HIGH_EPSS = 0.1
+
+const (
+	KEV_WEIGHT                = 2.0
+	RANSOMWARE_WEIGHT         = 0.5
+	EPSS_WEIGHT               = 2.0
+	EXPLOIT_REFERENCES_WEIGHT = 0.5
+)
+
+// KEVEntry is an entry of the CISA Known Exploited Vulnerabilities catalog
+type KEVEntry struct {
+	CVE                        string `json:"cve"`
+	DateAdded                  string `json:"date_added"`
+	KnownRansomwareCampaignUse bool   `json:"known_ransomware_campaign_use"`
+}
+
+// EPSSScore is the Exploit Prediction Scoring System score of a CVE
+type EPSSScore struct {
+	CVE        string  `json:"cve"`
+	EPSS       float64 `json:"epss"`
+	Percentile float64 `json:"percentile"`
+}
+
+// Dataset holds the exploitation data known about vulnerabilities, keyed by CVE id
+type Dataset struct {
+	KEV               map[string]KEVEntry
+	EPSS              map[string]EPSSScore
+	ExploitReferences map[string][]string
+}
+
+func NewDataset() Dataset {
+	return Dataset{
+		KEV:               map[string]KEVEntry{},
+		EPSS:              map[string]EPSSScore{},
+		ExploitReferences: map[string][]string{},
+	}
+}
+
+// Multiplier returns how much the severity of a vulnerability is amplified by what is known of its exploitation
+func (dataset Dataset) Multiplier(vulnerabilityId string) float64 {
+	multiplier := 1.0
+	if kev, ok := dataset.KEV[vulnerabilityId]; ok {
+		multiplier += KEV_WEIGHT
+		if kev.KnownRansomwareCampaignUse {
+			multiplier += RANSOMWARE_WEIGHT
+		}
+	}
+	if epss, ok := dataset.EPSS[vulnerabilityId]; ok {
+		multiplier += epss.EPSS * EPSS_WEIGHT
+	}
+	if len(dataset.ExploitReferences[vulnerabilityId]) > 0 {
+		multiplier += EXPLOIT_REFERENCES_WEIGHT
+	}
+	return multiplier
+}
+
+// Prioritize computes the priority of a recommendation from the vulnerabilities it fixes,
+// and explains which of them raise it.
+func (dataset Dataset) Prioritize(patch patching.PatchInfo) patching.PatchInfo {
+	priority := 0.0
+	reasons := []string{}
+	seen := map[string]bool{}
+	for _, toPatch := range patch.Patchable {
+		vulnerabilityId := toPatch.Vulnerability.VulnerabilityId
+		severity := toPatch.Vulnerability.Severity.Severity
+		if severity <= 0 {
+			severity = DEFAULT_SEVERITY
+		}
+		priority += severity * dataset.Multiplier(vulnerabilityId)
+
+		if seen[vulnerabilityId] {
+			continue
+		}
+		seen[vulnerabilityId] = true
+		reasons = append(reasons, dataset.reasons(vulnerabilityId)...)
+	}
+
+	fixed := len(patch.Patchable)
+	total := fixed + len(patch.Unpatchable)
+	if total > 0 {
+		reasons = append(reasons, fmt.Sprintf("fixes %d of %d vulnerabilities", fixed, total))
+	}
+	if len(patch.Introduced) > 0 {
+		reasons = append(reasons, fmt.Sprintf("introduces %d vulnerabilities", len(patch.Introduced)))
+	}
+
+	patch.Priority = math.Round(priority*100) / 100
+	patch.PriorityReasons = reasons
+	return patch
+}
+
+func (dataset Dataset) reasons(vulnerabilityId string) []string {
+	reasons := []string{}
+	if kev, ok := dataset.KEV[vulnerabilityId]; ok {
+		reason := fmt.Sprintf("%s is in the CISA KEV catalog since %s", vulnerabilityId, kev.DateAdded)
+		if kev.KnownRansomwareCampaignUse {
+			reason += " and is used in ransomware campaigns"
+		}
+		reasons = append(reasons, reason)
+	}
+	if epss, ok := dataset.EPSS[vulnerabilityId]; ok && epss.EPSS >= HIGH_EPSS {
+		reasons = append(reasons, fmt.Sprintf("%s has an EPSS score of %.3f (percentile %.2f)", vulnerabilityId, epss.EPSS, epss.Percentile))
+	}
+	if len(dataset.ExploitReferences[vulnerabilityId]) > 0 {
+		reasons = append(reasons, fmt.Sprintf("%s has public exploits referenced in NVD", vulnerabilityId))
+	}
+	return reasons
+}
+
+// VulnerabilityIds lists the distinct vulnerability ids of the patches
+func VulnerabilityIds(patches ...map[string]patching.PatchInfo) []string {
+	ids := []string{}
+	for _, patchMap := range patches {
+		for _, patch := range patchMap {
+			for _, toPatch := range slices.Concat(patch.Patchable, patch.Unpatchable, patch.Introduced) {
+				if !slices.Contains(ids, toPatch.Vulnerability.VulnerabilityId) {
+					ids = append(ids, toPatch.Vulnerability.VulnerabilityId)
+				}
+			}
+		}
+	}
+	return ids
+}
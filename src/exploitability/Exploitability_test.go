@@ -0,0 +1,48 @@
+package exploitability
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+	vulnerabilityFinder "github.com/CodeClarityCE/plugin-sca-vuln-finder/src/types"
+)
+
+func TestLoadFeeds(t *testing.T) {
+	dataset := NewDataset()
+	kev := `{"vulnerabilities":[{"cveID":"CVE-2021-44228","dateAdded":"2021-12-10","knownRansomwareCampaignUse":"Known"}]}`
+	if err := dataset.readKEV(strings.NewReader(kev)); err != nil {
+		t.Fatal(err)
+	}
+	epss := "#model_version:v2023.03.01,score_date:2024-01-01T00:00:00+0000\ncve,epss,percentile\nCVE-2021-44228,0.97565,0.99995\nCVE-2020-8203,0.01234,0.5\n"
+	if err := dataset.readEPSS(strings.NewReader(epss)); err != nil {
+		t.Fatal(err)
+	}
+	if !dataset.KEV["CVE-2021-44228"].KnownRansomwareCampaignUse {
+		t.Errorf("expected CVE-2021-44228 to be used in ransomware campaigns")
+	}
+	if dataset.EPSS["CVE-2020-8203"].EPSS != 0.01234 {
+		t.Errorf("unexpected EPSS score %v", dataset.EPSS["CVE-2020-8203"])
+	}
+}
+
+func TestPrioritize(t *testing.T) {
+	dataset := NewDataset()
+	dataset.KEV["CVE-2021-44228"] = KEVEntry{CVE: "CVE-2021-44228", DateAdded: "2021-12-10"}
+
+	vulnerability := func(id string) patching.ToPatch {
+		return patching.ToPatch{Vulnerability: vulnerabilityFinder.Vulnerability{
+			VulnerabilityId: id,
+			Severity:        vulnerabilityFinder.VulnerabilityMatchSeverity{Severity: 10},
+		}}
+	}
+	exploited := dataset.Prioritize(patching.PatchInfo{Patchable: []patching.ToPatch{vulnerability("CVE-2021-44228")}})
+	other := dataset.Prioritize(patching.PatchInfo{Patchable: []patching.ToPatch{vulnerability("CVE-2020-8203")}})
+
+	if exploited.Priority <= other.Priority {
+		t.Errorf("expected the known exploited vulnerability to rank higher: %v <= %v", exploited.Priority, other.Priority)
+	}
+	if len(exploited.PriorityReasons) != 2 || !strings.Contains(exploited.PriorityReasons[0], "KEV") {
+		t.Errorf("unexpected reasons %v", exploited.PriorityReasons)
+	}
+}
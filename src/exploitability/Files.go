@@ -0,0 +1,97 @@
+package exploitability
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// kevCatalog mirrors the JSON feed published by CISA
+type kevCatalog struct {
+	Vulnerabilities []struct {
+		CveID                      string `json:"cveID"`
+		DateAdded                  string `json:"dateAdded"`
+		KnownRansomwareCampaignUse string `json:"knownRansomwareCampaignUse"`
+	} `json:"vulnerabilities"`
+}
+
+// LoadKEVFile reads the CISA KEV catalog (known_exploited_vulnerabilities.json) into the dataset
+func (dataset *Dataset) LoadKEVFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return dataset.readKEV(file)
+}
+
+func (dataset *Dataset) readKEV(reader io.Reader) error {
+	catalog := kevCatalog{}
+	if err := json.NewDecoder(reader).Decode(&catalog); err != nil {
+		return fmt.Errorf("invalid KEV catalog: %w", err)
+	}
+	for _, vulnerability := range catalog.Vulnerabilities {
+		dataset.KEV[vulnerability.CveID] = KEVEntry{
+			CVE:                        vulnerability.CveID,
+			DateAdded:                  vulnerability.DateAdded,
+			KnownRansomwareCampaignUse: strings.EqualFold(vulnerability.KnownRansomwareCampaignUse, "Known"),
+		}
+	}
+	return nil
+}
+
+// LoadEPSSFile reads an EPSS scores CSV (epss_scores-YYYY-MM-DD.csv) into the dataset
+func (dataset *Dataset) LoadEPSSFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return dataset.readEPSS(file)
+}
+
+func (dataset *Dataset) readEPSS(reader io.Reader) error {
+	csvReader := csv.NewReader(reader)
+	// The feed starts with a "#model_version:...,score_date:..." comment line
+	csvReader.Comment = '#'
+	csvReader.FieldsPerRecord = -1
+
+	header, err := csvReader.Read()
+	if err != nil {
+		return fmt.Errorf("invalid EPSS scores: %w", err)
+	}
+	columns := map[string]int{}
+	for i, column := range header {
+		columns[strings.TrimSpace(column)] = i
+	}
+	cveColumn, okCve := columns["cve"]
+	epssColumn, okEpss := columns["epss"]
+	percentileColumn, okPercentile := columns["percentile"]
+	if !okCve || !okEpss || !okPercentile {
+		return fmt.Errorf("invalid EPSS scores: missing cve, epss or percentile column")
+	}
+
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("invalid EPSS scores: %w", err)
+		}
+		if len(record) <= max(cveColumn, epssColumn, percentileColumn) {
+			continue
+		}
+		epss, err := strconv.ParseFloat(record[epssColumn], 64)
+		if err != nil {
+			continue
+		}
+		percentile, _ := strconv.ParseFloat(record[percentileColumn], 64)
+		dataset.EPSS[record[cveColumn]] = EPSSScore{CVE: record[cveColumn], EPSS: epss, Percentile: percentile}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package exploitability
+
+import (
+	"errors"
+	"maps"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/nvd"
+)
+
+// Source is the part of the knowledge store exploitation data is read from
+type Source interface {
+	NVDEntries(vulnerabilityIds []string) (map[string]nvd.Entry, error)
+	KEV(vulnerabilityIds []string) (map[string]KEVEntry, error)
+	EPSS(vulnerabilityIds []string) (map[string]EPSSScore, error)
+}
+
+// LoadFromKnowledge completes the dataset with the knowledge store for the given vulnerabilities.
+// KEV entries and EPSS scores are only looked up when no file was loaded for them,
+// and the NVD references tagged "Exploit" are always read.
+func (dataset *Dataset) LoadFromKnowledge(source Source, vulnerabilityIds []string) error {
+	if len(vulnerabilityIds) == 0 {
+		return nil
+	}
+	errs := []error{}
+
+	if len(dataset.KEV) == 0 {
+		kev, err := source.KEV(vulnerabilityIds)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		maps.Copy(dataset.KEV, kev)
+	}
+
+	if len(dataset.EPSS) == 0 {
+		epss, err := source.EPSS(vulnerabilityIds)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		maps.Copy(dataset.EPSS, epss)
+	}
+
+	entries, err := source.NVDEntries(vulnerabilityIds)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	for id, entry := range entries {
+		if len(entry.ExploitReferences) > 0 {
+			dataset.ExploitReferences[id] = entry.ExploitReferences
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,75 @@
+package knowledgeStore
+
+import (
+	"time"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/exploitability"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/metrics"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/nvd"
+)
+
+// Instrumented wraps a store and records the count and latency of its queries in the metrics
+type Instrumented struct {
+	Store KnowledgeStore
+}
+
+// NewInstrumented returns store with its queries measured
+func NewInstrumented(store KnowledgeStore) Instrumented {
+	return Instrumented{Store: store}
+}
+
+func (instrumented Instrumented) Versions(ecosystem string, name string) ([]string, error) {
+	start := time.Now()
+	versions, err := instrumented.Store.Versions(ecosystem, name)
+	metrics.ObserveKnowledgeQuery("versions", start, err)
+	return versions, err
+}
+
+func (instrumented Instrumented) Dependencies(ecosystem string, name string, version string) (map[string]string, map[string]string, error) {
+	start := time.Now()
+	dependencies, devDependencies, err := instrumented.Store.Dependencies(ecosystem, name, version)
+	metrics.ObserveKnowledgeQuery("dependencies", start, err)
+	return dependencies, devDependencies, err
+}
+
+func (instrumented Instrumented) Vulnerabilities(ecosystem string, name string, version string) ([]string, error) {
+	start := time.Now()
+	vulnerabilityIds, err := instrumented.Store.Vulnerabilities(ecosystem, name, version)
+	metrics.ObserveKnowledgeQuery("vulnerabilities", start, err)
+	return vulnerabilityIds, err
+}
+
+func (instrumented Instrumented) NVDEntries(vulnerabilityIds []string) (map[string]nvd.Entry, error) {
+	start := time.Now()
+	entries, err := instrumented.Store.NVDEntries(vulnerabilityIds)
+	metrics.ObserveKnowledgeQuery("nvd", start, err)
+	return entries, err
+}
+
+func (instrumented Instrumented) KEV(vulnerabilityIds []string) (map[string]exploitability.KEVEntry, error) {
+	start := time.Now()
+	entries, err := instrumented.Store.KEV(vulnerabilityIds)
+	metrics.ObserveKnowledgeQuery("kev", start, err)
+	return entries, err
+}
+
+func (instrumented Instrumented) EPSS(vulnerabilityIds []string) (map[string]exploitability.EPSSScore, error) {
+	start := time.Now()
+	scores, err := instrumented.Store.EPSS(vulnerabilityIds)
+	metrics.ObserveKnowledgeQuery("epss", start, err)
+	return scores, err
+}
+
+func (instrumented Instrumented) DataVersion() (string, error) {
+	start := time.Now()
+	version, err := instrumented.Store.DataVersion()
+	metrics.ObserveKnowledgeQuery("data_version", start, err)
+	return version, err
+}
+
+func (instrumented Instrumented) VulnerabilityDataVersion() (string, error) {
+	start := time.Now()
+	version, err := instrumented.Store.VulnerabilityDataVersion()
+	metrics.ObserveKnowledgeQuery("vulnerability_data_version", start, err)
+	return version, err
+}
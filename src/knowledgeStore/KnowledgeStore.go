@@ -0,0 +1,42 @@
+package knowledgeStore
+
+import (
+	"errors"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/exploitability"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/nvd"
+)
+
+// Ecosystems the store holds packages for, as named by the ecosystem drivers
+const (
+	NPM  = "npm"
+	PYPI = "PyPI"
+)
+
+// ErrNotFound is returned when a release is not known to the store
+var ErrNotFound = errors.New("not found in the knowledge store")
+
+// KnowledgeStore is where the patcher looks up packages and vulnerabilities.
+// Postgres queries the knowledge database, Snapshot answers from a file exported for a project.
+type KnowledgeStore interface {
+	// Versions returns the versions published for a package, in no particular order.
+	Versions(ecosystem string, name string) ([]string, error)
+	// Dependencies returns the production and development dependencies of a release,
+	// as maps of dependency name to constraint.
+	Dependencies(ecosystem string, name string, version string) (map[string]string, map[string]string, error)
+	// Vulnerabilities returns the identifiers of the vulnerabilities affecting a release.
+	Vulnerabilities(ecosystem string, name string, version string) ([]string, error)
+
+	// NVDEntries returns the NVD records of the given vulnerabilities, keyed by NVD id.
+	NVDEntries(vulnerabilityIds []string) (map[string]nvd.Entry, error)
+	// KEV returns the CISA KEV entries of the given vulnerabilities.
+	KEV(vulnerabilityIds []string) (map[string]exploitability.KEVEntry, error)
+	// EPSS returns the EPSS scores of the given vulnerabilities.
+	EPSS(vulnerabilityIds []string) (map[string]exploitability.EPSSScore, error)
+
+	// DataVersion identifies the state of the data, it changes whenever the answers may change.
+	DataVersion() (string, error)
+	// VulnerabilityDataVersion identifies the state of the vulnerability data only,
+	// which changes less often than the releases of the registries.
+	VulnerabilityDataVersion() (string, error)
+}
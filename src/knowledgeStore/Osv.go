@@ -0,0 +1,65 @@
+package knowledgeStore
+
+import "github.com/CodeClarityCE/plugin-sca-patching/src/pep440"
+
+// osvAffected mirrors the "affected" entries of an OSV advisory stored in the knowledge base.
+type osvAffected struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	Ranges []struct {
+		Type   string `json:"type"`
+		Events []struct {
+			Introduced   string `json:"introduced,omitempty"`
+			Fixed        string `json:"fixed,omitempty"`
+			LastAffected string `json:"last_affected,omitempty"`
+		} `json:"events"`
+	} `json:"ranges"`
+	Versions []string `json:"versions"`
+}
+
+// osvAffectsVersion evaluates the PyPI entries of an OSV advisory against a version.
+// Explicit version lists are checked first, then ECOSYSTEM ranges are walked in event order.
+func osvAffectsVersion(affected []osvAffected, dependencyName string, version pep440.Version) bool {
+	for _, entry := range affected {
+		if entry.Package.Ecosystem != "PyPI" || pep440.NormalizeName(entry.Package.Name) != pep440.NormalizeName(dependencyName) {
+			continue
+		}
+		for _, affectedVersion := range entry.Versions {
+			parsed, err := pep440.Parse(affectedVersion)
+			if err == nil && parsed.Equal(version) {
+				return true
+			}
+		}
+		for _, affectedRange := range entry.Ranges {
+			if affectedRange.Type != "ECOSYSTEM" {
+				continue
+			}
+			vulnerable := false
+			for _, event := range affectedRange.Events {
+				switch {
+				case event.Introduced != "":
+					introduced, err := pep440.Parse(event.Introduced)
+					if event.Introduced == "0" || (err == nil && introduced.Compare(version) <= 0) {
+						vulnerable = true
+					}
+				case event.Fixed != "":
+					fixed, err := pep440.Parse(event.Fixed)
+					if err == nil && fixed.Compare(version) <= 0 {
+						vulnerable = false
+					}
+				case event.LastAffected != "":
+					lastAffected, err := pep440.Parse(event.LastAffected)
+					if err == nil && lastAffected.Compare(version) < 0 {
+						vulnerable = false
+					}
+				}
+			}
+			if vulnerable {
+				return true
+			}
+		}
+	}
+	return false
+}
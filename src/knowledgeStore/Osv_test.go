@@ -0,0 +1,34 @@
+package knowledgeStore
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/pep440"
+)
+
+func TestOSVAffectsVersion(t *testing.T) {
+	affected := []osvAffected{}
+	err := json.Unmarshal([]byte(`[{
+		"package": {"ecosystem": "PyPI", "name": "Django"},
+		"ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "3.2.14"}, {"introduced": "4.0"}, {"fixed": "4.0.6"}]}],
+		"versions": ["1.0b1"]
+	}]`), &affected)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := map[string]bool{
+		"1.0b1":  true,
+		"3.2.13": true,
+		"3.2.14": false,
+		"3.2.20": false,
+		"4.0.5":  true,
+		"4.0.6":  false,
+	}
+	for version, expected := range tests {
+		if got := osvAffectsVersion(affected, "django", pep440.MustParse(version)); got != expected {
+			t.Errorf("osvAffectsVersion(%s) = %t, expected %t", version, got, expected)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package knowledgeStore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/exploitability"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/nvd"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/pep440"
+	matcher "github.com/CodeClarityCE/plugin-sca-vuln-finder/src/vulnerabilityMatcher"
+	nvdMatcher "github.com/CodeClarityCE/plugin-sca-vuln-finder/src/vulnerabilityMatcher/nvd"
+	semver "github.com/CodeClarityCE/utility-node-semver"
+	knowledge "github.com/CodeClarityCE/utility-types/knowledge_db"
+	"github.com/uptrace/bun"
+)
+
+// Postgres is the knowledge database: package registries mirrored in the package tables,
+// NVD for npm vulnerabilities and OSV for PyPI ones.
+type Postgres struct {
+	DB *bun.DB
+}
+
+type osvAdvisory struct {
+	OSVId    string          `bun:"osv_id"`
+	Aliases  json.RawMessage `bun:"aliases"`
+	Affected json.RawMessage `bun:"affected"`
+}
+
+type nvdRow struct {
+	NVDId        string          `bun:"nvd_id"`
+	Descriptions json.RawMessage `bun:"descriptions"`
+	Weaknesses   json.RawMessage `bun:"weaknesses"`
+	Metrics      json.RawMessage `bun:"metrics"`
+	References   json.RawMessage `bun:"references"`
+}
+
+type kevRow struct {
+	CVE                        string `bun:"cve_id"`
+	DateAdded                  string `bun:"date_added"`
+	KnownRansomwareCampaignUse string `bun:"known_ransomware_campaign_use"`
+}
+
+type epssRow struct {
+	CVE        string  `bun:"cve"`
+	EPSS       float64 `bun:"epss"`
+	Percentile float64 `bun:"percentile"`
+}
+
+// packageQuery restricts a query to a package of an ecosystem.
+// npm packages predate the language column and are matched on their name only.
+func packageQuery(query *bun.SelectQuery, ecosystem string, name string) *bun.SelectQuery {
+	query = query.Where("p.name = ?", name)
+	if ecosystem == PYPI {
+		query = query.Where("p.language = ?", "python")
+	}
+	return query
+}
+
+func (store Postgres) Versions(ecosystem string, name string) ([]string, error) {
+	var versions []knowledge.Version
+
+	// Execute a SELECT query using the knowledge base.
+	err := store.DB.RunInTx(context.Background(), nil, func(ctx context.Context, tx bun.Tx) error {
+		return packageQuery(tx.NewSelect().
+			Model(&versions).
+			ColumnExpr("pv.version, pv.id, pv.\"packageId\"").
+			Join("JOIN package AS p ON p.id = pv.\"packageId\""), ecosystem, name).
+			Scan(context.Background())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	versionFields := []string{}
+	for _, version := range versions {
+		versionFields = append(versionFields, version.Version)
+	}
+	return versionFields, nil
+}
+
+func (store Postgres) Dependencies(ecosystem string, name string, version string) (map[string]string, map[string]string, error) {
+	release := new(knowledge.Version)
+
+	// Execute a SELECT query using the knowledge base.
+	err := store.DB.RunInTx(context.Background(), nil, func(ctx context.Context, tx bun.Tx) error {
+		return packageQuery(tx.NewSelect().
+			Model(release).
+			ColumnExpr("pv.dependencies, pv.dev_dependencies").
+			Join("JOIN package AS p ON p.id = pv.\"packageId\""), ecosystem, name).
+			Where("pv.version = ?", version).
+			Scan(context.Background())
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return release.Dependencies, release.DevDependencies, nil
+}
+
+func (store Postgres) Vulnerabilities(ecosystem string, name string, version string) ([]string, error) {
+	if ecosystem == PYPI {
+		return store.osvVulnerabilities(name, version)
+	}
+	return store.nvdVulnerabilities(name, version)
+}
+
+// nvdVulnerabilities matches the NVD records of a product against a release
+func (store Postgres) nvdVulnerabilities(name string, version string) ([]string, error) {
+	vulnerabilities := []knowledge.NVDItem{}
+
+	ctx := context.Background()
+
+	// The product name is bound as a jsonpath variable, never concatenated into the path.
+	// The filter of the path is escaped as \? so that bun does not take it for a placeholder.
+	rows, err := store.DB.QueryContext(ctx, `
+		WITH preselect AS(SELECT *, jsonb_path_query("affectedFlattened", '$[*].criteriaDict.product \? (@ == $name)', jsonb_build_object('name', ?::text))
+		FROM nvd)
+
+		SELECT DISTINCT id, nvd_id, "sourceIdentifier", published, "lastModified", "vulnStatus", descriptions, metrics, weaknesses, configurations, "affectedFlattened", affected, "references"
+		FROM preselect
+		WHERE "vulnStatus" = 'Analyzed' OR "vulnStatus" = 'Modified'
+	`, name)
+	if err != nil {
+		return nil, fmt.Errorf("nvd records of %s: %w", name, err)
+	}
+
+	err = store.DB.ScanRows(ctx, rows, &vulnerabilities)
+	if err != nil {
+		return nil, fmt.Errorf("nvd records of %s: %w", name, err)
+	}
+
+	semver, err := semver.ParseSemver(version)
+	if err != nil {
+		return nil, fmt.Errorf("%s@%s: %w", name, version, err)
+	}
+	vulnerabilitiesAffectingVersion := []string{}
+
+	for _, vulnerability := range vulnerabilities {
+		affectedUniform := nvdMatcher.NormalizeAffectedVersions(name, vulnerability.Affected, store.DB)
+		matches, _ := matcher.MatchRange(affectedUniform, semver)
+		if matches {
+			vulnerabilitiesAffectingVersion = append(vulnerabilitiesAffectingVersion, vulnerability.NVDId)
+			continue
+		}
+		matches, _ = matcher.MatchExact(affectedUniform, semver)
+		if matches {
+			vulnerabilitiesAffectingVersion = append(vulnerabilitiesAffectingVersion, vulnerability.NVDId)
+			continue
+		}
+		matches, _ = matcher.MatchUniversal(affectedUniform, semver)
+		if matches {
+			vulnerabilitiesAffectingVersion = append(vulnerabilitiesAffectingVersion, vulnerability.NVDId)
+			continue
+		}
+	}
+
+	return vulnerabilitiesAffectingVersion, nil
+}
+
+// osvVulnerabilities returns the identifiers of the OSV advisories affecting a PyPI release.
+// CVE aliases are preferred over OSV identifiers so that the results line up
+// with the ones produced by the vuln-finder.
+func (store Postgres) osvVulnerabilities(name string, version string) ([]string, error) {
+	advisories := []osvAdvisory{}
+
+	filter, err := json.Marshal([]map[string]any{
+		{"package": map[string]string{"ecosystem": PYPI, "name": name}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	err = store.DB.NewRaw(`SELECT osv_id, aliases, affected FROM osv WHERE affected @> ?::jsonb`, string(filter)).Scan(ctx, &advisories)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := pep440.Parse(version)
+	if err != nil {
+		return nil, err
+	}
+
+	vulnerabilityIds := []string{}
+	for _, advisory := range advisories {
+		affected := []osvAffected{}
+		if err := json.Unmarshal(advisory.Affected, &affected); err != nil {
+			continue
+		}
+		if !osvAffectsVersion(affected, name, parsed) {
+			continue
+		}
+
+		vulnerabilityId := advisory.OSVId
+		aliases := []string{}
+		_ = json.Unmarshal(advisory.Aliases, &aliases)
+		for _, alias := range aliases {
+			if strings.HasPrefix(alias, "CVE-") {
+				vulnerabilityId = alias
+				break
+			}
+		}
+		vulnerabilityIds = append(vulnerabilityIds, vulnerabilityId)
+	}
+
+	return vulnerabilityIds, nil
+}
+
+func (store Postgres) NVDEntries(vulnerabilityIds []string) (map[string]nvd.Entry, error) {
+	entries := map[string]nvd.Entry{}
+	if len(vulnerabilityIds) == 0 {
+		return entries, nil
+	}
+
+	rows := []nvdRow{}
+	err := store.DB.NewRaw(`SELECT nvd_id, descriptions, weaknesses, metrics, "references" FROM nvd WHERE nvd_id IN (?)`, bun.In(vulnerabilityIds)).Scan(context.Background(), &rows)
+	if err != nil {
+		return entries, err
+	}
+	for _, row := range rows {
+		entries[row.NVDId] = nvd.ParseRecord(row.NVDId, row.Descriptions, row.Weaknesses, row.Metrics, row.References)
+	}
+	return entries, nil
+}
+
+func (store Postgres) KEV(vulnerabilityIds []string) (map[string]exploitability.KEVEntry, error) {
+	entries := map[string]exploitability.KEVEntry{}
+	if len(vulnerabilityIds) == 0 {
+		return entries, nil
+	}
+
+	rows := []kevRow{}
+	err := store.DB.NewRaw(`SELECT cve_id, date_added, known_ransomware_campaign_use FROM kev WHERE cve_id IN (?)`, bun.In(vulnerabilityIds)).Scan(context.Background(), &rows)
+	if err != nil {
+		return entries, err
+	}
+	for _, row := range rows {
+		entries[row.CVE] = exploitability.KEVEntry{
+			CVE:                        row.CVE,
+			DateAdded:                  row.DateAdded,
+			KnownRansomwareCampaignUse: row.KnownRansomwareCampaignUse == "Known",
+		}
+	}
+	return entries, nil
+}
+
+func (store Postgres) EPSS(vulnerabilityIds []string) (map[string]exploitability.EPSSScore, error) {
+	scores := map[string]exploitability.EPSSScore{}
+	if len(vulnerabilityIds) == 0 {
+		return scores, nil
+	}
+
+	rows := []epssRow{}
+	err := store.DB.NewRaw(`SELECT cve, epss, percentile FROM epss WHERE cve IN (?)`, bun.In(vulnerabilityIds)).Scan(context.Background(), &rows)
+	if err != nil {
+		return scores, err
+	}
+	for _, row := range rows {
+		scores[row.CVE] = exploitability.EPSSScore(row)
+	}
+	return scores, nil
+}
+
+// DataVersion summarizes the tables the patcher reads: the last update of the packages mirrored
+// and the VulnerabilityDataVersion.
+func (store Postgres) DataVersion() (string, error) {
+	var packagesUpdated string
+	err := store.DB.NewRaw(`SELECT coalesce(max("time")::text, '') FROM package`).Scan(context.Background(), &packagesUpdated)
+	if err != nil {
+		return "", err
+	}
+	vulnerabilityDataVersion, err := store.VulnerabilityDataVersion()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("packages=%s %s", packagesUpdated, vulnerabilityDataVersion), nil
+}
+
+// VulnerabilityDataVersion summarizes the vulnerability tables from their content: the last modification
+// of the NVD and OSV records and the date the last KEV entry was added, along with a digest
+// of the KEV catalog and of the EPSS scores, which are replaced in place without a modification date.
+// Unlike statistics counters, the summary never comes back to a former value for different data.
+func (store Postgres) VulnerabilityDataVersion() (string, error) {
+	var summary struct {
+		NVDModified string `bun:"nvd_modified"`
+		OSVModified string `bun:"osv_modified"`
+		KEV         string `bun:"kev"`
+		EPSS        string `bun:"epss"`
+	}
+	err := store.DB.NewRaw(`SELECT
+		(SELECT coalesce(max("lastModified")::text, '') FROM nvd) AS nvd_modified,
+		(SELECT coalesce(max(modified)::text, '') FROM osv) AS osv_modified,
+		(SELECT coalesce(max(date_added)::text, '') || ':' || md5(coalesce(string_agg(cve_id || known_ransomware_campaign_use, ',' ORDER BY cve_id), '')) FROM kev) AS kev,
+		(SELECT count(*) || ':' || coalesce(sum(epss), 0) || ':' || coalesce(sum(percentile), 0) FROM epss) AS epss`).Scan(context.Background(), &summary)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("nvd=%s osv=%s kev=%s epss=%s", summary.NVDModified, summary.OSVModified, summary.KEV, summary.EPSS), nil
+}
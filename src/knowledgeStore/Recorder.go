@@ -0,0 +1,97 @@
+package knowledgeStore
+
+import (
+	"maps"
+	"sync"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/exploitability"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/nvd"
+)
+
+// Recorder wraps a store and keeps every answer it gives in a snapshot,
+// which can then be saved to run the same analysis offline.
+type Recorder struct {
+	Store    KnowledgeStore
+	Snapshot *Snapshot
+	mutex    sync.Mutex
+}
+
+// NewRecorder returns a recorder filling an empty snapshot
+func NewRecorder(store KnowledgeStore) *Recorder {
+	return &Recorder{Store: store, Snapshot: NewSnapshot()}
+}
+
+// Save writes the recorded snapshot to a file
+func (recorder *Recorder) Save(path string) error {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	return recorder.Snapshot.Save(path)
+}
+
+func (recorder *Recorder) Versions(ecosystem string, name string) ([]string, error) {
+	versions, err := recorder.Store.Versions(ecosystem, name)
+	if err != nil {
+		return versions, err
+	}
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	recorder.Snapshot.PackageVersions[packageKey(ecosystem, name)] = versions
+	return versions, nil
+}
+
+func (recorder *Recorder) Dependencies(ecosystem string, name string, version string) (map[string]string, map[string]string, error) {
+	dependencies, devDependencies, err := recorder.Store.Dependencies(ecosystem, name, version)
+	if err != nil {
+		return dependencies, devDependencies, err
+	}
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	recorder.Snapshot.Releases[releaseKey(ecosystem, name, version)] = SnapshotRelease{
+		Dependencies:    dependencies,
+		DevDependencies: devDependencies,
+	}
+	return dependencies, devDependencies, nil
+}
+
+func (recorder *Recorder) Vulnerabilities(ecosystem string, name string, version string) ([]string, error) {
+	vulnerabilityIds, err := recorder.Store.Vulnerabilities(ecosystem, name, version)
+	if err != nil {
+		return vulnerabilityIds, err
+	}
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	recorder.Snapshot.ReleaseVulnerabilities[releaseKey(ecosystem, name, version)] = vulnerabilityIds
+	return vulnerabilityIds, nil
+}
+
+func (recorder *Recorder) NVDEntries(vulnerabilityIds []string) (map[string]nvd.Entry, error) {
+	entries, err := recorder.Store.NVDEntries(vulnerabilityIds)
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	maps.Copy(recorder.Snapshot.NVDRecords, entries)
+	return entries, err
+}
+
+func (recorder *Recorder) KEV(vulnerabilityIds []string) (map[string]exploitability.KEVEntry, error) {
+	entries, err := recorder.Store.KEV(vulnerabilityIds)
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	maps.Copy(recorder.Snapshot.KEVEntries, entries)
+	return entries, err
+}
+
+func (recorder *Recorder) EPSS(vulnerabilityIds []string) (map[string]exploitability.EPSSScore, error) {
+	scores, err := recorder.Store.EPSS(vulnerabilityIds)
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	maps.Copy(recorder.Snapshot.EPSSScores, scores)
+	return scores, err
+}
+
+func (recorder *Recorder) DataVersion() (string, error) {
+	return recorder.Store.DataVersion()
+}
+
+func (recorder *Recorder) VulnerabilityDataVersion() (string, error) {
+	return recorder.Store.VulnerabilityDataVersion()
+}
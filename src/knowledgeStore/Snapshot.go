@@ -0,0 +1,173 @@
+package knowledgeStore
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/exploitability"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/nvd"
+)
+
+// SNAPSHOT_VERSION is the format version written in snapshot files
+const SNAPSHOT_VERSION = "1"
+
+// SnapshotRelease holds the dependency maps of a release
+type SnapshotRelease struct {
+	Dependencies    map[string]string `json:"dependencies"`
+	DevDependencies map[string]string `json:"dev_dependencies"`
+}
+
+// Snapshot is a knowledge store answering from a subset of the knowledge database saved to a file,
+// usually the packages and vulnerabilities one project needs. Packages are keyed "ecosystem:name"
+// and releases "ecosystem:name@version". Anything not in the snapshot is reported as ErrNotFound.
+type Snapshot struct {
+	Version                string                              `json:"version"`
+	PackageVersions        map[string][]string                 `json:"versions"`
+	Releases               map[string]SnapshotRelease          `json:"releases"`
+	ReleaseVulnerabilities map[string][]string                 `json:"vulnerabilities"`
+	NVDRecords             map[string]nvd.Entry                `json:"nvd"`
+	KEVEntries             map[string]exploitability.KEVEntry  `json:"kev"`
+	EPSSScores             map[string]exploitability.EPSSScore `json:"epss"`
+}
+
+// NewSnapshot returns an empty snapshot
+func NewSnapshot() *Snapshot {
+	return &Snapshot{
+		Version:                SNAPSHOT_VERSION,
+		PackageVersions:        map[string][]string{},
+		Releases:               map[string]SnapshotRelease{},
+		ReleaseVulnerabilities: map[string][]string{},
+		NVDRecords:             map[string]nvd.Entry{},
+		KEVEntries:             map[string]exploitability.KEVEntry{},
+		EPSSScores:             map[string]exploitability.EPSSScore{},
+	}
+}
+
+// LoadSnapshot reads a snapshot file, gzip compressed when its name ends in .gz
+func LoadSnapshot(path string) (*Snapshot, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("invalid snapshot %s: %w", path, err)
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+
+	snapshot := NewSnapshot()
+	if err := json.NewDecoder(reader).Decode(snapshot); err != nil {
+		return nil, fmt.Errorf("invalid snapshot %s: %w", path, err)
+	}
+	if snapshot.Version != SNAPSHOT_VERSION {
+		return nil, fmt.Errorf("unsupported snapshot version %q in %s", snapshot.Version, path)
+	}
+	return snapshot, nil
+}
+
+// Save writes the snapshot to a file, gzip compressed when its name ends in .gz
+func (snapshot *Snapshot) Save(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	var writer io.Writer = file
+	var gzipWriter *gzip.Writer
+	if strings.HasSuffix(path, ".gz") {
+		gzipWriter = gzip.NewWriter(file)
+		writer = gzipWriter
+	}
+	err = json.NewEncoder(writer).Encode(snapshot)
+	// Closing flushes the compressed data and the file, their errors mean the snapshot is incomplete
+	if gzipWriter != nil {
+		err = errors.Join(err, gzipWriter.Close())
+	}
+	return errors.Join(err, file.Close())
+}
+
+func packageKey(ecosystem string, name string) string {
+	return ecosystem + ":" + name
+}
+
+func releaseKey(ecosystem string, name string, version string) string {
+	return ecosystem + ":" + name + "@" + version
+}
+
+func (snapshot *Snapshot) Versions(ecosystem string, name string) ([]string, error) {
+	versions, ok := snapshot.PackageVersions[packageKey(ecosystem, name)]
+	if !ok {
+		return nil, fmt.Errorf("%s %s: %w", ecosystem, name, ErrNotFound)
+	}
+	return versions, nil
+}
+
+func (snapshot *Snapshot) Dependencies(ecosystem string, name string, version string) (map[string]string, map[string]string, error) {
+	release, ok := snapshot.Releases[releaseKey(ecosystem, name, version)]
+	if !ok {
+		return nil, nil, fmt.Errorf("%s %s@%s: %w", ecosystem, name, version, ErrNotFound)
+	}
+	return release.Dependencies, release.DevDependencies, nil
+}
+
+func (snapshot *Snapshot) Vulnerabilities(ecosystem string, name string, version string) ([]string, error) {
+	vulnerabilityIds, ok := snapshot.ReleaseVulnerabilities[releaseKey(ecosystem, name, version)]
+	if !ok {
+		return nil, fmt.Errorf("%s %s@%s: %w", ecosystem, name, version, ErrNotFound)
+	}
+	return vulnerabilityIds, nil
+}
+
+// Exploitation data is optional, so vulnerabilities missing from these tables are simply left out
+
+func (snapshot *Snapshot) NVDEntries(vulnerabilityIds []string) (map[string]nvd.Entry, error) {
+	return subset(snapshot.NVDRecords, vulnerabilityIds), nil
+}
+
+func (snapshot *Snapshot) KEV(vulnerabilityIds []string) (map[string]exploitability.KEVEntry, error) {
+	return subset(snapshot.KEVEntries, vulnerabilityIds), nil
+}
+
+func (snapshot *Snapshot) EPSS(vulnerabilityIds []string) (map[string]exploitability.EPSSScore, error) {
+	return subset(snapshot.EPSSScores, vulnerabilityIds), nil
+}
+
+func subset[T any](table map[string]T, keys []string) map[string]T {
+	result := map[string]T{}
+	for _, key := range keys {
+		if value, ok := table[key]; ok {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// DataVersion is a hash of the content of the snapshot
+func (snapshot *Snapshot) DataVersion() (string, error) {
+	content, err := json.Marshal(snapshot)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("snapshot-%x", sha256.Sum256(content)), nil
+}
+
+// VulnerabilityDataVersion is a hash of the vulnerabilities of the snapshot
+func (snapshot *Snapshot) VulnerabilityDataVersion() (string, error) {
+	content, err := json.Marshal([]any{snapshot.ReleaseVulnerabilities, snapshot.NVDRecords, snapshot.KEVEntries, snapshot.EPSSScores})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("snapshot-vulnerabilities-%x", sha256.Sum256(content)), nil
+}
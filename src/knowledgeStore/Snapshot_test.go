@@ -0,0 +1,77 @@
+package knowledgeStore
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/exploitability"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/nvd"
+)
+
+func TestRecordSnapshot(t *testing.T) {
+	source := NewSnapshot()
+	source.PackageVersions["npm:lodash"] = []string{"4.17.20", "4.17.21"}
+	source.Releases["npm:lodash@4.17.21"] = SnapshotRelease{Dependencies: map[string]string{}, DevDependencies: map[string]string{"mocha": "^8.0.0"}}
+	source.ReleaseVulnerabilities["npm:lodash@4.17.20"] = []string{"CVE-2021-23337"}
+	source.NVDRecords["CVE-2021-23337"] = nvd.Entry{Id: "CVE-2021-23337", BaseScore: 7.2}
+	source.KEVEntries["CVE-2021-44228"] = exploitability.KEVEntry{CVE: "CVE-2021-44228"}
+
+	recorder := NewRecorder(source)
+	recorder.Versions(NPM, "lodash")
+	recorder.Dependencies(NPM, "lodash", "4.17.21")
+	recorder.Vulnerabilities(NPM, "lodash", "4.17.20")
+	recorder.NVDEntries([]string{"CVE-2021-23337"})
+	if _, _, err := recorder.Dependencies(NPM, "express", "4.18.2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing release, got %v", err)
+	}
+
+	for _, name := range []string{"snapshot.json", "snapshot.json.gz"} {
+		path := filepath.Join(t.TempDir(), name)
+		if err := recorder.Save(path); err != nil {
+			t.Fatalf("Error saving %s: %v", name, err)
+		}
+		snapshot, err := LoadSnapshot(path)
+		if err != nil {
+			t.Fatalf("Error loading %s: %v", name, err)
+		}
+
+		versions, err := snapshot.Versions(NPM, "lodash")
+		if err != nil || !slices.Equal(versions, []string{"4.17.20", "4.17.21"}) {
+			t.Errorf("Unexpected versions %v (%v)", versions, err)
+		}
+		_, devDependencies, err := snapshot.Dependencies(NPM, "lodash", "4.17.21")
+		if err != nil || devDependencies["mocha"] != "^8.0.0" {
+			t.Errorf("Unexpected dev dependencies %v (%v)", devDependencies, err)
+		}
+		vulnerabilityIds, err := snapshot.Vulnerabilities(NPM, "lodash", "4.17.20")
+		if err != nil || len(vulnerabilityIds) != 1 {
+			t.Errorf("Unexpected vulnerabilities %v (%v)", vulnerabilityIds, err)
+		}
+		if _, err := snapshot.Versions(PYPI, "lodash"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for a package of another ecosystem, got %v", err)
+		}
+		entries, _ := snapshot.NVDEntries([]string{"CVE-2021-23337", "CVE-2020-8203"})
+		if len(entries) != 1 || entries["CVE-2021-23337"].BaseScore != 7.2 {
+			t.Errorf("Unexpected NVD entries %v", entries)
+		}
+		// KEV was never asked for, so it was not recorded
+		if kev, _ := snapshot.KEV([]string{"CVE-2021-44228"}); len(kev) != 0 {
+			t.Errorf("Expected no recorded KEV entries, got %v", kev)
+		}
+	}
+}
+
+func TestSnapshotDataVersion(t *testing.T) {
+	snapshot := NewSnapshot()
+	snapshot.PackageVersions["npm:lodash"] = []string{"4.17.20"}
+	version, err := snapshot.DataVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot.PackageVersions["npm:lodash"] = append(snapshot.PackageVersions["npm:lodash"], "4.17.21")
+	if changed, _ := snapshot.DataVersion(); changed == version {
+		t.Errorf("Expected the data version to change with the content of the snapshot")
+	}
+}
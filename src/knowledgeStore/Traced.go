@@ -0,0 +1,78 @@
+package knowledgeStore
+
+import (
+	"context"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/exploitability"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/nvd"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Traced wraps a store and records a span for each of its queries.
+// The store does not take a context, so the spans are children of the one given here.
+type Traced struct {
+	Store   KnowledgeStore
+	Context context.Context
+}
+
+// NewTraced returns store with its queries traced under the span of ctx
+func NewTraced(ctx context.Context, store KnowledgeStore) Traced {
+	return Traced{Store: store, Context: ctx}
+}
+
+func (traced Traced) Versions(ecosystem string, name string) ([]string, error) {
+	_, span := tracing.Start(traced.Context, "knowledge.versions", attribute.String("ecosystem", ecosystem), attribute.String("package", name))
+	versions, err := traced.Store.Versions(ecosystem, name)
+	tracing.End(span, err)
+	return versions, err
+}
+
+func (traced Traced) Dependencies(ecosystem string, name string, version string) (map[string]string, map[string]string, error) {
+	_, span := tracing.Start(traced.Context, "knowledge.dependencies", attribute.String("ecosystem", ecosystem), attribute.String("package", name), attribute.String("version", version))
+	dependencies, devDependencies, err := traced.Store.Dependencies(ecosystem, name, version)
+	tracing.End(span, err)
+	return dependencies, devDependencies, err
+}
+
+func (traced Traced) Vulnerabilities(ecosystem string, name string, version string) ([]string, error) {
+	_, span := tracing.Start(traced.Context, "knowledge.vulnerabilities", attribute.String("ecosystem", ecosystem), attribute.String("package", name), attribute.String("version", version))
+	vulnerabilityIds, err := traced.Store.Vulnerabilities(ecosystem, name, version)
+	tracing.End(span, err)
+	return vulnerabilityIds, err
+}
+
+func (traced Traced) NVDEntries(vulnerabilityIds []string) (map[string]nvd.Entry, error) {
+	_, span := tracing.Start(traced.Context, "knowledge.nvd", attribute.Int("vulnerabilities", len(vulnerabilityIds)))
+	entries, err := traced.Store.NVDEntries(vulnerabilityIds)
+	tracing.End(span, err)
+	return entries, err
+}
+
+func (traced Traced) KEV(vulnerabilityIds []string) (map[string]exploitability.KEVEntry, error) {
+	_, span := tracing.Start(traced.Context, "knowledge.kev", attribute.Int("vulnerabilities", len(vulnerabilityIds)))
+	entries, err := traced.Store.KEV(vulnerabilityIds)
+	tracing.End(span, err)
+	return entries, err
+}
+
+func (traced Traced) EPSS(vulnerabilityIds []string) (map[string]exploitability.EPSSScore, error) {
+	_, span := tracing.Start(traced.Context, "knowledge.epss", attribute.Int("vulnerabilities", len(vulnerabilityIds)))
+	scores, err := traced.Store.EPSS(vulnerabilityIds)
+	tracing.End(span, err)
+	return scores, err
+}
+
+func (traced Traced) DataVersion() (string, error) {
+	_, span := tracing.Start(traced.Context, "knowledge.data_version")
+	version, err := traced.Store.DataVersion()
+	tracing.End(span, err)
+	return version, err
+}
+
+func (traced Traced) VulnerabilityDataVersion() (string, error) {
+	_, span := tracing.Start(traced.Context, "knowledge.vulnerability_data_version")
+	version, err := traced.Store.VulnerabilityDataVersion()
+	tracing.End(span, err)
+	return version, err
+}
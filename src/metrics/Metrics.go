@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Phases of an analysis whose duration is measured
+const (
+	PHASE_ANALYSIS          = "analysis"
+	PHASE_SBOM_LOAD         = "sbom_load"
+	PHASE_PATCH_APPLICATION = "patch_application"
+	PHASE_CANDIDATE_SEARCH  = "candidate_search"
+	// PHASE_EXPLOITABILITY_LOAD is the loading of the KEV and EPSS data of the vulnerabilities,
+	// the vulnerability lookups of candidates are measured as knowledge queries
+	PHASE_EXPLOITABILITY_LOAD = "exploitability_load"
+)
+
+// Outcomes of a result cache lookup, the hit ratio being hits over all lookups
+const (
+	CACHE_HIT   = "hit"
+	CACHE_MISS  = "miss"
+	CACHE_ERROR = "error"
+)
+
+// Registry holds the metrics of the plugin. They add up over all the analyses of the process.
+var Registry = prometheus.NewRegistry()
+
+var (
+	analyses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "patching_analyses_total",
+		Help: "Analyses run, by status.",
+	}, []string{"status"})
+	phaseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "patching_phase_duration_seconds",
+		Help:    "Duration of the phases of the analyses.",
+		Buckets: prometheus.ExponentialBuckets(0.01, 4, 10),
+	}, []string{"phase"})
+	candidateVersions = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "patching_candidate_versions_evaluated_total",
+		Help: "Candidate versions of direct dependencies scanned for vulnerabilities.",
+	})
+	knowledgeQueries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "patching_knowledge_queries_total",
+		Help: "Queries to the knowledge store, by query and outcome.",
+	}, []string{"query", "outcome"})
+	knowledgeQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "patching_knowledge_query_duration_seconds",
+		Help:    "Latency of the queries to the knowledge store.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"query"})
+	cacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "patching_result_cache_lookups_total",
+		Help: "Lookups of the result cache, by outcome.",
+	}, []string{"outcome"})
+	dependencies = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "patching_dependencies_total",
+		Help: "Vulnerable direct dependencies, by patch type.",
+	}, []string{"patch_type"})
+)
+
+func init() {
+	Registry.MustRegister(analyses, phaseDuration, candidateVersions, knowledgeQueries, knowledgeQueryDuration, cacheLookups, dependencies)
+}
+
+// Handler serves the metrics to Prometheus
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Serve exposes the metrics on /metrics at the given address, such as ":9090"
+func Serve(address string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	err := http.ListenAndServe(address, mux)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// ObservePhase records the duration of a phase which began at start
+func ObservePhase(phase string, start time.Time) {
+	phaseDuration.WithLabelValues(phase).Observe(time.Since(start).Seconds())
+}
+
+// CountCandidate records the scan of a candidate version
+func CountCandidate() {
+	candidateVersions.Inc()
+}
+
+// ObserveKnowledgeQuery records a query to the knowledge store which began at start
+func ObserveKnowledgeQuery(query string, start time.Time, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	knowledgeQueries.WithLabelValues(query, outcome).Inc()
+	knowledgeQueryDuration.WithLabelValues(query).Observe(time.Since(start).Seconds())
+}
+
+// CountCacheLookup records the outcome of a result cache lookup, CACHE_HIT, CACHE_MISS or CACHE_ERROR
+func CountCacheLookup(outcome string) {
+	cacheLookups.WithLabelValues(outcome).Inc()
+}
+
+// CountOutput records the status of an analysis and the patch types of its dependencies
+func CountOutput(output patching.Output) {
+	analyses.WithLabelValues(string(output.AnalysisInfo.Status)).Inc()
+	for _, workspace := range output.WorkSpaces {
+		for _, patches := range []map[string]patching.PatchInfo{workspace.Patches, workspace.DevPatches} {
+			for _, patch := range patches {
+				if patch.IsPatchable != "" {
+					dependencies.WithLabelValues(string(patch.IsPatchable)).Inc()
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+	codeclarity "github.com/CodeClarityCE/utility-types/codeclarity_db"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCountOutput(t *testing.T) {
+	full := testutil.ToFloat64(dependencies.WithLabelValues(string(patching.FULL)))
+	none := testutil.ToFloat64(dependencies.WithLabelValues(string(patching.NONE)))
+	successes := testutil.ToFloat64(analyses.WithLabelValues(string(codeclarity.SUCCESS)))
+
+	CountOutput(patching.Output{
+		WorkSpaces: map[string]patching.Workspace{
+			".": {
+				Patches:    map[string]patching.PatchInfo{"lodash@4.17.20": {IsPatchable: patching.FULL}, "minimist@1.2.5": {IsPatchable: patching.FULL}},
+				DevPatches: map[string]patching.PatchInfo{"mkdirp@0.5.5": {IsPatchable: patching.NONE}},
+			},
+		},
+		AnalysisInfo: patching.AnalysisInfo{Status: codeclarity.SUCCESS},
+	})
+
+	if got := testutil.ToFloat64(dependencies.WithLabelValues(string(patching.FULL))) - full; got != 2 {
+		t.Errorf("Expected 2 FULL dependencies, got %v", got)
+	}
+	if got := testutil.ToFloat64(dependencies.WithLabelValues(string(patching.NONE))) - none; got != 1 {
+		t.Errorf("Expected 1 NONE dependency, got %v", got)
+	}
+	if got := testutil.ToFloat64(analyses.WithLabelValues(string(codeclarity.SUCCESS))) - successes; got != 1 {
+		t.Errorf("Expected 1 successful analysis, got %v", got)
+	}
+}
@@ -0,0 +1,79 @@
+package nvd
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+)
+
+// Entry is the descriptive part of an NVD record, used to document vulnerabilities in exports
+type Entry struct {
+	Id                string   `json:"id"`
+	Description       string   `json:"description"`
+	Weaknesses        []string `json:"weaknesses"`
+	BaseScore         float64  `json:"base_score"`
+	References        []string `json:"references"`
+	ExploitReferences []string `json:"exploit_references"`
+}
+
+type langString struct {
+	Lang  string `json:"lang"`
+	Value string `json:"value"`
+}
+
+type cvssMetric struct {
+	CvssData struct {
+		BaseScore float64 `json:"baseScore"`
+	} `json:"cvssData"`
+}
+
+type reference struct {
+	Url  string   `json:"url"`
+	Tags []string `json:"tags"`
+}
+
+// ParseRecord builds an entry from the JSON columns of an NVD record, in the NVD API 2.0 format
+func ParseRecord(id string, rawDescriptions []byte, rawWeaknesses []byte, rawMetrics []byte, rawReferences []byte) Entry {
+	entry := Entry{Id: id, Weaknesses: []string{}, References: []string{}, ExploitReferences: []string{}}
+
+	descriptions := []langString{}
+	_ = json.Unmarshal(rawDescriptions, &descriptions)
+	for _, description := range descriptions {
+		if description.Lang == "en" {
+			entry.Description = description.Value
+			break
+		}
+	}
+
+	weaknesses := []struct {
+		Description []langString `json:"description"`
+	}{}
+	_ = json.Unmarshal(rawWeaknesses, &weaknesses)
+	for _, weakness := range weaknesses {
+		for _, description := range weakness.Description {
+			if strings.HasPrefix(description.Value, "CWE-") && !slices.Contains(entry.Weaknesses, description.Value) {
+				entry.Weaknesses = append(entry.Weaknesses, description.Value)
+			}
+		}
+	}
+
+	// The most recent CVSS version available wins
+	metrics := map[string][]cvssMetric{}
+	_ = json.Unmarshal(rawMetrics, &metrics)
+	for _, version := range []string{"cvssMetricV40", "cvssMetricV31", "cvssMetricV30", "cvssMetricV2"} {
+		if len(metrics[version]) > 0 {
+			entry.BaseScore = metrics[version][0].CvssData.BaseScore
+			break
+		}
+	}
+
+	references := []reference{}
+	_ = json.Unmarshal(rawReferences, &references)
+	for _, reference := range references {
+		entry.References = append(entry.References, reference.Url)
+		if slices.Contains(reference.Tags, "Exploit") {
+			entry.ExploitReferences = append(entry.ExploitReferences, reference.Url)
+		}
+	}
+	return entry
+}
@@ -0,0 +1,25 @@
+package nvd
+
+import "testing"
+
+func TestParseRecord(t *testing.T) {
+	entry := ParseRecord("CVE-2021-44228",
+		[]byte(`[{"lang":"es","value":"Apache Log4j2 ..."},{"lang":"en","value":"Apache Log4j2 JNDI features do not protect against attacker controlled LDAP."}]`),
+		[]byte(`[{"source":"nvd@nist.gov","type":"Primary","description":[{"lang":"en","value":"CWE-917"}]},{"description":[{"lang":"en","value":"NVD-CWE-Other"}]}]`),
+		[]byte(`{"cvssMetricV2":[{"cvssData":{"baseScore":9.3}}],"cvssMetricV31":[{"cvssData":{"baseScore":10.0}}]}`),
+		[]byte(`[{"url":"https://example.com/poc","tags":["Exploit","Third Party Advisory"]},{"url":"https://example.com","tags":["Patch"]}]`),
+	)
+
+	if entry.Description != "Apache Log4j2 JNDI features do not protect against attacker controlled LDAP." {
+		t.Errorf("Unexpected description %q", entry.Description)
+	}
+	if len(entry.Weaknesses) != 1 || entry.Weaknesses[0] != "CWE-917" {
+		t.Errorf("Unexpected weaknesses %v", entry.Weaknesses)
+	}
+	if entry.BaseScore != 10.0 {
+		t.Errorf("Expected the CVSS 3.1 score, got %v", entry.BaseScore)
+	}
+	if len(entry.References) != 2 || len(entry.ExploitReferences) != 1 {
+		t.Errorf("Unexpected references %v %v", entry.References, entry.ExploitReferences)
+	}
+}
@@ -0,0 +1,141 @@
+package outputGenerator
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/plan"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+)
+
+var upgradesHeader = []string{
+	"workspace", "dependency", "dev", "installed_version", "recommended_version", "patch_type",
+	"vulnerabilities_fixed", "vulnerabilities_remaining", "vulnerabilities_introduced", "potential_breaking_changes",
+}
+
+var occurencesHeader = []string{
+	"workspace", "vulnerability_id", "severity", "affected_dependency", "affected_version",
+	"direct_dependency", "dev", "status", "path",
+}
+
+// UpgradesCSV writes one row per workspace and vulnerable direct dependency
+func UpgradesCSV(output patching.Output, writer io.Writer) error {
+	csvWriter := csv.NewWriter(writer)
+	if err := csvWriter.Write(upgradesHeader); err != nil {
+		return err
+	}
+	for _, workspaceName := range slices.Sorted(maps.Keys(output.WorkSpaces)) {
+		workspace := output.WorkSpaces[workspaceName]
+		for _, dev := range []bool{false, true} {
+			patches := workspace.Patches
+			if dev {
+				patches = workspace.DevPatches
+			}
+			for _, dependency := range slices.Sorted(maps.Keys(patches)) {
+				patch := patches[dependency]
+				name, version := splitDependencyKey(dependency)
+				err := csvWriter.Write([]string{
+					workspaceName, name, strconv.FormatBool(dev), version, patch.UpdateVersion(), string(patch.IsPatchable),
+					strconv.Itoa(len(patch.Patchable)), strconv.Itoa(len(patch.Unpatchable)), strconv.Itoa(len(patch.Introduced)),
+					strconv.FormatBool(patch.UpdateVersion() != "" && plan.PotentialBreaking(version, patch.UpdateVersion())),
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// OccurencesCSV writes one row per vulnerability occurrence, with the path leading to the affected dependency
+func OccurencesCSV(output patching.Output, writer io.Writer) error {
+	csvWriter := csv.NewWriter(writer)
+	if err := csvWriter.Write(occurencesHeader); err != nil {
+		return err
+	}
+	for _, workspaceName := range slices.Sorted(maps.Keys(output.WorkSpaces)) {
+		workspace := output.WorkSpaces[workspaceName]
+		for _, dev := range []bool{false, true} {
+			patches := workspace.Patches
+			if dev {
+				patches = workspace.DevPatches
+			}
+			for _, dependency := range slices.Sorted(maps.Keys(patches)) {
+				patch := patches[dependency]
+				name, _ := splitDependencyKey(dependency)
+				for _, occurences := range []struct {
+					status string
+					list   []patching.ToPatch
+				}{
+					{"patched", patch.Patchable},
+					{"unpatched", patch.Unpatchable},
+					{"introduced", patch.Introduced},
+				} {
+					// Vulnerabilities of a dependency without an upgrade stay as they are
+					status := occurences.status
+					if status == "patched" && patch.UpdateVersion() == "" {
+						status = "unpatched"
+					}
+					for _, toPatch := range occurences.list {
+						err := csvWriter.Write([]string{
+							workspaceName,
+							toPatch.Vulnerability.VulnerabilityId,
+							severityCell(toPatch.Vulnerability.Severity.Severity),
+							toPatch.Vulnerability.AffectedDependency,
+							toPatch.Vulnerability.AffectedVersion,
+							name,
+							strconv.FormatBool(dev),
+							status,
+							strings.Join(toPatch.Path, " > "),
+						})
+						if err != nil {
+							return err
+						}
+					}
+				}
+			}
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// WriteCSV writes upgrades.csv and vulnerabilities.csv to a directory
+func WriteCSV(output patching.Output, directory string) error {
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return err
+	}
+	for fileName, export := range map[string]func(patching.Output, io.Writer) error{
+		"upgrades.csv":        UpgradesCSV,
+		"vulnerabilities.csv": OccurencesCSV,
+	} {
+		file, err := os.Create(filepath.Join(directory, fileName))
+		if err != nil {
+			return err
+		}
+		err = export(output, file)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func severityCell(severity float64) string {
+	if severity <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%.1f", severity)
+}
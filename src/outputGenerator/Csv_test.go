@@ -0,0 +1,45 @@
+package outputGenerator
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+	"github.com/CodeClarityCE/utility-node-semver/versions"
+)
+
+func TestUpgradesCSV(t *testing.T) {
+	buffer := bytes.Buffer{}
+	if err := UpgradesCSV(mockReportOutput(), &buffer); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected a header and 2 rows, got:\n%s", buffer.String())
+	}
+	if lines[2] != ".,lodash,false,4.17.15,4.17.21,FULL,1,0,0,false" {
+		t.Errorf("Unexpected row %q", lines[2])
+	}
+
+	// Upgrades left out of the plan are flagged too
+	output := mockReportOutput()
+	output.WorkSpaces["."].Patches["express@3.21.2"] = patching.PatchInfo{IsPatchable: patching.FULL, Update: versions.Semver{Version: "4.19.2"}}
+	buffer.Reset()
+	if err := UpgradesCSV(output, &buffer); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buffer.String(), ".,express,false,3.21.2,4.19.2,FULL,0,0,0,true") {
+		t.Errorf("Expected the major upgrade of express to be flagged, got:\n%s", buffer.String())
+	}
+}
+
+func TestOccurencesCSV(t *testing.T) {
+	buffer := bytes.Buffer{}
+	if err := OccurencesCSV(mockReportOutput(), &buffer); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buffer.String(), ".,GHSA-xxxx,,,,left-pad|x,false,unpatched,") {
+		t.Errorf("Unexpected occurences:\n%s", buffer.String())
+	}
+}
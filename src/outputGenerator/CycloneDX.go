@@ -0,0 +1,267 @@
+package outputGenerator
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/ecosystem"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/exploitability"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/nvd"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+	"github.com/google/uuid"
+)
+
+const CYCLONEDX_SPEC_VERSION = "1.5"
+
+// CycloneDXDocument is a CycloneDX 1.5 VEX document stating the remediation of every vulnerability
+type CycloneDXDocument struct {
+	BomFormat       string                   `json:"bomFormat"`
+	SpecVersion     string                   `json:"specVersion"`
+	SerialNumber    string                   `json:"serialNumber"`
+	Version         int                      `json:"version"`
+	Metadata        cyclonedxMetadata        `json:"metadata"`
+	Components      []cyclonedxComponent     `json:"components"`
+	Vulnerabilities []cyclonedxVulnerability `json:"vulnerabilities"`
+}
+
+type cyclonedxMetadata struct {
+	Timestamp string `json:"timestamp"`
+	Tools     struct {
+		Components []cyclonedxComponent `json:"components"`
+	} `json:"tools"`
+}
+
+type cyclonedxComponent struct {
+	Type    string `json:"type"`
+	BomRef  string `json:"bom-ref,omitempty"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	Purl    string `json:"purl,omitempty"`
+}
+
+type cyclonedxVulnerability struct {
+	BomRef         string              `json:"bom-ref"`
+	Id             string              `json:"id"`
+	Source         *cyclonedxSource    `json:"source,omitempty"`
+	Ratings        []cyclonedxRating   `json:"ratings,omitempty"`
+	Cwes           []int               `json:"cwes,omitempty"`
+	Description    string              `json:"description,omitempty"`
+	Recommendation string              `json:"recommendation,omitempty"`
+	Analysis       cyclonedxAnalysis   `json:"analysis"`
+	Affects        []cyclonedxAffected `json:"affects"`
+}
+
+type cyclonedxSource struct {
+	Name string `json:"name"`
+	Url  string `json:"url"`
+}
+
+type cyclonedxRating struct {
+	Score    float64 `json:"score"`
+	Severity string  `json:"severity"`
+}
+
+type cyclonedxAnalysis struct {
+	State         string   `json:"state"`
+	Justification string   `json:"justification,omitempty"`
+	Response      []string `json:"response,omitempty"`
+	Detail        string   `json:"detail"`
+}
+
+// cyclonedxJustifications are the justifications CycloneDX accepts for a vulnerability that does not affect a component
+var cyclonedxJustifications = []string{
+	"code_not_present",
+	"code_not_reachable",
+	"requires_configuration",
+	"requires_dependency",
+	"requires_environment",
+	"protected_by_compiler",
+	"protected_at_runtime",
+	"protected_at_perimeter",
+	"protected_by_mitigating_control",
+}
+
+type cyclonedxAffected struct {
+	Ref string `json:"ref"`
+}
+
+// CycloneDXOutput converts the patching output to a CycloneDX VEX document.
+// Components are identified by their purl, which is also their bom-ref, so that the document can be merged with the SBOM.
+// The analysis of every vulnerability comes from the vulnerability-centric view of the workspaces:
+// fixable vulnerabilities get an "update" response and the upgrades to apply, the others "can_not_fix".
+// Vulnerabilities are "exploitable" when listed in the CISA KEV catalog and "in_triage" otherwise,
+// suppressed vulnerabilities are "not_affected" with the justification of their suppression.
+func CycloneDXOutput(output patching.Output, packageEcosystem ecosystem.Ecosystem, nvdEntries map[string]nvd.Entry, kev map[string]exploitability.KEVEntry) CycloneDXDocument {
+	components := map[string]cyclonedxComponent{}
+	vulnerabilities := map[string]*cyclonedxVulnerability{}
+	patchTypes := map[string][]patching.PatchType{}
+	recommendations := map[string][]string{}
+	suppressions := map[string]patching.SuppressedVulnerability{}
+
+	addComponent := func(name string, version string) string {
+		purl := packageEcosystem.Purl(name, version)
+		components[purl] = cyclonedxComponent{Type: "library", BomRef: purl, Name: name, Version: version, Purl: purl}
+		return purl
+	}
+
+	for _, workspaceName := range slices.Sorted(maps.Keys(output.WorkSpaces)) {
+		workspace := output.WorkSpaces[workspaceName]
+		for _, vulnerabilityId := range slices.Sorted(maps.Keys(workspace.Vulnerabilities)) {
+			view := workspace.Vulnerabilities[vulnerabilityId]
+			// Vulnerabilities that only the recommended upgrades would bring are not part of the project yet
+			if view.IntroductionType == patching.NewlyIntroduced {
+				continue
+			}
+
+			vulnerability, ok := vulnerabilities[vulnerabilityId]
+			if !ok {
+				vulnerability = newCycloneDXVulnerability(vulnerabilityId, nvdEntries[vulnerabilityId])
+				vulnerabilities[vulnerabilityId] = vulnerability
+			}
+			patchTypes[vulnerabilityId] = append(patchTypes[vulnerabilityId], view.PatchType)
+
+			for _, occurence := range view.Patches {
+				addComponent(occurence.DirectDepName, occurence.DirectDepInstalledVersion)
+				for _, affected := range slices.Concat(occurence.PatchedOccurences, occurence.UnPatchedOccurences) {
+					ref := addComponent(affected.AffectedDependency, affected.AffectedVersion)
+					if !slices.ContainsFunc(vulnerability.Affects, func(a cyclonedxAffected) bool { return a.Ref == ref }) {
+						vulnerability.Affects = append(vulnerability.Affects, cyclonedxAffected{Ref: ref})
+					}
+					if affected.Severity.Severity > 0 && len(vulnerability.Ratings) == 0 {
+						vulnerability.Ratings = []cyclonedxRating{{Score: affected.Severity.Severity, Severity: cyclonedxSeverity(affected.Severity.Severity)}}
+					}
+				}
+				if len(occurence.PatchedOccurences) > 0 && occurence.DirectDepUpgradeVersion != "" {
+					recommendation := fmt.Sprintf("upgrade %s from %s to %s", occurence.DirectDepName, occurence.DirectDepInstalledVersion, occurence.DirectDepUpgradeVersion)
+					if workspaceName != "." {
+						recommendation += " in " + workspaceName
+					}
+					if !slices.Contains(recommendations[vulnerabilityId], recommendation) {
+						recommendations[vulnerabilityId] = append(recommendations[vulnerabilityId], recommendation)
+					}
+				}
+			}
+		}
+	}
+
+	// A vulnerability suppressed in a workspace may still affect another one, which takes precedence
+	for _, workspaceName := range slices.Sorted(maps.Keys(output.WorkSpaces)) {
+		for _, suppressed := range output.WorkSpaces[workspaceName].Suppressed {
+			if _, ok := patchTypes[suppressed.VulnerabilityId]; ok {
+				continue
+			}
+			vulnerability, ok := vulnerabilities[suppressed.VulnerabilityId]
+			if !ok {
+				vulnerability = newCycloneDXVulnerability(suppressed.VulnerabilityId, nvdEntries[suppressed.VulnerabilityId])
+				vulnerabilities[suppressed.VulnerabilityId] = vulnerability
+				suppressions[suppressed.VulnerabilityId] = suppressed
+			}
+			ref := addComponent(packageEcosystem.SplitKey(suppressed.Dependency))
+			if !slices.ContainsFunc(vulnerability.Affects, func(a cyclonedxAffected) bool { return a.Ref == ref }) {
+				vulnerability.Affects = append(vulnerability.Affects, cyclonedxAffected{Ref: ref})
+			}
+		}
+	}
+
+	document := CycloneDXDocument{
+		BomFormat:       "CycloneDX",
+		SpecVersion:     CYCLONEDX_SPEC_VERSION,
+		SerialNumber:    "urn:uuid:" + uuid.New().String(),
+		Version:         1,
+		Components:      []cyclonedxComponent{},
+		Vulnerabilities: []cyclonedxVulnerability{},
+	}
+	document.Metadata.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	document.Metadata.Tools.Components = []cyclonedxComponent{{Type: "application", Name: "codeclarity-patching"}}
+
+	for _, purl := range slices.Sorted(maps.Keys(components)) {
+		document.Components = append(document.Components, components[purl])
+	}
+	for _, vulnerabilityId := range slices.Sorted(maps.Keys(vulnerabilities)) {
+		vulnerability := vulnerabilities[vulnerabilityId]
+		if suppressed, ok := suppressions[vulnerabilityId]; ok {
+			vulnerability.Analysis = cyclonedxSuppressedAnalysis(suppressed)
+		} else {
+			_, exploited := kev[vulnerabilityId]
+			vulnerability.Analysis = cyclonedxAnalysisOf(patchTypes[vulnerabilityId], exploited)
+		}
+		if len(recommendations[vulnerabilityId]) > 0 {
+			recommendation := strings.Join(recommendations[vulnerabilityId], "; ")
+			vulnerability.Recommendation = strings.ToUpper(recommendation[:1]) + recommendation[1:]
+		}
+		document.Vulnerabilities = append(document.Vulnerabilities, *vulnerability)
+	}
+	return document
+}
+
+func newCycloneDXVulnerability(vulnerabilityId string, entry nvd.Entry) *cyclonedxVulnerability {
+	vulnerability := &cyclonedxVulnerability{
+		BomRef:      vulnerabilityId,
+		Id:          vulnerabilityId,
+		Description: entry.Description,
+		Affects:     []cyclonedxAffected{},
+	}
+	if strings.HasPrefix(vulnerabilityId, "CVE-") {
+		vulnerability.Source = &cyclonedxSource{Name: "NVD", Url: "https://nvd.nist.gov/vuln/detail/" + vulnerabilityId}
+	} else {
+		vulnerability.Source = &cyclonedxSource{Name: "OSV", Url: "https://osv.dev/vulnerability/" + vulnerabilityId}
+	}
+	for _, weakness := range entry.Weaknesses {
+		if cwe, err := strconv.Atoi(strings.TrimPrefix(weakness, "CWE-")); err == nil {
+			vulnerability.Cwes = append(vulnerability.Cwes, cwe)
+		}
+	}
+	if entry.BaseScore > 0 {
+		vulnerability.Ratings = []cyclonedxRating{{Score: entry.BaseScore, Severity: cyclonedxSeverity(entry.BaseScore)}}
+	}
+	return vulnerability
+}
+
+// cyclonedxAnalysisOf states the remediation of a vulnerability from its patch type in every workspace.
+// Only vulnerabilities known to be exploited are stated "exploitable", the others are left in triage.
+func cyclonedxAnalysisOf(patchTypes []patching.PatchType, exploited bool) cyclonedxAnalysis {
+	state := "in_triage"
+	if exploited {
+		state = "exploitable"
+	}
+	fixable := slices.ContainsFunc(patchTypes, func(patchType patching.PatchType) bool { return patchType != patching.NONE })
+	complete := !slices.ContainsFunc(patchTypes, func(patchType patching.PatchType) bool { return patchType != patching.FULL })
+	switch {
+	case complete:
+		return cyclonedxAnalysis{State: state, Response: []string{"update"}, Detail: "Every occurrence is fixed by the recommended upgrades."}
+	case fixable:
+		return cyclonedxAnalysis{State: state, Response: []string{"update", "can_not_fix"}, Detail: "Some occurrences are fixed by the recommended upgrades, no upgrade fixes the others."}
+	default:
+		return cyclonedxAnalysis{State: state, Response: []string{"can_not_fix"}, Detail: "No upgrade of the direct dependencies fixes this vulnerability."}
+	}
+}
+
+// cyclonedxSuppressedAnalysis states a suppressed vulnerability as not affecting the project.
+// The justification of the suppression is free text, it is kept as the detail
+// and also used as the CycloneDX justification when it is one of its values.
+func cyclonedxSuppressedAnalysis(suppressed patching.SuppressedVulnerability) cyclonedxAnalysis {
+	analysis := cyclonedxAnalysis{State: "not_affected", Detail: suppressed.Justification}
+	if justification := strings.TrimSpace(suppressed.Justification); slices.Contains(cyclonedxJustifications, justification) {
+		analysis.Justification = justification
+	}
+	return analysis
+}
+
+func cyclonedxSeverity(score float64) string {
+	switch {
+	case score >= 9:
+		return "critical"
+	case score >= 7:
+		return "high"
+	case score >= 4:
+		return "medium"
+	case score > 0:
+		return "low"
+	default:
+		return "none"
+	}
+}
@@ -0,0 +1,63 @@
+package outputGenerator
+
+import (
+	"testing"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/ecosystem"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/exploitability"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/nvd"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+	vulnerabilityFinder "github.com/CodeClarityCE/plugin-sca-vuln-finder/src/types"
+)
+
+func TestCycloneDXOutput(t *testing.T) {
+	qs := vulnerabilityFinder.Vulnerability{VulnerabilityId: "CVE-2022-24999", AffectedDependency: "qs", AffectedVersion: "6.7.0"}
+	output := patching.Output{WorkSpaces: map[string]patching.Workspace{
+		".": {
+			Vulnerabilities: map[string]patching.VulnerabilityPatchInfo{
+				"CVE-2022-24999": {
+					IntroductionType: patching.ExistedBefore,
+					PatchType:        patching.FULL,
+					Patches: map[string]patching.VulnerabilityOccurencePatchInfo{
+						"express@4.17.1": {
+							DirectDepName:             "express",
+							DirectDepInstalledVersion: "4.17.1",
+							DirectDepUpgradeVersion:   "4.17.3",
+							PatchedOccurences:         []vulnerabilityFinder.Vulnerability{qs},
+						},
+					},
+				},
+				"CVE-2024-43796": {IntroductionType: patching.NewlyIntroduced, PatchType: patching.NONE},
+			},
+			Suppressed: []patching.SuppressedVulnerability{
+				{VulnerabilityId: "CVE-2022-25883", Dependency: "semver@7.3.7", DirectDependency: "express@4.17.1", Justification: "code_not_reachable"},
+			},
+		},
+	}}
+	entries := map[string]nvd.Entry{"CVE-2022-24999": {BaseScore: 7.5, Weaknesses: []string{"CWE-1321"}}}
+	kev := map[string]exploitability.KEVEntry{"CVE-2022-24999": {CVE: "CVE-2022-24999"}}
+
+	document := CycloneDXOutput(output, ecosystem.Npm{}, entries, kev)
+
+	if len(document.Components) != 3 || document.Components[0].Purl != "pkg:npm/express@4.17.1" {
+		t.Errorf("Unexpected components %+v", document.Components)
+	}
+	if len(document.Vulnerabilities) != 2 {
+		t.Fatalf("Expected the existing and the suppressed vulnerabilities, got %+v", document.Vulnerabilities)
+	}
+	vulnerability := document.Vulnerabilities[0]
+	if vulnerability.Affects[0].Ref != "pkg:npm/qs@6.7.0" || vulnerability.Cwes[0] != 1321 || vulnerability.Ratings[0].Severity != "high" {
+		t.Errorf("Unexpected vulnerability %+v", vulnerability)
+	}
+	if vulnerability.Analysis.State != "exploitable" || vulnerability.Analysis.Response[0] != "update" || vulnerability.Recommendation != "Upgrade express from 4.17.1 to 4.17.3" {
+		t.Errorf("Unexpected remediation %+v %q", vulnerability.Analysis, vulnerability.Recommendation)
+	}
+
+	suppressed := document.Vulnerabilities[1]
+	if suppressed.Analysis.State != "not_affected" || suppressed.Analysis.Justification != "code_not_reachable" || suppressed.Affects[0].Ref != "pkg:npm/semver@7.3.7" {
+		t.Errorf("Unexpected suppressed vulnerability %+v", suppressed)
+	}
+	if analysis := cyclonedxAnalysisOf([]patching.PatchType{patching.NONE}, false); analysis.State != "in_triage" {
+		t.Errorf("Expected a vulnerability not known to be exploited to be in triage, got %+v", analysis)
+	}
+}
@@ -10,41 +10,77 @@ import (
 )
 
 // SuccessOutput generates the output for a successful analysis.
-// It takes in the workspaceData, analysisStats, sbomAnalysisInfo, and start time as parameters.
+// It takes in the workspaceData, sbomAnalysisInfo, the errors of the analysis, and start time as parameters.
 // It returns a patchingTypes.Output struct containing the workspace data, analysis information, and timing details.
-func SuccessOutput(workspaceData map[string]patching.Workspace, sbomAnalysisInfo sbomTypes.AnalysisInfo, start time.Time) patching.Output {
+func SuccessOutput(workspaceData map[string]patching.Workspace, sbomAnalysisInfo sbomTypes.AnalysisInfo, errors *exceptionManager.Collector, start time.Time) patching.Output {
+	severityDist := patching.SeverityDist{}
+	afterUpgradeSeverityDist := patching.SeverityDist{}
+	for _, workspace := range workspaceData {
+		severityDist = severityDist.Plus(workspace.SeverityDist)
+		afterUpgradeSeverityDist = afterUpgradeSeverityDist.Plus(workspace.AfterUpgradeSeverityDist)
+	}
+
 	return patching.Output{
-		WorkSpaces: workspaceData,
+		SchemaVersion:            patching.SCHEMA_VERSION,
+		WorkSpaces:               workspaceData,
+		AlignedUpgrades:          []patching.AlignedUpgrade{},
+		SeverityDist:             severityDist,
+		AfterUpgradeSeverityDist: afterUpgradeSeverityDist,
 		AnalysisInfo: patching.AnalysisInfo{
-			Status:            codeclarity.SUCCESS,
-			AnalysisStartTime: start.Local().String(),
-			AnalysisEndTime:   time.Now().Local().String(),
-			AnalysisDeltaTime: time.Since(start).Seconds(),
-			PrivateErrors:     exceptionManager.GetPrivateErrors(),
-			PublicErrors:      exceptionManager.GetPublicErrors(),
+			Status:                   codeclarity.SUCCESS,
+			AnalysisStartTime:        start.Local().String(),
+			AnalysisEndTime:          time.Now().Local().String(),
+			AnalysisDeltaTime:        time.Since(start).Seconds(),
+			PrivateErrors:            errors.GetPrivateErrors(),
+			PublicErrors:             errors.GetPublicErrors(),
+			Errors:                   errors.Entries(),
+			VersionSeperator:         sbomAnalysisInfo.VersionSeperator,
+			ImportPathSeperator:      sbomAnalysisInfo.ImportPathSeperator,
+			DefaultWorkspaceName:     sbomAnalysisInfo.DefaultWorkspaceName,
+			SelfManagedWorkspaceName: sbomAnalysisInfo.SelfManagedWorkspaceName,
 		},
 	}
 }
 
 // FailureOutput generates the output for a failed analysis.
-// It takes the sbomAnalysisInfo and start time as input parameters.
+// It takes the sbomAnalysisInfo, the errors of the analysis and start time as input parameters.
 // It returns an instance of patchingTypes.Output.
-func FailureOutput(sbomAnalysisInfo sbomTypes.AnalysisInfo, start time.Time) patching.Output {
+func FailureOutput(sbomAnalysisInfo sbomTypes.AnalysisInfo, errors *exceptionManager.Collector, start time.Time) patching.Output {
 	formattedStart, formattedEnd, delta := getAnalysisTiming(start)
 	output := patching.Output{
+		SchemaVersion: patching.SCHEMA_VERSION,
 		AnalysisInfo: patching.AnalysisInfo{
-			Status:            codeclarity.FAILURE,
-			AnalysisStartTime: formattedStart,
-			AnalysisEndTime:   formattedEnd,
-			AnalysisDeltaTime: delta,
-			PrivateErrors:     exceptionManager.GetPrivateErrors(),
-			PublicErrors:      exceptionManager.GetPublicErrors(),
+			Status:                   codeclarity.FAILURE,
+			AnalysisStartTime:        formattedStart,
+			AnalysisEndTime:          formattedEnd,
+			AnalysisDeltaTime:        delta,
+			PrivateErrors:            errors.GetPrivateErrors(),
+			PublicErrors:             errors.GetPublicErrors(),
+			Errors:                   errors.Entries(),
+			VersionSeperator:         sbomAnalysisInfo.VersionSeperator,
+			ImportPathSeperator:      sbomAnalysisInfo.ImportPathSeperator,
+			DefaultWorkspaceName:     sbomAnalysisInfo.DefaultWorkspaceName,
+			SelfManagedWorkspaceName: sbomAnalysisInfo.SelfManagedWorkspaceName,
 		},
-		WorkSpaces: map[string]patching.Workspace{},
+		WorkSpaces:      map[string]patching.Workspace{},
+		AlignedUpgrades: []patching.AlignedUpgrade{},
 	}
 	return output
 }
 
+// CachedOutput reuses the output of an earlier analysis with the same inputs, stored as cachedResult.
+// The timings and errors are the ones of the current analysis.
+func CachedOutput(cached patching.Output, cachedResult string, errors *exceptionManager.Collector, start time.Time) patching.Output {
+	output := cached
+	output.AnalysisInfo.AnalysisStartTime, output.AnalysisInfo.AnalysisEndTime, output.AnalysisInfo.AnalysisDeltaTime = getAnalysisTiming(start)
+	output.AnalysisInfo.PrivateErrors = errors.GetPrivateErrors()
+	output.AnalysisInfo.PublicErrors = errors.GetPublicErrors()
+	output.AnalysisInfo.Errors = errors.Entries()
+	output.AnalysisInfo.CacheHit = true
+	output.AnalysisInfo.CachedResult = cachedResult
+	return output
+}
+
 // getAnalysisTiming calculates the start time, end time, and elapsed time of an analysis.
 // It takes the start time as a parameter and returns the start time, end time, and elapsed time in seconds.
 func getAnalysisTiming(start time.Time) (string, string, float64) {
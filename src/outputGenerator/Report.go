@@ -0,0 +1,275 @@
+package outputGenerator
+
+import (
+	"bytes"
+	"cmp"
+	"embed"
+	"fmt"
+	htmlTemplate "html/template"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	textTemplate "text/template"
+	"time"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/plan"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+)
+
+//go:embed templates
+var templates embed.FS
+
+// TOP_ACTIONS is the number of upgrades highlighted at the top of the reports
+const TOP_ACTIONS = 10
+
+type reportData struct {
+	GeneratedOn string
+	Status      string
+	Summary     reportSummary
+	TopActions  []reportAction
+	Workspaces  []reportWorkspace
+	Unfixable   []reportUnfixable
+}
+
+type reportSummary struct {
+	Workspaces             int
+	VulnerableDependencies int
+	Full                   int
+	Partial                int
+	None                   int
+	Before                 patching.SeverityDist
+	After                  patching.SeverityDist
+}
+
+type reportAction struct {
+	Workspace       string
+	Upgrades        []string
+	SeverityRemoved float64
+	Breaking        bool
+	Reasons         []string
+}
+
+type reportWorkspace struct {
+	Name     string
+	Before   patching.SeverityDist
+	After    patching.SeverityDist
+	Upgrades []reportUpgrade
+}
+
+type reportUpgrade struct {
+	Dependency    string
+	Dev           bool
+	Installed     string
+	Recommended   string
+	PatchType     patching.PatchType
+	Fixed         int
+	Remaining     int
+	Introduced    int
+	SeverityDelta string
+	Breaking      bool
+}
+
+type reportUnfixable struct {
+	Workspace     string
+	Vulnerability string
+	Affected      []string
+	Via           []string
+}
+
+// MarkdownReport renders a Markdown summary of the patching output
+func MarkdownReport(output patching.Output) ([]byte, error) {
+	report, err := textTemplate.New("report.md.tmpl").Funcs(textTemplate.FuncMap{
+		"join":  strings.Join,
+		"cell":  markdownCell,
+		"total": severityTotal,
+		"inc":   increment,
+	}).ParseFS(templates, "templates/report.md.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	buffer := bytes.Buffer{}
+	err = report.Execute(&buffer, newReportData(output))
+	return buffer.Bytes(), err
+}
+
+// HTMLReport renders a self-contained HTML report of the patching output, styles included
+func HTMLReport(output patching.Output) ([]byte, error) {
+	report, err := htmlTemplate.New("report.html.tmpl").Funcs(htmlTemplate.FuncMap{
+		"join":  strings.Join,
+		"total": severityTotal,
+		"inc":   increment,
+	}).ParseFS(templates, "templates/report.html.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	buffer := bytes.Buffer{}
+	err = report.Execute(&buffer, newReportData(output))
+	return buffer.Bytes(), err
+}
+
+// WriteReports writes report.md and report.html to a directory
+func WriteReports(output patching.Output, directory string) error {
+	markdown, err := MarkdownReport(output)
+	if err != nil {
+		return err
+	}
+	html, err := HTMLReport(output)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(directory, "report.md"), markdown, 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(directory, "report.html"), html, 0644)
+}
+
+func newReportData(output patching.Output) reportData {
+	data := reportData{
+		GeneratedOn: time.Now().UTC().Format(time.RFC3339),
+		Status:      string(output.AnalysisInfo.Status),
+		Summary: reportSummary{
+			Workspaces: len(output.WorkSpaces),
+			Before:     output.SeverityDist,
+			After:      output.AfterUpgradeSeverityDist,
+		},
+	}
+
+	type rankedAction struct {
+		action reportAction
+		score  float64
+	}
+	actions := []rankedAction{}
+
+	for _, workspaceName := range slices.Sorted(maps.Keys(output.WorkSpaces)) {
+		workspace := output.WorkSpaces[workspaceName]
+		reportedWorkspace := reportWorkspace{
+			Name:     workspaceName,
+			Before:   workspace.SeverityDist,
+			After:    workspace.AfterUpgradeSeverityDist,
+			Upgrades: []reportUpgrade{},
+		}
+
+		for _, dev := range []bool{false, true} {
+			patches := workspace.Patches
+			if dev {
+				patches = workspace.DevPatches
+			}
+			for _, dependency := range slices.Sorted(maps.Keys(patches)) {
+				patch := patches[dependency]
+				if len(patch.Patchable)+len(patch.Unpatchable) == 0 {
+					continue
+				}
+				name, version := splitDependencyKey(dependency)
+				recommended := patch.UpdateVersion()
+				if recommended == "" {
+					recommended = "-"
+				}
+				reportedWorkspace.Upgrades = append(reportedWorkspace.Upgrades, reportUpgrade{
+					Dependency:    name,
+					Dev:           dev,
+					Installed:     version,
+					Recommended:   recommended,
+					PatchType:     patch.IsPatchable,
+					Fixed:         len(patch.Patchable),
+					Remaining:     len(patch.Unpatchable),
+					Introduced:    len(patch.Introduced),
+					SeverityDelta: severityDelta(patch.SeverityDist, patch.AfterUpgradeSeverityDist),
+					Breaking:      patch.UpdateVersion() != "" && plan.PotentialBreaking(version, patch.UpdateVersion()),
+				})
+
+				data.Summary.VulnerableDependencies++
+				switch patch.IsPatchable {
+				case patching.FULL:
+					data.Summary.Full++
+				case patching.PARTIAL:
+					data.Summary.Partial++
+				default:
+					data.Summary.None++
+				}
+			}
+		}
+		data.Workspaces = append(data.Workspaces, reportedWorkspace)
+
+		for _, upgrade := range workspace.Plan.Upgrades {
+			action := reportAction{Workspace: workspaceName, SeverityRemoved: upgrade.SeverityRemoved - upgrade.SeverityIntroduced, Breaking: upgrade.PotentialBreaking}
+			for _, dependency := range slices.Sorted(maps.Keys(upgrade.Dependencies)) {
+				name, version := splitDependencyKey(dependency)
+				action.Upgrades = append(action.Upgrades, fmt.Sprintf("%s %s → %s", name, version, upgrade.Dependencies[dependency]))
+				if patch, ok := workspace.Patches[dependency]; ok {
+					action.Reasons = append(action.Reasons, patch.PriorityReasons...)
+				} else if patch, ok := workspace.DevPatches[dependency]; ok {
+					action.Reasons = append(action.Reasons, patch.PriorityReasons...)
+				}
+			}
+			actions = append(actions, rankedAction{action: action, score: upgrade.Score})
+		}
+
+		for _, vulnerabilityId := range slices.Sorted(maps.Keys(workspace.Vulnerabilities)) {
+			vulnerability := workspace.Vulnerabilities[vulnerabilityId]
+			if vulnerability.PatchType != patching.NONE || vulnerability.IntroductionType == patching.NewlyIntroduced {
+				continue
+			}
+			unfixable := reportUnfixable{Workspace: workspaceName, Vulnerability: vulnerabilityId}
+			for _, dependency := range slices.Sorted(maps.Keys(vulnerability.Patches)) {
+				occurence := vulnerability.Patches[dependency]
+				unfixable.Via = append(unfixable.Via, dependency)
+				for _, affected := range occurence.UnPatchedOccurences {
+					affectedKey := affected.AffectedDependency + "@" + affected.AffectedVersion
+					if !slices.Contains(unfixable.Affected, affectedKey) {
+						unfixable.Affected = append(unfixable.Affected, affectedKey)
+					}
+				}
+			}
+			data.Unfixable = append(data.Unfixable, unfixable)
+		}
+	}
+
+	slices.SortStableFunc(actions, func(a rankedAction, b rankedAction) int {
+		return cmp.Compare(b.score, a.score)
+	})
+	for i := 0; i < len(actions) && i < TOP_ACTIONS; i++ {
+		data.TopActions = append(data.TopActions, actions[i].action)
+	}
+	return data
+}
+
+// severityDelta describes how the severity counts change, e.g. "critical 1 → 0, high 2 → 1"
+func severityDelta(before patching.SeverityDist, after patching.SeverityDist) string {
+	changes := []string{}
+	for _, level := range []struct {
+		name          string
+		before, after int
+	}{
+		{"critical", before.Critical, after.Critical},
+		{"high", before.High, after.High},
+		{"medium", before.Medium, after.Medium},
+		{"low", before.Low, after.Low},
+		{"none", before.None, after.None},
+		{"unknown", before.Unknown, after.Unknown},
+	} {
+		if level.before != level.after {
+			changes = append(changes, fmt.Sprintf("%s %d → %d", level.name, level.before, level.after))
+		}
+	}
+	if len(changes) == 0 {
+		return "unchanged"
+	}
+	return strings.Join(changes, ", ")
+}
+
+func severityTotal(dist patching.SeverityDist) int {
+	return dist.Critical + dist.High + dist.Medium + dist.Low + dist.None + dist.Unknown
+}
+
+func markdownCell(value string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(value)
+}
+
+func increment(index int) int {
+	return index + 1
+}
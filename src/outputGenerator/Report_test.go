@@ -0,0 +1,68 @@
+package outputGenerator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+	vulnerabilityFinder "github.com/CodeClarityCE/plugin-sca-vuln-finder/src/types"
+	"github.com/CodeClarityCE/utility-node-semver/versions"
+)
+
+func mockReportOutput() patching.Output {
+	return patching.Output{
+		SeverityDist:             patching.SeverityDist{High: 1, Medium: 1},
+		AfterUpgradeSeverityDist: patching.SeverityDist{Medium: 1},
+		WorkSpaces: map[string]patching.Workspace{".": {
+			Patches: map[string]patching.PatchInfo{
+				"lodash@4.17.15": {
+					IsPatchable:              patching.FULL,
+					Update:                   versions.Semver{Version: "4.17.21"},
+					Patchable:                []patching.ToPatch{{Vulnerability: vulnerabilityFinder.Vulnerability{VulnerabilityId: "CVE-2020-8203"}}},
+					PriorityReasons:          []string{"fixes 1 of 1 vulnerabilities"},
+					SeverityDist:             patching.SeverityDist{High: 1},
+					AfterUpgradeSeverityDist: patching.SeverityDist{},
+				},
+				"left-pad|x@1.0.0": {
+					IsPatchable: patching.NONE,
+					Unpatchable: []patching.ToPatch{{Vulnerability: vulnerabilityFinder.Vulnerability{VulnerabilityId: "GHSA-xxxx"}}},
+				},
+			},
+			Plan: patching.RemediationPlan{Upgrades: []patching.PlannedUpgrade{
+				{Rank: 1, Dependencies: map[string]string{"lodash@4.17.15": "4.17.21"}, SeverityRemoved: 7.4, Score: 7.4},
+			}},
+			Vulnerabilities: map[string]patching.VulnerabilityPatchInfo{
+				"GHSA-xxxx": {IntroductionType: patching.ExistedBefore, PatchType: patching.NONE, Patches: map[string]patching.VulnerabilityOccurencePatchInfo{
+					"left-pad|x@1.0.0": {UnPatchedOccurences: []vulnerabilityFinder.Vulnerability{{AffectedDependency: "left-pad|x", AffectedVersion: "1.0.0"}}},
+				}},
+			},
+		}},
+	}
+}
+
+func TestMarkdownReport(t *testing.T) {
+	report, err := MarkdownReport(mockReportOutput())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"2 vulnerable direct dependencies in 1 workspaces",
+		"| 1 | . | lodash 4.17.15 → 4.17.21 | 7.4 | no | fixes 1 of 1 vulnerabilities |",
+		"| lodash | 4.17.15 | 4.17.21 | FULL | 1 | 0 | 0 | high 1 → 0 | no |",
+		`| . | GHSA-xxxx | left-pad\|x@1.0.0 | left-pad\|x@1.0.0 |`,
+	} {
+		if !strings.Contains(string(report), expected) {
+			t.Errorf("Expected the report to contain %q, got:\n%s", expected, report)
+		}
+	}
+}
+
+func TestHTMLReport(t *testing.T) {
+	report, err := HTMLReport(mockReportOutput())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(report), `<td class="FULL">FULL</td>`) || !strings.Contains(string(report), "lodash 4.17.15 → 4.17.21") {
+		t.Errorf("Unexpected report:\n%s", report)
+	}
+}
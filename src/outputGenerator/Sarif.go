@@ -0,0 +1,332 @@
+package outputGenerator
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/nvd"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+)
+
+const SARIF_VERSION = "2.1.0"
+const SARIF_SCHEMA = "https://json.schemastore.org/sarif-2.1.0.json"
+
+// SarifLog is a SARIF 2.1.0 log with a single run
+type SarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool       sarifTool     `json:"tool"`
+	Results    []sarifResult `json:"results"`
+	ColumnKind string        `json:"columnKind"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationUri string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifRule struct {
+	Id                   string             `json:"id"`
+	Name                 string             `json:"name"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	FullDescription      sarifMessage       `json:"fullDescription"`
+	HelpUri              string             `json:"helpUri"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+	Properties           sarifProperties    `json:"properties"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifProperties struct {
+	Tags             []string `json:"tags"`
+	SecuritySeverity string   `json:"security-severity,omitempty"`
+}
+
+type sarifResult struct {
+	RuleId     string          `json:"ruleId"`
+	Level      string          `json:"level"`
+	Message    sarifMessage    `json:"message"`
+	Locations  []sarifLocation `json:"locations"`
+	Fixes      []sarifFix      `json:"fixes,omitempty"`
+	Properties map[string]any  `json:"properties"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	Uri       string `json:"uri"`
+	UriBaseId string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+type sarifFix struct {
+	Description     sarifMessage          `json:"description"`
+	ArtifactChanges []sarifArtifactChange `json:"artifactChanges"`
+}
+
+type sarifArtifactChange struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Replacements     []sarifReplacement    `json:"replacements"`
+}
+
+type sarifReplacement struct {
+	DeletedRegion   sarifRegion  `json:"deletedRegion"`
+	InsertedContent sarifMessage `json:"insertedContent"`
+}
+
+// manifests are the files declaring direct dependencies, looked up in this order in a workspace
+var manifests = []string{"package.json", "pyproject.toml", "requirements.txt"}
+
+// SarifOutput converts the patching output to SARIF so that it can be uploaded to code scanning.
+// Every vulnerable direct dependency becomes a result located at its declaration in the workspace manifest,
+// read from projectRoot, and reported under its most severe vulnerability. Rules are documented from the NVD entries.
+func SarifOutput(output patching.Output, nvdEntries map[string]nvd.Entry, projectRoot string) SarifLog {
+	rules := map[string]sarifRule{}
+	results := []sarifResult{}
+
+	for _, workspaceName := range slices.Sorted(maps.Keys(output.WorkSpaces)) {
+		workspace := output.WorkSpaces[workspaceName]
+		upgrades := map[string]patching.Upgrades{}
+		for _, upgrade := range workspace.Upgrades {
+			upgrades[upgrade.Name] = upgrade
+		}
+
+		for _, patches := range []map[string]patching.PatchInfo{workspace.Patches, workspace.DevPatches} {
+			for _, dependency := range slices.Sorted(maps.Keys(patches)) {
+				patch := patches[dependency]
+				vulnerabilities := slices.Concat(patch.Patchable, patch.Unpatchable)
+				if len(vulnerabilities) == 0 {
+					continue
+				}
+
+				mostSevere := vulnerabilities[0]
+				vulnerabilityIds := []string{}
+				for _, toPatch := range vulnerabilities {
+					id := toPatch.Vulnerability.VulnerabilityId
+					if _, ok := rules[id]; !ok {
+						rules[id] = newSarifRule(toPatch, nvdEntries[id])
+					}
+					if !slices.Contains(vulnerabilityIds, id) {
+						vulnerabilityIds = append(vulnerabilityIds, id)
+					}
+					if toPatch.Vulnerability.Severity.Severity > mostSevere.Vulnerability.Severity.Severity {
+						mostSevere = toPatch
+					}
+				}
+
+				name, version := splitDependencyKey(dependency)
+				results = append(results, newSarifResult(sarifDependency{
+					Workspace:       workspaceName,
+					Name:            name,
+					Version:         version,
+					Patch:           patch,
+					Upgrade:         upgrades[name],
+					Vulnerabilities: vulnerabilityIds,
+					Rule:            rules[mostSevere.Vulnerability.VulnerabilityId],
+				}, projectRoot))
+			}
+		}
+	}
+
+	driverRules := []sarifRule{}
+	for _, id := range slices.Sorted(maps.Keys(rules)) {
+		driverRules = append(driverRules, rules[id])
+	}
+
+	return SarifLog{
+		Version: SARIF_VERSION,
+		Schema:  SARIF_SCHEMA,
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "codeclarity-patching",
+				InformationUri: "https://github.com/CodeClarityCE/plugin-sca-patching",
+				Rules:          driverRules,
+			}},
+			Results:    results,
+			ColumnKind: "unicodeCodePoints",
+		}},
+	}
+}
+
+type sarifDependency struct {
+	Workspace       string
+	Name            string
+	Version         string
+	Patch           patching.PatchInfo
+	Upgrade         patching.Upgrades
+	Vulnerabilities []string
+	Rule            sarifRule
+}
+
+func newSarifRule(toPatch patching.ToPatch, entry nvd.Entry) sarifRule {
+	id := toPatch.Vulnerability.VulnerabilityId
+	severity := toPatch.Vulnerability.Severity.Severity
+	if severity == 0 {
+		severity = entry.BaseScore
+	}
+	description := entry.Description
+	if description == "" {
+		description = fmt.Sprintf("%s affects %s", id, toPatch.Vulnerability.AffectedDependency)
+	}
+	shortDescription, _, _ := strings.Cut(description, ". ")
+
+	helpUri := "https://osv.dev/vulnerability/" + id
+	if strings.HasPrefix(id, "CVE-") {
+		helpUri = "https://nvd.nist.gov/vuln/detail/" + id
+	}
+
+	rule := sarifRule{
+		Id:                   id,
+		Name:                 id,
+		ShortDescription:     sarifMessage{Text: shortDescription},
+		FullDescription:      sarifMessage{Text: description},
+		HelpUri:              helpUri,
+		DefaultConfiguration: sarifConfiguration{Level: sarifLevel(severity)},
+		Properties:           sarifProperties{Tags: append([]string{"security"}, entry.Weaknesses...)},
+	}
+	if severity > 0 {
+		rule.Properties.SecuritySeverity = fmt.Sprintf("%.1f", severity)
+	}
+	return rule
+}
+
+func newSarifResult(dependency sarifDependency, projectRoot string) sarifResult {
+	manifest, line, column := locateDependency(projectRoot, dependency.Workspace, dependency.Name, dependency.Upgrade.OldConstraint)
+	location := sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{Uri: manifest, UriBaseId: "%SRCROOT%"},
+	}
+	if line > 0 {
+		location.Region = &sarifRegion{StartLine: line}
+	}
+
+	message := fmt.Sprintf("%s@%s is affected by %s.", dependency.Name, dependency.Version, strings.Join(dependency.Vulnerabilities, ", "))
+	upgradeVersion := dependency.Patch.UpdateVersion()
+	switch dependency.Patch.IsPatchable {
+	case patching.FULL:
+		message += fmt.Sprintf(" Upgrading to %s fixes all of them.", upgradeVersion)
+	case patching.PARTIAL:
+		message += fmt.Sprintf(" Upgrading to %s fixes %d of them.", upgradeVersion, len(dependency.Patch.Patchable))
+	default:
+		message += " No upgrade fixes them."
+	}
+
+	result := sarifResult{
+		RuleId:    dependency.Rule.Id,
+		Level:     dependency.Rule.DefaultConfiguration.Level,
+		Message:   sarifMessage{Text: message},
+		Locations: []sarifLocation{{PhysicalLocation: location}},
+		Properties: map[string]any{
+			"workspace":       dependency.Workspace,
+			"patch_type":      dependency.Patch.IsPatchable,
+			"vulnerabilities": dependency.Vulnerabilities,
+		},
+	}
+	if upgradeVersion != "" {
+		result.Properties["recommended_version"] = upgradeVersion
+	}
+
+	// A fix can only be expressed as a replacement of the constraint found in the manifest
+	if upgradeVersion != "" && column > 0 && dependency.Upgrade.NewConstraint != "" {
+		result.Fixes = []sarifFix{{
+			Description: sarifMessage{Text: fmt.Sprintf("Upgrade %s from %s to %s", dependency.Name, dependency.Version, upgradeVersion)},
+			ArtifactChanges: []sarifArtifactChange{{
+				ArtifactLocation: location.ArtifactLocation,
+				Replacements: []sarifReplacement{{
+					DeletedRegion: sarifRegion{
+						StartLine:   line,
+						StartColumn: column,
+						EndColumn:   column + len([]rune(dependency.Upgrade.OldConstraint)),
+					},
+					InsertedContent: sarifMessage{Text: dependency.Upgrade.NewConstraint},
+				}},
+			}},
+		}}
+	}
+	return result
+}
+
+func sarifLevel(severity float64) string {
+	switch {
+	case severity >= 7:
+		return "error"
+	case severity >= 4:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// locateDependency finds the manifest declaring a direct dependency in a workspace, the line of the declaration,
+// and the column of its constraint. The line and column are 0 when the manifest cannot be read.
+func locateDependency(projectRoot string, workspace string, name string, constraint string) (string, int, int) {
+	for _, manifest := range manifests {
+		content, err := os.ReadFile(filepath.Join(projectRoot, workspace, manifest))
+		if err != nil {
+			continue
+		}
+		declaration := declarationPattern(manifest, name)
+		for i, line := range strings.Split(string(content), "\n") {
+			if !declaration.MatchString(line) {
+				continue
+			}
+			column := 0
+			if constraint != "" {
+				if index := strings.Index(line, constraint); index >= 0 {
+					column = len([]rune(line[:index])) + 1
+				}
+			}
+			return path.Join(workspace, manifest), i + 1, column
+		}
+	}
+	return path.Join(workspace, manifests[0]), 0, 0
+}
+
+func declarationPattern(manifest string, name string) *regexp.Regexp {
+	if manifest == "package.json" {
+		return regexp.MustCompile(`"` + regexp.QuoteMeta(name) + `"\s*:`)
+	}
+	// requirements.txt lines, PEP 621 dependency arrays and Poetry tables
+	return regexp.MustCompile(`(?i)^\s*["']?` + regexp.QuoteMeta(name) + `\s*(\[|[=<>~!^;@ "']|$)`)
+}
+
+// splitDependencyKey splits the "name@version" keys of the output
+func splitDependencyKey(key string) (string, string) {
+	index := strings.LastIndex(key, "@")
+	if index <= 0 {
+		return key, ""
+	}
+	return key[:index], key[index+1:]
+}
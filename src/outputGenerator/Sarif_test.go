@@ -0,0 +1,60 @@
+package outputGenerator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/nvd"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+	vulnerabilityFinder "github.com/CodeClarityCE/plugin-sca-vuln-finder/src/types"
+	"github.com/CodeClarityCE/utility-node-semver/versions"
+)
+
+func TestSarifOutput(t *testing.T) {
+	projectRoot := t.TempDir()
+	manifest := "{\n  \"name\": \"app\",\n  \"dependencies\": {\n    \"lodash\": \"^4.17.15\"\n  }\n}\n"
+	if err := os.WriteFile(filepath.Join(projectRoot, "package.json"), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output := patching.Output{WorkSpaces: map[string]patching.Workspace{
+		".": {
+			Patches: map[string]patching.PatchInfo{
+				"lodash@4.17.15": {
+					IsPatchable: patching.FULL,
+					Update:      versions.Semver{Version: "4.17.21"},
+					Patchable: []patching.ToPatch{{Vulnerability: vulnerabilityFinder.Vulnerability{
+						VulnerabilityId:    "CVE-2020-8203",
+						AffectedDependency: "lodash",
+						Severity:           vulnerabilityFinder.VulnerabilityMatchSeverity{Severity: 7.4},
+					}}},
+				},
+			},
+			Upgrades: []patching.Upgrades{{Name: "lodash", OldConstraint: "^4.17.15", NewConstraint: "^4.17.21"}},
+		},
+	}}
+	entries := map[string]nvd.Entry{"CVE-2020-8203": {Description: "Prototype pollution in zipObjectDeep. More details.", Weaknesses: []string{"CWE-1321"}}}
+
+	log := SarifOutput(output, entries, projectRoot)
+
+	rules := log.Runs[0].Tool.Driver.Rules
+	if len(rules) != 1 || rules[0].ShortDescription.Text != "Prototype pollution in zipObjectDeep" || rules[0].Properties.SecuritySeverity != "7.4" {
+		t.Errorf("Unexpected rules %+v", rules)
+	}
+	results := log.Runs[0].Results
+	if len(results) != 1 || results[0].Level != "error" {
+		t.Fatalf("Unexpected results %+v", results)
+	}
+	location := results[0].Locations[0].PhysicalLocation
+	if location.ArtifactLocation.Uri != "package.json" || location.Region == nil || location.Region.StartLine != 4 {
+		t.Errorf("Unexpected location %+v", location)
+	}
+	if len(results[0].Fixes) != 1 {
+		t.Fatalf("Expected a fix, got %+v", results[0].Fixes)
+	}
+	replacement := results[0].Fixes[0].ArtifactChanges[0].Replacements[0]
+	if replacement.DeletedRegion.StartColumn != 16 || replacement.InsertedContent.Text != "^4.17.21" {
+		t.Errorf("Unexpected replacement %+v", replacement)
+	}
+}
@@ -1,6 +1,7 @@
 package patch
 
 import (
+	"maps"
 	"slices"
 
 	sbomTypes "github.com/CodeClarityCE/plugin-sbom-javascript/src/types/sbom/js"
@@ -8,13 +9,15 @@ import (
 	vulnerabilityFinder "github.com/CodeClarityCE/plugin-sca-vuln-finder/src/types"
 )
 
-func retrieveTopLevelDependenciesToPatch(sbom sbomTypes.WorkSpace, vulns vulnerabilityFinder.Workspace) (map[string][]patching.ToPatch, map[string][]patching.ToPatch) {
-
-	return addDependenciesToPatch(sbom.Start.Dependencies, sbom, vulns), addDependenciesToPatch(sbom.Start.DevDependencies, sbom, vulns)
-
+// retrieveTopLevelDependenciesToPatch returns the vulnerable dependencies and devDependencies of a workspace,
+// along with the vulnerabilities hidden by suppressions
+func (patcher Patcher) retrieveTopLevelDependenciesToPatch(sbom sbomTypes.WorkSpace, vulns vulnerabilityFinder.Workspace) (map[string][]patching.ToPatch, map[string][]patching.ToPatch, []patching.SuppressedVulnerability) {
+	toPatch, suppressed := patcher.addDependenciesToPatch(sbom.Start.Dependencies, sbom, vulns, false)
+	devToPatch, devSuppressed := patcher.addDependenciesToPatch(sbom.Start.DevDependencies, sbom, vulns, true)
+	return toPatch, devToPatch, append(suppressed, devSuppressed...)
 }
 
-func recursiveFindDependenciesToPatch(version sbomTypes.Versions, sbom sbomTypes.WorkSpace, vulnerabilities []vulnerabilityFinder.Vulnerability, toPatch []patching.ToPatch, path []string) []patching.ToPatch {
+func (patcher Patcher) recursiveFindDependenciesToPatch(version sbomTypes.Versions, sbom sbomTypes.WorkSpace, vulnerabilities []vulnerabilityFinder.Vulnerability, toPatch []patching.ToPatch, path []string) []patching.ToPatch {
 	if slices.Contains(path, version.Key) {
 		return toPatch
 	}
@@ -24,7 +27,10 @@ func recursiveFindDependenciesToPatch(version sbomTypes.Versions, sbom sbomTypes
 	new_path = append(new_path, version.Key)
 
 	for _, vulnerability := range vulnerabilities {
-		if version.Key == vulnerability.AffectedDependency+"@"+vulnerability.AffectedVersion {
+		if !patcher.aboveSeverityThreshold(vulnerability) {
+			continue
+		}
+		if version.Key == patcher.Ecosystem.Key(vulnerability.AffectedDependency, vulnerability.AffectedVersion) {
 			toPatch = append(toPatch, patching.ToPatch{
 				DependencyName:    vulnerability.AffectedDependency,
 				DependencyVersion: vulnerability.AffectedVersion,
@@ -33,24 +39,61 @@ func recursiveFindDependenciesToPatch(version sbomTypes.Versions, sbom sbomTypes
 			})
 		}
 	}
-	for dependency_name, dependency_version := range version.Dependencies {
-		version = sbom.Dependencies[dependency_name][dependency_version]
-		toPatch = recursiveFindDependenciesToPatch(version, sbom, vulnerabilities, toPatch, new_path)
+	for _, dependency_name := range slices.Sorted(maps.Keys(version.Dependencies)) {
+		dependency := sbom.Dependencies[dependency_name][version.Dependencies[dependency_name]]
+		toPatch = patcher.recursiveFindDependenciesToPatch(dependency, sbom, vulnerabilities, toPatch, new_path)
 	}
 	return toPatch
 }
 
-func addDependenciesToPatch(dependencies []sbomTypes.WorkSpaceDependency, sbom sbomTypes.WorkSpace, vulns vulnerabilityFinder.Workspace) map[string][]patching.ToPatch {
+func (patcher Patcher) addDependenciesToPatch(dependencies []sbomTypes.WorkSpaceDependency, sbom sbomTypes.WorkSpace, vulns vulnerabilityFinder.Workspace, dev bool) (map[string][]patching.ToPatch, []patching.SuppressedVulnerability) {
 	toPatch := make(map[string][]patching.ToPatch)
+	suppressed := []patching.SuppressedVulnerability{}
 	for _, dependency := range dependencies {
 
 		version := sbom.Dependencies[dependency.Name][dependency.Version]
 		var toPatchArray []patching.ToPatch
 
-		res := recursiveFindDependenciesToPatch(version, sbom, vulns.Vulnerabilities, toPatchArray, []string{})
+		res := patcher.recursiveFindDependenciesToPatch(version, sbom, vulns.Vulnerabilities, toPatchArray, []string{})
+		res, hidden := patcher.applySuppressions(res, dev)
+		suppressed = append(suppressed, hidden...)
 		if len(res) > 0 {
-			toPatch[dependency.Name+"@"+dependency.Version] = res
+			toPatch[patcher.Ecosystem.Key(dependency.Name, dependency.Version)] = res
 		}
 	}
-	return toPatch
+	return toPatch, suppressed
+}
+
+// applySuppressions removes the vulnerabilities hidden by a suppression, matching them against their import path
+func (patcher Patcher) applySuppressions(toPatch []patching.ToPatch, dev bool) ([]patching.ToPatch, []patching.SuppressedVulnerability) {
+	kept := []patching.ToPatch{}
+	suppressed := []patching.SuppressedVulnerability{}
+	for _, item := range toPatch {
+		names := []string{}
+		for _, key := range item.Path {
+			name, _ := patcher.Ecosystem.SplitKey(key)
+			names = append(names, name)
+		}
+		suppression, ok := patcher.suppression(item.Vulnerability.VulnerabilityId, item.DependencyName, names)
+		if !ok {
+			kept = append(kept, item)
+			continue
+		}
+		suppressed = append(suppressed, patching.SuppressedVulnerability{
+			VulnerabilityId:  item.Vulnerability.VulnerabilityId,
+			Dependency:       patcher.Ecosystem.Key(item.DependencyName, item.DependencyVersion),
+			DirectDependency: item.Path[0],
+			Dev:              dev,
+			Justification:    suppression.Justification,
+			Expires:          suppression.Expires,
+		})
+	}
+	return kept, suppressed
+}
+
+// aboveSeverityThreshold reports whether a vulnerability is severe enough to be patched under the upgrade policy.
+// Vulnerabilities without a score are always kept.
+func (patcher Patcher) aboveSeverityThreshold(vulnerability vulnerabilityFinder.Vulnerability) bool {
+	severity := vulnerability.Severity.Severity
+	return severity == 0 || severity >= patcher.UpgradePolicy.SeverityThreshold
 }
@@ -0,0 +1,326 @@
+package patch
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+	"github.com/CodeClarityCE/utility-types/exceptions"
+)
+
+// maxGroupSize bounds the number of direct dependencies searched jointly, larger groups are reported
+const maxGroupSize = 10
+
+// maxCandidatesPerDependency bounds the number of versions scanned for each direct dependency of a group,
+// the preferred ones are kept and the cut is reported
+const maxCandidatesPerDependency = 5
+
+// candidateOption is a version a direct dependency can be moved to,
+// along with the shared vulnerabilities it still carries.
+// The first option of a dependency is always its installed version.
+type candidateOption struct {
+	Version         string
+	Vulnerabilities []string
+	Score           int
+}
+
+// vulnerabilityKey identifies a vulnerability on a package regardless of the version pulled in
+func vulnerabilityKey(toPatch patching.ToPatch) string {
+	return toPatch.Vulnerability.VulnerabilityId + ":" + toPatch.DependencyName
+}
+
+// findCoordinatedUpgrades searches for the smallest sets of direct dependency upgrades
+// that clear the vulnerabilities several direct dependencies pull in through
+// a common transitive dependency, which no individual upgrade can remove.
+func (patcher Patcher) findCoordinatedUpgrades(dependenciesToPatch map[string][]patching.ToPatch, patches map[string]patching.PatchInfo) []patching.UpgradeGroup {
+	groups := []patching.UpgradeGroup{}
+
+	// Ignored dependencies are never upgraded, so they cannot be part of a group
+	upgradable := map[string][]patching.ToPatch{}
+	for dependency, toPatch := range dependenciesToPatch {
+		if name, _ := patcher.Ecosystem.SplitKey(dependency); patcher.ignoreRule(name) == "" {
+			upgradable[dependency] = toPatch
+		}
+	}
+	dependenciesToPatch = upgradable
+
+	shared := sharedVulnerabilities(dependenciesToPatch, patches)
+	for _, group := range groupDependencies(shared) {
+		if len(group) < 2 {
+			continue
+		}
+		if len(group) > maxGroupSize {
+			patcher.Errors.Add(patching.AnalysisError{
+				Code:      exceptions.GENERIC_ERROR,
+				Severity:  patching.SEVERITY_WARNING,
+				Detail:    fmt.Sprintf("No coordinated upgrade was searched for %d direct dependencies sharing vulnerabilities, more than the %d searched jointly: %s", len(group), maxGroupSize, strings.Join(group, ", ")),
+				Workspace: patcher.workspace,
+			})
+			continue
+		}
+
+		targets := []string{}
+		for vulnerability, carriers := range shared {
+			if slices.Contains(group, carriers[0]) {
+				targets = append(targets, vulnerability)
+			}
+		}
+		slices.Sort(targets)
+
+		options := map[string][]candidateOption{}
+		for _, dependency := range group {
+			options[dependency] = patcher.collectCandidateOptions(dependency, dependenciesToPatch[dependency], patches[dependency], targets)
+		}
+
+		upgrades, fixed := searchSmallestUpgradeSet(group, options, targets)
+		if len(upgrades) < 2 {
+			continue
+		}
+		remaining := []string{}
+		for _, target := range targets {
+			if !slices.Contains(fixed, target) {
+				remaining = append(remaining, target)
+			}
+		}
+		groups = append(groups, patching.UpgradeGroup{
+			Upgrades:        upgrades,
+			Vulnerabilities: fixed,
+			Remaining:       remaining,
+		})
+	}
+	return groups
+}
+
+// sharedVulnerabilities returns, for every vulnerability carried by at least two direct dependencies
+// and left unfixed by at least one of their individual recommendations, the sorted list of its carriers
+func sharedVulnerabilities(dependenciesToPatch map[string][]patching.ToPatch, patches map[string]patching.PatchInfo) map[string][]string {
+	carriers := map[string][]string{}
+	unfixed := map[string]bool{}
+	for dependency, toPatch := range dependenciesToPatch {
+		patch := patches[dependency]
+		for _, vulnerability := range toPatch {
+			key := vulnerabilityKey(vulnerability)
+			if !slices.Contains(carriers[key], dependency) {
+				carriers[key] = append(carriers[key], dependency)
+			}
+			if patch.IsPatchable != patching.FULL {
+				unfixed[key] = true
+			}
+		}
+	}
+
+	shared := map[string][]string{}
+	for key, dependencies := range carriers {
+		if len(dependencies) >= 2 && unfixed[key] {
+			slices.Sort(dependencies)
+			shared[key] = dependencies
+		}
+	}
+	return shared
+}
+
+// groupDependencies partitions the carriers of shared vulnerabilities into connected groups
+func groupDependencies(shared map[string][]string) [][]string {
+	parent := map[string]string{}
+	var find func(string) string
+	find = func(dependency string) string {
+		if parent[dependency] == dependency {
+			return dependency
+		}
+		parent[dependency] = find(parent[dependency])
+		return parent[dependency]
+	}
+	for _, carriers := range shared {
+		for _, carrier := range carriers {
+			if _, ok := parent[carrier]; !ok {
+				parent[carrier] = carrier
+			}
+		}
+		for _, carrier := range carriers[1:] {
+			parent[find(carrier)] = find(carriers[0])
+		}
+	}
+
+	members := map[string][]string{}
+	for dependency := range parent {
+		root := find(dependency)
+		members[root] = append(members[root], dependency)
+	}
+	groups := [][]string{}
+	for _, group := range members {
+		slices.Sort(group)
+		groups = append(groups, group)
+	}
+	slices.SortFunc(groups, func(a []string, b []string) int {
+		return slices.Compare(a, b)
+	})
+	return groups
+}
+
+// collectCandidateOptions scans the preferred versions of a direct dependency, at most maxCandidatesPerDependency of them,
+// and records which of the targeted shared vulnerabilities each one still carries
+func (patcher Patcher) collectCandidateOptions(dependency string, toPatch []patching.ToPatch, patch patching.PatchInfo, targets []string) []candidateOption {
+	name, version := patcher.Ecosystem.SplitKey(dependency)
+	options := []candidateOption{{
+		Version:         version,
+		Vulnerabilities: targetedVulnerabilities(toPatch, targets),
+		Score:           len(toPatch),
+	}}
+
+	versions, err := patcher.getPossibleVersions(name, version)
+	if err != nil {
+		return options
+	}
+	if len(versions) > maxCandidatesPerDependency {
+		patcher.Errors.Add(patching.AnalysisError{
+			Code:      exceptions.GENERIC_ERROR,
+			Severity:  patching.SEVERITY_WARNING,
+			Detail:    fmt.Sprintf("Only %d of the %d versions %s can be upgraded to were searched for coordinated upgrades", maxCandidatesPerDependency, len(versions), dependency),
+			Workspace: patcher.workspace,
+		})
+		versions = versions[:maxCandidatesPerDependency]
+	}
+	if recommended := patch.UpdateVersion(); recommended != "" && !slices.Contains(versions, recommended) {
+		versions = append(versions, recommended)
+	}
+
+	for _, candidate := range versions {
+		score, vulnerabilities, err := patcher.scanCandidate(name, candidate)
+		if err != nil {
+			continue
+		}
+		options = append(options, candidateOption{
+			Version:         candidate,
+			Vulnerabilities: targetedVulnerabilities(vulnerabilities, targets),
+			Score:           score,
+		})
+	}
+	return options
+}
+
+func targetedVulnerabilities(vulnerabilities []patching.ToPatch, targets []string) []string {
+	targeted := []string{}
+	for _, vulnerability := range vulnerabilities {
+		key := vulnerabilityKey(vulnerability)
+		if slices.Contains(targets, key) && !slices.Contains(targeted, key) {
+			targeted = append(targeted, key)
+		}
+	}
+	return targeted
+}
+
+// searchSmallestUpgradeSet searches the versions of the dependencies of a group for the upgrades
+// clearing the most targeted vulnerabilities, preferring fewer upgrades, then versions carrying
+// fewer vulnerabilities. It returns them as a map of direct dependency to version,
+// along with the vulnerabilities they clear.
+// The search runs depth first over the options of every dependency, skipping the versions
+// another version of the same dependency beats, and abandons branches that cannot beat the best set found.
+func searchSmallestUpgradeSet(group []string, options map[string][]candidateOption, targets []string) (map[string]string, []string) {
+	choices := make([][]candidateOption, len(group))
+	for i, dependency := range group {
+		choices[i] = undominatedOptions(options[dependency])
+	}
+
+	best := upgradeSet{cleared: -1}
+	current := make([]int, len(group))
+	var search func(i int, carried map[string]int, upgrades int, score int)
+	search = func(i int, carried map[string]int, upgrades int, score int) {
+		// Vulnerabilities only get carried by more dependencies as the search goes deeper
+		reachable := len(targets) - len(carried)
+		if reachable < best.cleared || (reachable == best.cleared && (upgrades > best.upgrades || (upgrades == best.upgrades && score >= best.score))) {
+			return
+		}
+		if i == len(group) {
+			best = upgradeSet{choices: slices.Clone(current), cleared: reachable, upgrades: upgrades, score: score}
+			return
+		}
+		for index, option := range choices[i] {
+			for _, vulnerability := range option.Vulnerabilities {
+				carried[vulnerability]++
+			}
+			current[i] = index
+			if index == 0 {
+				search(i+1, carried, upgrades, score)
+			} else {
+				search(i+1, carried, upgrades+1, score+option.Score)
+			}
+			for _, vulnerability := range option.Vulnerabilities {
+				if carried[vulnerability]--; carried[vulnerability] == 0 {
+					delete(carried, vulnerability)
+				}
+			}
+		}
+	}
+	search(0, map[string]int{}, 0, 0)
+
+	if best.cleared <= 0 {
+		return map[string]string{}, []string{}
+	}
+	upgrades := map[string]string{}
+	carried := map[string]bool{}
+	for i, dependency := range group {
+		option := choices[i][best.choices[i]]
+		if best.choices[i] != 0 {
+			upgrades[dependency] = option.Version
+		}
+		for _, vulnerability := range option.Vulnerabilities {
+			carried[vulnerability] = true
+		}
+	}
+	fixed := []string{}
+	for _, target := range targets {
+		if !carried[target] {
+			fixed = append(fixed, target)
+		}
+	}
+	return upgrades, fixed
+}
+
+// upgradeSet is a choice of option for every dependency of a group, with what it achieves
+type upgradeSet struct {
+	choices  []int
+	cleared  int
+	upgrades int
+	score    int
+}
+
+// undominatedOptions keeps the installed version, followed by the versions no other version beats,
+// that is carrying a subset of their targeted vulnerabilities with a score at most as high.
+// Versions keep their order, so ties go to the preferred version.
+func undominatedOptions(options []candidateOption) []candidateOption {
+	if len(options) == 0 {
+		return options
+	}
+	kept := []candidateOption{options[0]}
+	for i, option := range options[1:] {
+		dominated := false
+		for j, other := range options[1:] {
+			if i == j || !isSubset(other.Vulnerabilities, option.Vulnerabilities) || other.Score > option.Score {
+				continue
+			}
+			// Equal options are only dominated by the ones before them
+			if !isSubset(option.Vulnerabilities, other.Vulnerabilities) || other.Score < option.Score || j < i {
+				dominated = true
+				break
+			}
+		}
+		if !dominated {
+			kept = append(kept, option)
+		}
+	}
+	// Versions clearing more come first, so that good sets are found early and bound the search
+	slices.SortStableFunc(kept[1:], func(a candidateOption, b candidateOption) int {
+		return len(a.Vulnerabilities) - len(b.Vulnerabilities)
+	})
+	return kept
+}
+
+func isSubset(subset []string, set []string) bool {
+	for _, element := range subset {
+		if !slices.Contains(set, element) {
+			return false
+		}
+	}
+	return true
+}
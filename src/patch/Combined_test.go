@@ -0,0 +1,153 @@
+package patch
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	sbomTypes "github.com/CodeClarityCE/plugin-sbom-javascript/src/types/sbom/js"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/ecosystem"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/knowledgeStore"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+	vulnerabilityFinder "github.com/CodeClarityCE/plugin-sca-vuln-finder/src/types"
+)
+
+func TestSearchSmallestUpgradeSet(t *testing.T) {
+	// a and b both pull in minimist, c pulls in a vulnerable qs that only its own upgrade removes
+	group := []string{"a@1.0.0", "b@1.0.0", "c@1.0.0"}
+	targets := []string{"CVE-2021-44906:minimist", "CVE-2022-24999:qs"}
+	options := map[string][]candidateOption{
+		"a@1.0.0": {
+			{Version: "1.0.0", Vulnerabilities: []string{"CVE-2021-44906:minimist"}},
+			{Version: "1.1.0", Vulnerabilities: []string{"CVE-2021-44906:minimist"}, Score: 1},
+			{Version: "2.0.0", Vulnerabilities: []string{}, Score: 0},
+		},
+		"b@1.0.0": {
+			{Version: "1.0.0", Vulnerabilities: []string{"CVE-2021-44906:minimist", "CVE-2022-24999:qs"}},
+			{Version: "1.2.0", Vulnerabilities: []string{"CVE-2022-24999:qs"}, Score: 1},
+		},
+		"c@1.0.0": {
+			{Version: "1.0.0", Vulnerabilities: []string{}},
+			{Version: "1.0.1", Vulnerabilities: []string{}, Score: 0},
+		},
+	}
+
+	upgrades, fixed := searchSmallestUpgradeSet(group, options, targets)
+
+	if len(upgrades) != 2 || upgrades["a@1.0.0"] != "2.0.0" || upgrades["b@1.0.0"] != "1.2.0" {
+		t.Errorf("Expected a@2.0.0 and b@1.2.0 to be upgraded together, got %v", upgrades)
+	}
+	if !slices.Equal(fixed, []string{"CVE-2021-44906:minimist"}) {
+		t.Errorf("Expected only the minimist vulnerability to be fixed, got %v", fixed)
+	}
+}
+
+func TestSearchSmallestUpgradeSetWithoutFix(t *testing.T) {
+	group := []string{"a@1.0.0", "b@1.0.0"}
+	targets := []string{"CVE-2021-44906:minimist"}
+	options := map[string][]candidateOption{
+		"a@1.0.0": {
+			{Version: "1.0.0", Vulnerabilities: targets},
+			{Version: "1.1.0", Vulnerabilities: targets},
+		},
+		"b@1.0.0": {
+			{Version: "1.0.0", Vulnerabilities: targets},
+		},
+	}
+
+	upgrades, fixed := searchSmallestUpgradeSet(group, options, targets)
+
+	if len(upgrades) != 0 || len(fixed) != 0 {
+		t.Errorf("Expected no upgrade group, got %v fixing %v", upgrades, fixed)
+	}
+}
+
+func TestSearchSmallestUpgradeSetWithOtherVersion(t *testing.T) {
+	// The version of a carrying the fewest vulnerabilities keeps qs, only the other one clears it with c
+	group := []string{"a@1.0.0", "b@1.0.0", "c@1.0.0"}
+	targets := []string{"CVE-2021-44906:minimist", "CVE-2022-24999:qs"}
+	options := map[string][]candidateOption{
+		"a@1.0.0": {
+			{Version: "1.0.0", Vulnerabilities: targets},
+			{Version: "1.1.0", Vulnerabilities: []string{"CVE-2022-24999:qs"}, Score: 1},
+			{Version: "2.0.0", Vulnerabilities: []string{"CVE-2021-44906:minimist"}, Score: 2},
+		},
+		"b@1.0.0": {
+			{Version: "1.0.0", Vulnerabilities: []string{"CVE-2021-44906:minimist"}},
+			{Version: "1.1.0", Vulnerabilities: []string{"CVE-2021-44906:minimist"}, Score: 1},
+		},
+		"c@1.0.0": {
+			{Version: "1.0.0", Vulnerabilities: []string{"CVE-2022-24999:qs"}},
+			{Version: "1.0.1", Vulnerabilities: []string{}, Score: 0},
+		},
+	}
+
+	upgrades, fixed := searchSmallestUpgradeSet(group, options, targets)
+
+	if len(upgrades) != 2 || upgrades["a@1.0.0"] != "2.0.0" || upgrades["c@1.0.0"] != "1.0.1" {
+		t.Errorf("Expected a@2.0.0 and c@1.0.1 to be upgraded together, got %v", upgrades)
+	}
+	if !slices.Equal(fixed, []string{"CVE-2022-24999:qs"}) {
+		t.Errorf("Expected the qs vulnerability to be fixed, got %v", fixed)
+	}
+}
+
+func TestOversizedGroupIsReported(t *testing.T) {
+	dependenciesToPatch := map[string][]patching.ToPatch{}
+	patches := map[string]patching.PatchInfo{}
+	for i := range maxGroupSize + 1 {
+		dependency := fmt.Sprintf("dependency-%d@1.0.0", i)
+		dependenciesToPatch[dependency] = []patching.ToPatch{mockToPatch("CVE-2021-44906", "minimist")}
+		patches[dependency] = patching.PatchInfo{IsPatchable: patching.NONE}
+	}
+
+	patcher := InitializePatcher(types.UpgradePolicy{}, ecosystem.Npm{}, sbomTypes.Output{}, vulnerabilityFinder.Output{})
+	groups := patcher.findCoordinatedUpgrades(dependenciesToPatch, patches)
+
+	if len(groups) != 0 {
+		t.Errorf("Expected no upgrade group, got %v", groups)
+	}
+	if entries := patcher.Errors.Entries(); len(entries) != 1 || entries[0].Severity != patching.SEVERITY_WARNING {
+		t.Errorf("Expected the oversized group to be reported, got %v", entries)
+	}
+}
+
+func TestIgnoredDependencyLeftOutOfGroups(t *testing.T) {
+	// a, b and c pull in a vulnerable minimist that only their 2.0.0 releases drop together, c is ignored
+	store := knowledgeStore.NewSnapshot()
+	store.PackageVersions["npm:minimist"] = []string{"1.2.5", "1.2.6"}
+	store.ReleaseVulnerabilities["npm:minimist@1.2.6"] = []string{}
+	dependenciesToPatch := map[string][]patching.ToPatch{}
+	patches := map[string]patching.PatchInfo{}
+	for _, name := range []string{"a", "b", "c"} {
+		store.PackageVersions["npm:"+name] = []string{"1.0.0", "2.0.0"}
+		store.Releases["npm:"+name+"@2.0.0"] = knowledgeStore.SnapshotRelease{Dependencies: map[string]string{"minimist": "1.2.6"}}
+		store.ReleaseVulnerabilities["npm:"+name+"@2.0.0"] = []string{}
+		dependenciesToPatch[name+"@1.0.0"] = []patching.ToPatch{mockToPatch("CVE-2021-44906", "minimist")}
+		patches[name+"@1.0.0"] = patching.PatchInfo{IsPatchable: patching.NONE}
+	}
+	upgradePolicy := types.UpgradePolicy{MaxMajorJump: patching.UNLIMITED_MAJOR_JUMP, Rules: patching.PolicyRules{Ignore: []string{"c"}}}
+	patcher := InitializePatcher(upgradePolicy, ecosystem.Npm{Store: store}, sbomTypes.Output{}, vulnerabilityFinder.Output{})
+
+	groups := patcher.findCoordinatedUpgrades(dependenciesToPatch, patches)
+
+	if len(groups) != 1 || len(groups[0].Upgrades) != 2 || groups[0].Upgrades["a@1.0.0"] != "2.0.0" || groups[0].Upgrades["b@1.0.0"] != "2.0.0" {
+		t.Errorf("Expected a and b to be upgraded together without c, got %v", groups)
+	}
+}
+
+func TestCandidateCutIsReported(t *testing.T) {
+	store := knowledgeStore.NewSnapshot()
+	store.PackageVersions["npm:a"] = []string{"1.0.0"}
+	for i := 1; i <= maxCandidatesPerDependency+2; i++ {
+		store.PackageVersions["npm:a"] = append(store.PackageVersions["npm:a"], fmt.Sprintf("1.0.%d", i))
+	}
+
+	patcher := InitializePatcher(types.UpgradePolicy{MaxMajorJump: patching.UNLIMITED_MAJOR_JUMP}, ecosystem.Npm{Store: store}, sbomTypes.Output{}, vulnerabilityFinder.Output{})
+	patcher.collectCandidateOptions("a@1.0.0", []patching.ToPatch{}, patching.PatchInfo{IsPatchable: patching.NONE}, []string{})
+
+	if entries := patcher.Errors.Entries(); len(entries) != 1 || entries[0].Severity != patching.SEVERITY_WARNING {
+		t.Errorf("Expected the versions left out of the search to be reported, got %v", entries)
+	}
+}
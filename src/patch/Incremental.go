@@ -0,0 +1,110 @@
+package patch
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/knowledgeStore"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+)
+
+// fingerprint identifies what the patch of a direct dependency is computed from: its vulnerable
+// dependencies, the policy, the vulnerability data and the release data of the dependency,
+// that is its published versions and the transitive dependencies its candidate versions resolve to.
+// Releases of other packages do not change it, so entries survive the constant updates of the registries.
+// An empty fingerprint is never carried forward.
+func (patcher Patcher) fingerprint(dependency string, toPatch []patching.ToPatch) string {
+	if patcher.VulnerabilityDataVersion == "" {
+		return ""
+	}
+	name, version := patcher.Ecosystem.SplitKey(dependency)
+	versions, err := patcher.Ecosystem.Versions(name)
+	if err != nil {
+		return ""
+	}
+	releases, err := patcher.releaseData(name, version)
+	if err != nil {
+		return ""
+	}
+	content, err := json.Marshal(struct {
+		Dependency               string                 `json:"dependency"`
+		ToPatch                  []patching.ToPatch     `json:"to_patch"`
+		Policy                   patching.UpgradePolicy `json:"policy"`
+		Suppressions             []patching.Suppression `json:"suppressions"`
+		Versions                 []string               `json:"versions"`
+		Releases                 candidateReleases      `json:"releases"`
+		VulnerabilityDataVersion string                 `json:"vulnerability_data_version"`
+	}{dependency, toPatch, patcher.UpgradePolicy, patcher.Suppressions, slices.Sorted(slices.Values(versions)), releases, patcher.VulnerabilityDataVersion})
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(content))
+}
+
+// candidateReleases holds what the transitive dependencies of the candidate versions of a dependency
+// are resolved from: the constraints of every candidate and the versions of the packages they name
+type candidateReleases struct {
+	Dependencies    map[string]map[string]string `json:"dependencies"`
+	DevDependencies map[string]map[string]string `json:"dev_dependencies"`
+	Versions        map[string][]string          `json:"versions"`
+}
+
+// releaseData collects the candidateReleases of a direct dependency installed at version.
+// Releases and packages missing from the knowledge are left out, their absence is part of the data.
+func (patcher Patcher) releaseData(name string, version string) (candidateReleases, error) {
+	releases := candidateReleases{
+		Dependencies:    map[string]map[string]string{},
+		DevDependencies: map[string]map[string]string{},
+		Versions:        map[string][]string{},
+	}
+	candidates, err := patcher.getPossibleVersions(name, version)
+	if err != nil {
+		return releases, err
+	}
+	for _, candidate := range candidates {
+		dependencies, devDependencies, err := patcher.Ecosystem.Dependencies(name, candidate)
+		if errors.Is(err, knowledgeStore.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return releases, err
+		}
+		releases.Dependencies[candidate] = dependencies
+		releases.DevDependencies[candidate] = devDependencies
+		for _, constraints := range []map[string]string{dependencies, devDependencies} {
+			for dependency := range constraints {
+				if _, ok := releases.Versions[dependency]; ok {
+					continue
+				}
+				versions, err := patcher.Ecosystem.Versions(dependency)
+				if errors.Is(err, knowledgeStore.ErrNotFound) {
+					continue
+				}
+				if err != nil {
+					return releases, err
+				}
+				releases.Versions[dependency] = slices.Sorted(slices.Values(versions))
+			}
+		}
+	}
+	return releases, nil
+}
+
+// previousPatch returns the entry of a direct dependency in the previous result of the project,
+// if it was computed from the same fingerprint
+func (patcher Patcher) previousPatch(dependency string, fingerprint string) (patching.PatchInfo, bool) {
+	if patcher.Previous == nil || fingerprint == "" {
+		return patching.PatchInfo{}, false
+	}
+	workspace := patcher.Previous.WorkSpaces[patcher.workspace]
+	for _, patches := range []map[string]patching.PatchInfo{workspace.Patches, workspace.DevPatches} {
+		if patch, ok := patches[dependency]; ok && patch.Fingerprint == fingerprint {
+			patch.Recomputed = false
+			return patch, true
+		}
+	}
+	return patching.PatchInfo{}, false
+}
@@ -0,0 +1,405 @@
+package patch
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	sbomTypes "github.com/CodeClarityCE/plugin-sbom-javascript/src/types/sbom/js"
+	types "github.com/CodeClarityCE/plugin-sca-patching/src/types"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+)
+
+// dependencyUsage describes how a workspace uses a direct dependency
+type dependencyUsage struct {
+	Name               string
+	Installed          string
+	OriginalConstraint string
+	Constraint         string
+	Recommended        string
+	// Patch is the patch of the workspace for the dependency, nil when it has none
+	Patch *patching.PatchInfo
+}
+
+// AlignWorkspaces lines up the upgrades of the direct dependencies shared by several workspaces
+// on a single version, so that package managers can keep hoisting them.
+// The chosen version is the one that the most workspaces can follow without losing a fix
+// and without leaving the constraint they would have after their own upgrade.
+// Followers have their recommendation and manifest upgrade moved to it, the other workspaces
+// keep their own recommendation and are reported as blocked.
+func (patcher Patcher) AlignWorkspaces(workspaces map[string]patching.Workspace) []patching.AlignedUpgrade {
+	aligned := []patching.AlignedUpgrade{}
+	dependents := patcher.workspaceDependents()
+
+	usages := patcher.collectDependencyUsages(workspaces)
+	names := make([]string, 0, len(usages))
+	for name := range usages {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		usage := usages[name]
+		if len(usage) < 2 {
+			continue
+		}
+
+		candidates := []string{}
+		for _, workspaceUsage := range usage {
+			if workspaceUsage.Recommended != "" && !slices.Contains(candidates, workspaceUsage.Recommended) {
+				candidates = append(candidates, workspaceUsage.Recommended)
+			}
+		}
+		if len(candidates) == 0 || patcher.isAlreadyAligned(usage) {
+			continue
+		}
+
+		version, blocked, realigned := patcher.selectAlignedVersion(name, candidates, usage)
+		alignedUpgrade := patching.AlignedUpgrade{
+			Name:       name,
+			Version:    version,
+			Workspaces: []string{},
+			Dependents: []string{},
+			Blocked:    []patching.BlockedWorkspace{},
+		}
+
+		for _, workspaceKey := range sortedWorkspaceKeys(usage) {
+			workspaceUsage := usage[workspaceKey]
+			reason, isBlocked := blocked[workspaceKey]
+			if !isBlocked {
+				patcher.workspace = workspaceKey
+				workspace := workspaces[workspaceKey]
+				alignedUpgrade.Workspaces = append(alignedUpgrade.Workspaces, workspaceKey)
+				workspace.Upgrades = patcher.alignUpgrade(workspace.Upgrades, name, workspaceUsage, version)
+				if patch, ok := realigned[workspaceKey]; ok {
+					patcher.realignPatch(&workspace, workspaceUsage, patch)
+					patcher.summarizeWorkspace(&workspace)
+				}
+				workspaces[workspaceKey] = workspace
+			}
+			if isBlocked {
+				target := workspaceUsage.Recommended
+				if target == "" {
+					target = workspaceUsage.Installed
+				}
+				alignedUpgrade.Blocked = append(alignedUpgrade.Blocked, patching.BlockedWorkspace{
+					Workspace:  workspaceKey,
+					Version:    target,
+					Constraint: workspaceUsage.Constraint,
+					Reason:     reason,
+				})
+			}
+		}
+
+		// Workspaces that consume a member through an internal dependency receive the aligned version too
+		for _, workspaceKey := range alignedUpgrade.Workspaces {
+			for _, dependent := range dependents[workspaceKey] {
+				if _, ok := usage[dependent]; !ok && !slices.Contains(alignedUpgrade.Dependents, dependent) {
+					alignedUpgrade.Dependents = append(alignedUpgrade.Dependents, dependent)
+				}
+			}
+		}
+		slices.Sort(alignedUpgrade.Dependents)
+
+		aligned = append(aligned, alignedUpgrade)
+	}
+	return aligned
+}
+
+// collectDependencyUsages indexes, per direct dependency name, how each workspace uses it
+func (patcher Patcher) collectDependencyUsages(workspaces map[string]patching.Workspace) map[string]map[string]dependencyUsage {
+	usages := map[string]map[string]dependencyUsage{}
+	for workspaceKey, workspace := range patcher.Sbom.WorkSpaces {
+		dependencies := append(slices.Clone(workspace.Start.Dependencies), workspace.Start.DevDependencies...)
+		for _, dependency := range dependencies {
+			key := patcher.Ecosystem.Key(dependency.Name, dependency.Version)
+			usage := dependencyUsage{
+				Name:               dependency.Name,
+				Installed:          dependency.Version,
+				OriginalConstraint: dependency.Constraint,
+				Constraint:         dependency.Constraint,
+			}
+			if patch, ok := workspaces[workspaceKey].Patches[key]; ok {
+				usage.Recommended = patch.UpdateVersion()
+				usage.Patch = &patch
+			} else if patch, ok := workspaces[workspaceKey].DevPatches[key]; ok {
+				usage.Recommended = patch.UpdateVersion()
+				usage.Patch = &patch
+			}
+			for _, upgrade := range workspaces[workspaceKey].Upgrades {
+				if upgrade.Name == dependency.Name {
+					usage.Constraint = upgrade.NewConstraint
+				}
+			}
+			if _, ok := usages[dependency.Name]; !ok {
+				usages[dependency.Name] = map[string]dependencyUsage{}
+			}
+			usages[dependency.Name][workspaceKey] = usage
+		}
+	}
+	return usages
+}
+
+// isAlreadyAligned reports whether every workspace already ends up on the same version
+func (patcher Patcher) isAlreadyAligned(usage map[string]dependencyUsage) bool {
+	target := ""
+	for _, workspaceUsage := range usage {
+		version := workspaceUsage.Recommended
+		if version == "" {
+			version = workspaceUsage.Installed
+		}
+		if target != "" && target != version {
+			return false
+		}
+		target = version
+	}
+	return true
+}
+
+// selectAlignedVersion picks the candidate that the most workspaces can follow,
+// breaking ties with the version selection preference of the upgrade policy.
+// It returns the chosen version, the workspaces that cannot follow it with the reason why,
+// and the patches of the followers moving to it.
+func (patcher Patcher) selectAlignedVersion(name string, candidates []string, usage map[string]dependencyUsage) (string, map[string]string, map[string]patching.PatchInfo) {
+	sorted, err := patcher.Ecosystem.SortVersions(candidates)
+	if err != nil || len(sorted) == 0 {
+		sorted = candidates
+	}
+	if patcher.UpgradePolicy.VersionSelectionPreference != types.SELECT_OLDEST {
+		slices.Reverse(sorted)
+	}
+
+	bestVersion := ""
+	var bestBlocked map[string]string
+	var bestRealigned map[string]patching.PatchInfo
+	for _, candidate := range sorted {
+		blocked := map[string]string{}
+		realigned := map[string]patching.PatchInfo{}
+		// The candidate is scanned once, the first time a workspace could move to it
+		var vulnerabilities []patching.ToPatch
+		var scanErr error
+		scanned := false
+		for workspaceKey, workspaceUsage := range usage {
+			reason := patcher.cannotFollow(workspaceUsage, candidate)
+			if reason == "" && workspaceUsage.target() != candidate {
+				if !scanned {
+					_, vulnerabilities, scanErr = patcher.scanCandidate(name, candidate)
+					scanned = true
+				}
+				if scanErr != nil {
+					reason = fmt.Sprintf("%s could not be checked: %s", candidate, scanErr)
+				} else {
+					var patch patching.PatchInfo
+					patch, reason = patcher.alignedPatch(workspaceUsage, candidate, vulnerabilities)
+					if reason == "" && workspaceUsage.Patch != nil {
+						realigned[workspaceKey] = patch
+					}
+				}
+			}
+			if reason != "" {
+				blocked[workspaceKey] = reason
+			}
+		}
+		if bestBlocked == nil || len(blocked) < len(bestBlocked) {
+			bestVersion = candidate
+			bestBlocked = blocked
+			bestRealigned = realigned
+		}
+	}
+	return bestVersion, bestBlocked, bestRealigned
+}
+
+// target is the version a workspace ends up on for the dependency before the alignment
+func (usage dependencyUsage) target() string {
+	if usage.Recommended != "" {
+		return usage.Recommended
+	}
+	return usage.Installed
+}
+
+// cannotFollow returns why the policy or the constraint of a workspace prevents it from moving to version,
+// or an empty string if they do not
+func (patcher Patcher) cannotFollow(usage dependencyUsage, version string) string {
+	if rule := patcher.ignoreRule(usage.Name); rule != "" {
+		return "blocked by the policy rule " + rule
+	}
+	if rule := patcher.versionRule(usage.Name, version); rule != "" {
+		return "blocked by the policy rule " + rule
+	}
+	target := usage.target()
+	comparison, err := patcher.Ecosystem.Compare(version, target)
+	if err != nil {
+		return err.Error()
+	}
+	if comparison < 0 && (usage.Recommended != "" || !patcher.UpgradePolicy.AllowDowngrades) {
+		return fmt.Sprintf("%s is older than %s", version, target)
+	}
+	if strings.TrimSpace(usage.Constraint) == "" {
+		return ""
+	}
+	satisfies, err := patcher.Ecosystem.Satisfies(version, usage.Constraint)
+	if err != nil {
+		return err.Error()
+	}
+	if !satisfies {
+		return fmt.Sprintf("constraint %s does not admit %s", usage.Constraint, version)
+	}
+	return ""
+}
+
+// alignedPatch returns the patch of a workspace moved to version, which carries vulnerabilities.
+// The reason is not empty when the workspace would lose a fix of its own recommendation
+// or pick up vulnerabilities it leaves behind, in which case it cannot follow.
+func (patcher Patcher) alignedPatch(usage dependencyUsage, version string, vulnerabilities []patching.ToPatch) (patching.PatchInfo, string) {
+	// A workspace without a patch is not vulnerable through the dependency, anything the version carries is new
+	fixed, remaining := []string{}, []string{}
+	var patch patching.PatchInfo
+	if usage.Patch != nil {
+		patch = *usage.Patch
+		for _, vulnerability := range patch.Patchable {
+			fixed = append(fixed, vulnerabilityKey(vulnerability))
+		}
+		for _, vulnerability := range append(slices.Clone(patch.Unpatchable), patch.Introduced...) {
+			remaining = append(remaining, vulnerabilityKey(vulnerability))
+		}
+	}
+
+	lost, added := []string{}, []string{}
+	for _, vulnerability := range vulnerabilities {
+		key := vulnerabilityKey(vulnerability)
+		switch {
+		case slices.Contains(fixed, key):
+			if !slices.Contains(lost, key) {
+				lost = append(lost, key)
+			}
+		case !slices.Contains(remaining, key) && !slices.Contains(added, key):
+			added = append(added, key)
+		}
+	}
+	if len(lost) > 0 {
+		return patch, fmt.Sprintf("%s does not fix %s", version, strings.Join(lost, ", "))
+	}
+	if len(added) > 0 {
+		return patch, fmt.Sprintf("%s introduces %s", version, strings.Join(added, ", "))
+	}
+	if usage.Patch == nil {
+		return patch, ""
+	}
+
+	update, err := patcher.Ecosystem.ParseVersion(version)
+	if err != nil {
+		return patch, err.Error()
+	}
+	patch.Update = update
+	toPatch := append(slices.Clone(patch.Patchable), patch.Unpatchable...)
+	if len(vulnerabilities) == 0 {
+		patch.IsPatchable = patching.FULL
+		patch.Patchable = toPatch
+		patch.Unpatchable = []patching.ToPatch{}
+		patch.Introduced = []patching.ToPatch{}
+	} else {
+		patch.IsPatchable = patching.PARTIAL
+		patch.Introduced, patch.Unpatchable, patch.Patchable = generatePatchingResult(vulnerabilities, toPatch)
+	}
+	return patch, ""
+}
+
+// realignPatch replaces the patch of a workspace for a dependency with its aligned patch,
+// and drops the coordinated upgrades that move the dependency to another version
+func (patcher Patcher) realignPatch(workspace *patching.Workspace, usage dependencyUsage, patch patching.PatchInfo) {
+	key := patcher.Ecosystem.Key(usage.Name, usage.Installed)
+	if _, ok := workspace.Patches[key]; ok {
+		workspace.Patches[key] = patch
+	} else {
+		workspace.DevPatches[key] = patch
+	}
+	workspace.CoordinatedUpgrades = slices.DeleteFunc(workspace.CoordinatedUpgrades, func(group patching.UpgradeGroup) bool {
+		version, ok := group.Upgrades[key]
+		return ok && version != patch.UpdateVersion()
+	})
+}
+
+// alignUpgrade rewrites, or adds, the manifest upgrade of a dependency so that it targets version
+func (patcher Patcher) alignUpgrade(upgrades []patching.Upgrades, name string, usage dependencyUsage, version string) []patching.Upgrades {
+	if version == usage.Installed {
+		return upgrades
+	}
+	newConstraint, reapply, err := patcher.Ecosystem.UpdateConstraint(usage.OriginalConstraint, version)
+	if err != nil {
+		return upgrades
+	}
+	for i, upgrade := range upgrades {
+		if upgrade.Name == name {
+			upgrades[i].NewConstraint = newConstraint
+			upgrades[i].Reapply = reapply
+			return upgrades
+		}
+	}
+	return append(upgrades, patching.Upgrades{
+		Name:          name,
+		OldConstraint: usage.OriginalConstraint,
+		NewConstraint: newConstraint,
+		Reapply:       reapply,
+	})
+}
+
+// workspaceDependents maps every workspace to the workspaces that depend on it, directly or not
+func (patcher Patcher) workspaceDependents() map[string][]string {
+	direct := map[string][]string{}
+	for workspaceKey, workspace := range patcher.Sbom.WorkSpaces {
+		dependencies := append(slices.Clone(workspace.Start.Dependencies), workspace.Start.DevDependencies...)
+		for _, dependency := range dependencies {
+			if target, ok := patcher.findWorkspace(workspaceKey, dependency); ok {
+				direct[target] = append(direct[target], workspaceKey)
+			}
+		}
+	}
+
+	dependents := map[string][]string{}
+	for workspaceKey := range patcher.Sbom.WorkSpaces {
+		visited := []string{}
+		queue := slices.Clone(direct[workspaceKey])
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			if current == workspaceKey || slices.Contains(visited, current) {
+				continue
+			}
+			visited = append(visited, current)
+			queue = append(queue, direct[current]...)
+		}
+		dependents[workspaceKey] = visited
+	}
+	return dependents
+}
+
+// findWorkspace resolves a dependency declared with the workspace:, file: or link: protocols to a workspace of the SBOM
+func (patcher Patcher) findWorkspace(from string, dependency sbomTypes.WorkSpaceDependency) (string, bool) {
+	constraint := strings.TrimSpace(dependency.Constraint)
+	for _, protocol := range []string{"file:", "link:", "portal:"} {
+		if strings.HasPrefix(constraint, protocol) {
+			target := path.Clean(path.Join(from, strings.TrimPrefix(constraint, protocol)))
+			if _, ok := patcher.Sbom.WorkSpaces[target]; ok && target != from {
+				return target, true
+			}
+		}
+	}
+	if strings.HasPrefix(constraint, "workspace:") {
+		for workspaceKey := range patcher.Sbom.WorkSpaces {
+			if workspaceKey != from && path.Base(workspaceKey) == path.Base(dependency.Name) {
+				return workspaceKey, true
+			}
+		}
+	}
+	return "", false
+}
+
+func sortedWorkspaceKeys(usage map[string]dependencyUsage) []string {
+	keys := make([]string, 0, len(usage))
+	for key := range usage {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,137 @@
+package patch
+
+import (
+	"testing"
+
+	sbomTypes "github.com/CodeClarityCE/plugin-sbom-javascript/src/types/sbom/js"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/ecosystem"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/knowledgeStore"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+	vulnerabilityFinder "github.com/CodeClarityCE/plugin-sca-vuln-finder/src/types"
+	"github.com/CodeClarityCE/utility-node-semver/versions"
+)
+
+// mockPatchInfo returns a fully patchable PatchInfo upgrading to version
+func mockPatchInfo(version string) patching.PatchInfo {
+	return patching.PatchInfo{
+		IsPatchable: patching.FULL,
+		Update:      versions.Semver{Version: version},
+	}
+}
+
+func TestAlignWorkspaces(t *testing.T) {
+	sbom := sbomTypes.Output{WorkSpaces: map[string]sbomTypes.WorkSpace{
+		"packages/a": {Start: sbomTypes.Start{Dependencies: []sbomTypes.WorkSpaceDependency{
+			{Name: "urllib3", Version: "1.26.4", Constraint: ">=1.26,<2"},
+		}}},
+		"packages/b": {Start: sbomTypes.Start{Dependencies: []sbomTypes.WorkSpaceDependency{
+			{Name: "urllib3", Version: "1.26.9", Constraint: ">=1.26,<2"},
+		}}},
+		"packages/c": {Start: sbomTypes.Start{Dependencies: []sbomTypes.WorkSpaceDependency{
+			{Name: "urllib3", Version: "1.25.11", Constraint: "==1.25.11"},
+		}}},
+		"packages/app": {Start: sbomTypes.Start{Dependencies: []sbomTypes.WorkSpaceDependency{
+			{Name: "@acme/a", Version: "1.0.0", Constraint: "workspace:*"},
+		}}},
+	}}
+	patchA := mockPatchInfo("1.26.5")
+	patchA.Patchable = []patching.ToPatch{mockToPatch("CVE-2021-33503", "urllib3")}
+	workspaces := map[string]patching.Workspace{
+		"packages/a": {Patches: map[string]patching.PatchInfo{"urllib3@1.26.4": patchA}},
+		"packages/b": {Patches: map[string]patching.PatchInfo{"urllib3@1.26.9": mockPatchInfo("1.26.18")}},
+		"packages/c": {Patches: map[string]patching.PatchInfo{}},
+	}
+
+	// The aligned version is scanned again for the workspaces that move to it
+	store := knowledgeStore.NewSnapshot()
+	store.Releases["PyPI:urllib3@1.26.18"] = knowledgeStore.SnapshotRelease{}
+	store.ReleaseVulnerabilities["PyPI:urllib3@1.26.18"] = []string{}
+
+	patcher := InitializePatcher(types.UpgradePolicy{VersionSelectionPreference: types.SELECT_NEWEST}, ecosystem.Python{Store: store}, sbom, vulnerabilityFinder.Output{})
+	aligned := patcher.AlignWorkspaces(workspaces)
+
+	if len(aligned) != 1 {
+		t.Fatalf("Expected 1 aligned upgrade, got %d", len(aligned))
+	}
+	if aligned[0].Version != "1.26.18" {
+		t.Errorf("Expected urllib3 to be aligned on 1.26.18, got %s", aligned[0].Version)
+	}
+	if len(aligned[0].Workspaces) != 2 || aligned[0].Workspaces[0] != "packages/a" || aligned[0].Workspaces[1] != "packages/b" {
+		t.Errorf("Unexpected followers: %v", aligned[0].Workspaces)
+	}
+	if len(aligned[0].Blocked) != 1 || aligned[0].Blocked[0].Workspace != "packages/c" {
+		t.Errorf("Expected packages/c to be blocked, got %v", aligned[0].Blocked)
+	}
+	if len(aligned[0].Dependents) != 1 || aligned[0].Dependents[0] != "packages/app" {
+		t.Errorf("Expected packages/app to depend on the aligned workspaces, got %v", aligned[0].Dependents)
+	}
+	if len(workspaces["packages/a"].Upgrades) != 1 || workspaces["packages/a"].Upgrades[0].NewConstraint != ">=1.26.18,<2" {
+		t.Errorf("Expected the upgrade of packages/a to target 1.26.18, got %v", workspaces["packages/a"].Upgrades)
+	}
+	if patch := workspaces["packages/a"].Patches["urllib3@1.26.4"]; patch.UpdateVersion() != "1.26.18" || patch.IsPatchable != patching.FULL {
+		t.Errorf("Expected the recommendation of packages/a to move to 1.26.18, got %s %s", patch.IsPatchable, patch.UpdateVersion())
+	}
+	if plan := workspaces["packages/a"].Plan; len(plan.Upgrades) != 1 || plan.Upgrades[0].Dependencies["urllib3@1.26.4"] != "1.26.18" {
+		t.Errorf("Expected the plan of packages/a to follow the aligned version, got %+v", plan)
+	}
+}
+
+func TestAlignWorkspacesKeepsFixes(t *testing.T) {
+	vulnerability := func(vulnerabilityId string) patching.ToPatch {
+		toPatch := mockToPatch(vulnerabilityId, "requests")
+		toPatch.DependencyName = "requests"
+		return toPatch
+	}
+	sbom := sbomTypes.Output{WorkSpaces: map[string]sbomTypes.WorkSpace{}}
+	for _, workspaceKey := range []string{"packages/a", "packages/b", "packages/c"} {
+		sbom.WorkSpaces[workspaceKey] = sbomTypes.WorkSpace{Start: sbomTypes.Start{Dependencies: []sbomTypes.WorkSpaceDependency{
+			{Name: "requests", Version: "2.0.0", Constraint: ">=2.0"},
+		}}}
+	}
+	patchA := mockPatchInfo("2.0.1")
+	patchA.IsPatchable = patching.PARTIAL
+	patchA.Patchable = []patching.ToPatch{vulnerability("CVE-A")}
+	patchA.Unpatchable = []patching.ToPatch{vulnerability("CVE-B")}
+	patchC := mockPatchInfo("2.0.1")
+	patchC.Patchable = []patching.ToPatch{vulnerability("CVE-B")}
+	workspaces := map[string]patching.Workspace{
+		"packages/a": {
+			Patches: map[string]patching.PatchInfo{"requests@2.0.0": patchA},
+			CoordinatedUpgrades: []patching.UpgradeGroup{
+				{Upgrades: map[string]string{"requests@2.0.0": "2.0.1", "urllib3@1.0.0": "1.1.0"}},
+			},
+		},
+		"packages/b": {Patches: map[string]patching.PatchInfo{"requests@2.0.0": mockPatchInfo("2.0.2")}},
+		"packages/c": {Patches: map[string]patching.PatchInfo{"requests@2.0.0": patchC}},
+	}
+
+	// 2.0.2 is still affected by the vulnerability the recommendation of packages/c fixes,
+	// which the recommendation of packages/a leaves unfixed
+	store := knowledgeStore.NewSnapshot()
+	store.Releases["PyPI:requests@2.0.2"] = knowledgeStore.SnapshotRelease{}
+	store.ReleaseVulnerabilities["PyPI:requests@2.0.2"] = []string{"CVE-B"}
+
+	patcher := InitializePatcher(types.UpgradePolicy{VersionSelectionPreference: types.SELECT_NEWEST}, ecosystem.Python{Store: store}, sbom, vulnerabilityFinder.Output{})
+	aligned := patcher.AlignWorkspaces(workspaces)
+
+	if len(aligned) != 1 || aligned[0].Version != "2.0.2" {
+		t.Fatalf("Expected requests to be aligned on 2.0.2, got %+v", aligned)
+	}
+	if len(aligned[0].Workspaces) != 2 || aligned[0].Workspaces[0] != "packages/a" || aligned[0].Workspaces[1] != "packages/b" {
+		t.Errorf("Unexpected followers: %v", aligned[0].Workspaces)
+	}
+	if len(aligned[0].Blocked) != 1 || aligned[0].Blocked[0].Workspace != "packages/c" || aligned[0].Blocked[0].Reason != "2.0.2 does not fix CVE-B:requests" {
+		t.Errorf("Expected packages/c to be blocked for losing its fix, got %+v", aligned[0].Blocked)
+	}
+	if patch := workspaces["packages/c"].Patches["requests@2.0.0"]; patch.UpdateVersion() != "2.0.1" || patch.IsPatchable != patching.FULL {
+		t.Errorf("Expected packages/c to keep its recommendation, got %s %s", patch.IsPatchable, patch.UpdateVersion())
+	}
+	if patch := workspaces["packages/a"].Patches["requests@2.0.0"]; patch.UpdateVersion() != "2.0.2" || patch.IsPatchable != patching.PARTIAL {
+		t.Errorf("Expected the recommendation of packages/a to move to 2.0.2, got %s %s", patch.IsPatchable, patch.UpdateVersion())
+	}
+	// The coordinated upgrade naming the former recommendation no longer applies
+	if groups := workspaces["packages/a"].CoordinatedUpgrades; len(groups) != 0 {
+		t.Errorf("Expected the coordinated upgrades of packages/a to be dropped, got %+v", groups)
+	}
+}
@@ -1,91 +1,144 @@
 package patch
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
-	"strings"
+	"slices"
 	"sync"
+	"time"
 
+	"github.com/CodeClarityCE/plugin-sca-patching/src/metrics"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/plan"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/tracing"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types"
 	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
 	vulnerabilityFinder "github.com/CodeClarityCE/plugin-sca-vuln-finder/src/types"
-	semver "github.com/CodeClarityCE/utility-node-semver"
 	"github.com/CodeClarityCE/utility-node-semver/versions"
-	knowledge "github.com/CodeClarityCE/utility-types/knowledge_db"
+	"github.com/CodeClarityCE/utility-types/exceptions"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func (patcher Patcher) PatchDependencies(dependenciesToPatch map[string][]patching.ToPatch) map[string]patching.PatchInfo {
 	patcher.patching_info = make(map[string]patching.PatchInfo)
+	fingerprints := map[string]string{}
 
 	// We iterate over the direct dependencies that need to be patched
 	// the vulnerability might be in the direct dependency itself or in one of its transitive dependencies
 	for dependency, toPatch := range dependenciesToPatch {
-		// We initialize the patching info for the dependency
-		patcher.patching_info[dependency] = patching.PatchInfo{
-			TopLevelVulnerable: false,
-			IsPatchable:        "",
-			Unpatchable:        []patching.ToPatch{},
-			Patchable:          []patching.ToPatch{},
-			Introduced:         []patching.ToPatch{},
-			Patches:            make(map[string]versions.Semver),
+		if fingerprint, computed := patcher.patchDependency(dependency, toPatch); computed {
+			fingerprints[dependency] = fingerprint
 		}
+	}
 
-		// We if the dependency needs to be patched because it is vulnerable itself
-		// In that case, we just need to find the closest non-vulnerable version
-		if len(toPatch) == 1 && dependency == toPatch[0].DependencyName+"@"+toPatch[0].DependencyVersion {
-			patch := patcher.patching_info[dependency]
-			patch.TopLevelVulnerable = true
-			patcher.patching_info[dependency] = patch
-			patcher.patchDirectDependencyVulnerable(dependency, toPatch[0])
-			continue
-		} else {
-			splited_dependency := strings.Split(dependency, "@")
-			name := splited_dependency[0]
-			version := splited_dependency[1]
-			if len(splited_dependency) == 3 {
-				name = splited_dependency[0] + "@" + splited_dependency[1]
-				version = splited_dependency[2]
-			}
-			lessVulnerableVersion, vulnerabilities, err := patcher.findLessVulnerableDependency(name, version)
-			if err != nil {
-				if err.Error() == "already patched" {
-					continue
-				} else if err.Error() == "dependency not fully patchable" {
-					patch := patcher.patching_info[dependency]
-					introduced, unpatchable, patchable := generatePatchingResult(vulnerabilities, toPatch)
-					patch.IsPatchable = "PARTIAL"
-					patch.Introduced = introduced
-					patch.Unpatchable = unpatchable
-					patch.Patchable = patchable
-					// patch.Patches[dependency] = versions.Semver{Version: lessVulnerableVersion}
-					patch.Update, err = semver.ParseSemver(lessVulnerableVersion)
-					if err != nil {
-						panic(err)
-					}
-					patcher.patching_info[dependency] = patch
-					continue
-				} else if err.Error() == "not patchable" {
-					patch := patcher.patching_info[dependency]
-					patch.IsPatchable = "NONE"
-					patch.Unpatchable = toPatch
-					patcher.patching_info[dependency] = patch
-					continue
-				} else {
+	for dependency, fingerprint := range fingerprints {
+		patch := patcher.patching_info[dependency]
+		patch.Fingerprint = fingerprint
+		patch.Recomputed = patcher.Previous != nil
+		patcher.patching_info[dependency] = patch
+	}
+	return patcher.patching_info
+}
+
+// patchDependency fills the patching info of a direct dependency.
+// It returns the fingerprint of the entry unless it was carried forward from the previous analysis.
+func (patcher Patcher) patchDependency(dependency string, toPatch []patching.ToPatch) (string, bool) {
+	ctx, span := tracing.Start(patcher.Context, "dependency", attribute.String("workspace", patcher.workspace), attribute.String("dependency", dependency))
+	patcher = patcher.under(ctx)
+	defer func() {
+		span.SetAttributes(attribute.String("patch_type", string(patcher.patching_info[dependency].IsPatchable)))
+		span.End()
+	}()
+
+	// We initialize the patching info for the dependency
+	patcher.patching_info[dependency] = patching.PatchInfo{
+		TopLevelVulnerable: false,
+		IsPatchable:        "",
+		Unpatchable:        []patching.ToPatch{},
+		Patchable:          []patching.ToPatch{},
+		Introduced:         []patching.ToPatch{},
+		Patches:            make(map[string]versions.Semver),
+	}
+
+	// Entries computed from the same inputs by the previous analysis are carried forward
+	fingerprint := patcher.fingerprint(dependency, toPatch)
+	if previous, ok := patcher.previousPatch(dependency, fingerprint); ok {
+		patcher.patching_info[dependency] = previous
+		span.SetAttributes(attribute.Bool("carried_forward", true))
+		return "", false
+	}
+
+	// Ignored packages are left as they are, without looking them up
+	name, version := patcher.Ecosystem.SplitKey(dependency)
+	if patcher.ignoreRule(name) != "" {
+		patch := patcher.patching_info[dependency]
+		patch.IsPatchable = patching.NONE
+		patch.Unpatchable = toPatch
+		patcher.patching_info[dependency] = patch
+		return fingerprint, true
+	}
+
+	// We if the dependency needs to be patched because it is vulnerable itself
+	// In that case, we just need to find the closest non-vulnerable version
+	// The fixed version is read from the NVD match. Matches without NVD evidence, such as OSV-only
+	// npm findings, carry none and go through the candidate search instead of failing,
+	// as do fixed versions the upgrade policy does not accept
+	if len(toPatch) == 1 && dependency == patcher.Ecosystem.Key(toPatch[0].DependencyName, toPatch[0].DependencyVersion) && toPatch[0].Vulnerability.NVDMatch != nil && patcher.acceptsFixedVersion(name, version, toPatch[0]) {
+		patch := patcher.patching_info[dependency]
+		patch.TopLevelVulnerable = true
+		patcher.patching_info[dependency] = patch
+		patcher.patchDirectDependencyVulnerable(dependency, toPatch[0])
+		return fingerprint, true
+	} else {
+		lessVulnerableVersion, vulnerabilities, err := patcher.findLessVulnerableDependency(name, version)
+		if err != nil {
+			if err.Error() == "already patched" {
+				return fingerprint, true
+			} else if err.Error() == "dependency not fully patchable" {
+				patch := patcher.patching_info[dependency]
+				introduced, unpatchable, patchable := generatePatchingResult(vulnerabilities, toPatch)
+				patch.IsPatchable = patching.PARTIAL
+				patch.Introduced = introduced
+				patch.Unpatchable = unpatchable
+				patch.Patchable = patchable
+				// patch.Patches[dependency] = versions.Semver{Version: lessVulnerableVersion}
+				patch.Update, err = patcher.Ecosystem.ParseVersion(lessVulnerableVersion)
+				if err != nil {
 					panic(err)
 				}
+				patcher.patching_info[dependency] = patch
+				return fingerprint, true
+			} else {
+				// Dependencies that cannot be looked up are reported and left unpatched
+				if err.Error() != "not patchable" {
+					patcher.Errors.Add(patching.AnalysisError{
+						Code:       exceptions.GENERIC_ERROR,
+						Severity:   patching.SEVERITY_WARNING,
+						Detail:     fmt.Sprintf("Error when looking for a patch: %s", err),
+						Workspace:  patcher.workspace,
+						Dependency: dependency,
+					})
+				}
+				patch := patcher.patching_info[dependency]
+				patch.IsPatchable = patching.NONE
+				patch.Unpatchable = toPatch
+				patcher.patching_info[dependency] = patch
+				return fingerprint, true
 			}
-			// If there is no error, it means that the dependency is fully patchable
-			patch := patcher.patching_info[dependency]
-			patch.IsPatchable = "FULL"
-			patch.Patchable = toPatch
-			// patch.Patches[dependency] = versions.Semver{Version: lessVulnerableVersion}
-			patch.Update, err = semver.ParseSemver(lessVulnerableVersion)
-			if err != nil {
-				panic(err)
-			}
-			patcher.patching_info[dependency] = patch
-
 		}
+		// If there is no error, it means that the dependency is fully patchable
+		patch := patcher.patching_info[dependency]
+		patch.IsPatchable = patching.FULL
+		patch.Patchable = toPatch
+		// patch.Patches[dependency] = versions.Semver{Version: lessVulnerableVersion}
+		patch.Update, err = patcher.Ecosystem.ParseVersion(lessVulnerableVersion)
+		if err != nil {
+			panic(err)
+		}
+		patcher.patching_info[dependency] = patch
+
 	}
-	return patcher.patching_info
+	return fingerprint, true
 }
 
 func generatePatchingResult(vulnerabilities []patching.ToPatch, toPatch []patching.ToPatch) ([]patching.ToPatch, []patching.ToPatch, []patching.ToPatch) {
@@ -127,8 +180,9 @@ func generatePatchingResult(vulnerabilities []patching.ToPatch, toPatch []patchi
 }
 
 func (patcher Patcher) findLessVulnerableDependency(dependencyName string, dependencyVersion string) (string, []patching.ToPatch, error) {
+	defer metrics.ObservePhase(metrics.PHASE_CANDIDATE_SEARCH, time.Now())
 	// Check that the dependency is not already patched
-	if patcher.patching_info[dependencyName+"@"+dependencyVersion].IsPatchable != "" {
+	if patcher.patching_info[patcher.Ecosystem.Key(dependencyName, dependencyVersion)].IsPatchable != "" {
 		return "", []patching.ToPatch{}, fmt.Errorf("already patched")
 	}
 
@@ -139,18 +193,22 @@ func (patcher Patcher) findLessVulnerableDependency(dependencyName string, depen
 	if len(versions) == 0 {
 		return "", []patching.ToPatch{}, fmt.Errorf("not patchable")
 	}
+	// The first clean version is recommended, the candidates come in the order of preference
 
+	severities := patcher.knownSeverities()
 	versionWithSmallestScore := ""
-	smallestScore := 0
+	smallestScore := 0.0
 	smallestVulnerabilities := []patching.ToPatch{}
+	var scanErr error
 	for _, version := range versions {
-		transitiveProdDependencies, transitiveDevDependencies, err := patcher.getTransitiveDependencies(dependencyName, version)
-		if err != nil {
-			return "", []patching.ToPatch{}, err
-		}
-		score, vulnerabilities, err := patcher.lookForVulnerabilities(transitiveProdDependencies, transitiveDevDependencies)
+		ctx, span := tracing.Start(patcher.Context, "candidate", attribute.String("package", dependencyName), attribute.String("version", version))
+		score, vulnerabilities, err := patcher.under(ctx).scanCandidate(dependencyName, version)
+		span.SetAttributes(attribute.Int("vulnerabilities", len(vulnerabilities)))
+		tracing.End(span, err)
 		if err != nil {
-			return "", []patching.ToPatch{}, err
+			// A candidate that cannot be checked is skipped, the error is reported if no other candidate can be
+			scanErr = fmt.Errorf("%s could not be checked: %w", patcher.Ecosystem.Key(dependencyName, version), err)
+			continue
 		}
 
 		// If the dependency is not vulnerable, we return the version
@@ -158,20 +216,84 @@ func (patcher Patcher) findLessVulnerableDependency(dependencyName string, depen
 			return version, vulnerabilities, nil
 		} else {
 			// we keep track of the smallest score and continue
-			if smallestScore == 0 || score < smallestScore {
-				smallestScore = score
+			partialScore := patcher.partialFixScore(vulnerabilities, severities)
+			if versionWithSmallestScore == "" || partialScore < smallestScore {
+				smallestScore = partialScore
 				smallestVulnerabilities = vulnerabilities
 				versionWithSmallestScore = version
 			}
 		}
 
 	}
+	if versionWithSmallestScore == "" && scanErr != nil {
+		return "", []patching.ToPatch{}, scanErr
+	}
 	return versionWithSmallestScore, smallestVulnerabilities, fmt.Errorf("dependency not fully patchable")
 }
 
-func (patcher Patcher) lookForVulnerabilities(transitiveProdDependencies []string, transitiveDevDependencies []string) (int, []patching.ToPatch, error) {
+// knownSeverities returns the scores of the vulnerabilities reported by the vuln-finder,
+// candidates only come with vulnerability identifiers
+func (patcher Patcher) knownSeverities() map[string]float64 {
+	severities := map[string]float64{}
+	for _, workspace := range patcher.Vulns.WorkSpaces {
+		for _, vulnerability := range workspace.Vulnerabilities {
+			severities[vulnerability.VulnerabilityId] = max(severities[vulnerability.VulnerabilityId], vulnerability.Severity.Severity)
+		}
+	}
+	return severities
+}
+
+// partialFixScore compares versions that leave vulnerabilities behind, following the partial fix selection of the policy.
+// Vulnerabilities of unknown severity count as plan.DEFAULT_SEVERITY.
+func (patcher Patcher) partialFixScore(vulnerabilities []patching.ToPatch, severities map[string]float64) float64 {
+	maxSeverity := 0.0
+	sum := 0.0
+	for _, vulnerability := range vulnerabilities {
+		severity := severities[vulnerability.Vulnerability.VulnerabilityId]
+		if severity == 0 {
+			severity = plan.DEFAULT_SEVERITY
+		}
+		maxSeverity = max(maxSeverity, severity)
+		sum += severity
+	}
+	if patcher.UpgradePolicy.PartialFixVersionSelection == types.SELECT_LOWEST_MAX_SEVERITY {
+		return maxSeverity
+	}
+	return sum / float64(len(vulnerabilities))
+}
+
+// scanCandidate looks for the vulnerabilities of a candidate version of a direct dependency
+// and of the transitive dependencies it would pull in.
+func (patcher Patcher) scanCandidate(dependencyName string, version string) (int, []patching.ToPatch, error) {
+	metrics.CountCandidate()
+	transitiveProdDependencies, transitiveDevDependencies, err := patcher.getTransitiveDependencies(dependencyName, version)
+	if err != nil {
+		return 0, nil, err
+	}
+	// The candidate itself can be affected, not only its dependencies: an npm release may be
+	// vulnerable while depending on clean packages, and must not be recommended as a fix
+	transitiveProdDependencies = append(transitiveProdDependencies, patcher.Ecosystem.Key(dependencyName, version))
+	score, vulnerabilities, err := patcher.lookForVulnerabilities(dependencyName, transitiveProdDependencies, transitiveDevDependencies)
+	if err != nil || patcher.UpgradePolicy.SeverityThreshold <= 0 {
+		return score, vulnerabilities, err
+	}
+	// As for the vulnerabilities of the project, those below the severity threshold of the policy
+	// do not count. Only the ones reported by the vuln-finder have a known score, the others always count.
+	severities := patcher.knownSeverities()
+	vulnerabilities = slices.DeleteFunc(vulnerabilities, func(vulnerability patching.ToPatch) bool {
+		severity := severities[vulnerability.Vulnerability.VulnerabilityId]
+		return severity > 0 && severity < patcher.UpgradePolicy.SeverityThreshold
+	})
+	return len(vulnerabilities), vulnerabilities, nil
+}
+
+// lookForVulnerabilities scans the transitive dependencies of a candidate version of a direct dependency.
+// Their import paths are unknown, so suppressions narrowed to a path are only matched on
+// the direct dependency and the affected package.
+func (patcher Patcher) lookForVulnerabilities(dependencyName string, transitiveProdDependencies []string, transitiveDevDependencies []string) (int, []patching.ToPatch, error) {
 	totalScore := 0
 	vulnerabilities := []patching.ToPatch{}
+	errs := []error{}
 
 	var wg sync.WaitGroup
 	maxGoroutines := 50
@@ -183,17 +305,13 @@ func (patcher Patcher) lookForVulnerabilities(transitiveProdDependencies []strin
 		guard <- struct{}{}
 		go func(wg *sync.WaitGroup, dependency string) {
 			defer wg.Done()
-			splited_dependency := strings.Split(dependency, "@")
-			name := splited_dependency[0]
-			version := splited_dependency[1]
-			if len(splited_dependency) == 3 {
-				name = splited_dependency[0] + "@" + splited_dependency[1]
-				version = splited_dependency[2]
-			}
-			nvdScore, foundVulnerabilities, _ := patcher.GetNVDVulnerabilities(name, version)
+			name, version := patcher.Ecosystem.SplitKey(dependency)
+			score, vulnerabilitiesConverted, err := patcher.findVulnerabilities(dependencyName, name, version)
 			mutex.Lock()
-			totalScore += nvdScore
-			vulnerabilitiesConverted := convertNVDItemsToPatchItems(foundVulnerabilities, name, version)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			totalScore += score
 			vulnerabilities = append(vulnerabilities, vulnerabilitiesConverted...)
 			mutex.Unlock()
 			<-guard
@@ -205,31 +323,58 @@ func (patcher Patcher) lookForVulnerabilities(transitiveProdDependencies []strin
 		guard <- struct{}{}
 		go func(wg *sync.WaitGroup, dependency string) {
 			defer wg.Done()
-			splited_dependency := strings.Split(dependency, "@")
-			name := splited_dependency[0]
-			version := splited_dependency[1]
-			if len(splited_dependency) == 3 {
-				name = splited_dependency[0] + "@" + splited_dependency[1]
-				version = splited_dependency[2]
-			}
-			nvdScore, foundVulnerabilities, _ := patcher.GetNVDVulnerabilities(name, version)
+			name, version := patcher.Ecosystem.SplitKey(dependency)
+			score, vulnerabilitiesConverted, err := patcher.findVulnerabilities(dependencyName, name, version)
 			mutex.Lock()
-			totalScore += nvdScore
-			vulnerabilitiesConverted := convertNVDItemsToPatchItems(foundVulnerabilities, name, version)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			totalScore += score
 			vulnerabilities = append(vulnerabilities, vulnerabilitiesConverted...)
 			mutex.Unlock()
 			<-guard
 		}(&wg, dependency)
 	}
 	wg.Wait()
+	// A release whose vulnerabilities are unknown cannot be called clean
+	if len(errs) > 0 {
+		slices.SortFunc(errs, func(a error, b error) int { return cmp.Compare(a.Error(), b.Error()) })
+		return 0, nil, errors.Join(errs...)
+	}
 
+	// The goroutines finish in any order, sort the result so the output is reproducible
+	slices.SortFunc(vulnerabilities, func(a patching.ToPatch, b patching.ToPatch) int {
+		return cmp.Or(
+			cmp.Compare(a.DependencyName, b.DependencyName),
+			cmp.Compare(a.DependencyVersion, b.DependencyVersion),
+			cmp.Compare(a.Vulnerability.VulnerabilityId, b.Vulnerability.VulnerabilityId),
+		)
+	})
 	return totalScore, vulnerabilities, nil
 }
 
-func convertNVDItemsToPatchItems(nvdItems []knowledge.NVDItem, name string, version string) []patching.ToPatch {
+// findVulnerabilities returns the score and the vulnerabilities affecting a dependency pulled in by a direct dependency.
+// Suppressed vulnerabilities do not count.
+func (patcher Patcher) findVulnerabilities(directDependency string, name string, version string) (int, []patching.ToPatch, error) {
+	vulnerabilityIds, err := patcher.Ecosystem.Vulnerabilities(name, version)
+	if err != nil {
+		return 0, nil, err
+	}
+	path := []string{directDependency}
+	if name != directDependency {
+		path = append(path, name)
+	}
+	vulnerabilityIds = slices.DeleteFunc(slices.Clone(vulnerabilityIds), func(vulnerabilityId string) bool {
+		_, suppressed := patcher.suppression(vulnerabilityId, name, path)
+		return suppressed
+	})
+	return len(vulnerabilityIds), convertVulnerabilityIdsToPatchItems(vulnerabilityIds, name, version), nil
+}
+
+func convertVulnerabilityIdsToPatchItems(vulnerabilityIds []string, name string, version string) []patching.ToPatch {
 	// TODO fill missing information
 	toPatchItems := []patching.ToPatch{}
-	for _, nvdItem := range nvdItems {
+	for _, vulnerabilityId := range vulnerabilityIds {
 		toPatchItems = append(toPatchItems, patching.ToPatch{
 			DependencyName:    name,
 			DependencyVersion: version,
@@ -238,7 +383,7 @@ func convertNVDItemsToPatchItems(nvdItems []knowledge.NVDItem, name string, vers
 				Sources:            []vulnerabilityFinder.VulnerabilitySource{},
 				AffectedDependency: name,
 				AffectedVersion:    version,
-				VulnerabilityId:    nvdItem.NVDId,
+				VulnerabilityId:    vulnerabilityId,
 				OSVMatch:           &vulnerabilityFinder.OSVVulnerability{},
 				NVDMatch:           &vulnerabilityFinder.NVDVulnerability{},
 				Severity:           vulnerabilityFinder.VulnerabilityMatchSeverity{},
@@ -252,7 +397,7 @@ func convertNVDItemsToPatchItems(nvdItems []knowledge.NVDItem, name string, vers
 func (patcher Patcher) patchDirectDependencyVulnerable(dependency string, vulnerableDependency patching.ToPatch) {
 	patch := patcher.patching_info[dependency]
 	if vulnerableDependency.Vulnerability.NVDMatch.VulnerableEvidenceType == vulnerabilityFinder.VULNERABLE_EVIDENCE_UNIVERSAL {
-		patch.IsPatchable = "NONE"
+		patch.IsPatchable = patching.NONE
 		patch.Unpatchable = append(patch.Unpatchable, vulnerableDependency)
 
 	} else {
@@ -260,7 +405,7 @@ func (patcher Patcher) patchDirectDependencyVulnerable(dependency string, vulner
 		if err != nil {
 			panic(err)
 		}
-		patch.IsPatchable = "FULL"
+		patch.IsPatchable = patching.FULL
 		patch.Patchable = append(patch.Patchable, vulnerableDependency)
 		patch.Patches[dependency] = patched_version
 		patch.Update = patched_version
@@ -268,6 +413,28 @@ func (patcher Patcher) patchDirectDependencyVulnerable(dependency string, vulner
 	patcher.patching_info[dependency] = patch
 }
 
+// acceptsFixedVersion reports whether the version fixing the only vulnerability of a vulnerable direct dependency
+// can be recommended without a candidate search. The upgrade policy has to allow it, and to prefer the oldest
+// versions since the newer ones are not looked at. Unfixable vulnerabilities need no search either.
+func (patcher Patcher) acceptsFixedVersion(dependencyName string, dependencyVersion string, vulnerableDependency patching.ToPatch) bool {
+	if vulnerableDependency.Vulnerability.NVDMatch.VulnerableEvidenceType == vulnerabilityFinder.VULNERABLE_EVIDENCE_UNIVERSAL {
+		return true
+	}
+	if patcher.UpgradePolicy.VersionSelectionPreference != types.SELECT_OLDEST || vulnerableDependency.Vulnerability.OSVMatch == nil {
+		return false
+	}
+	fixed, err := getClosestNonVulnerable(*vulnerableDependency.Vulnerability.NVDMatch, *vulnerableDependency.Vulnerability.OSVMatch)
+	if err != nil {
+		return false
+	}
+	fixedVersion := fixed.Version
+	if fixedVersion == "" {
+		fixedVersion = fixed.String()
+	}
+	comparison, err := patcher.Ecosystem.Compare(fixedVersion, dependencyVersion)
+	return err == nil && comparison > 0 && patcher.allowedVersion(dependencyName, dependencyVersion, fixedVersion)
+}
+
 func getClosestNonVulnerable(NVD vulnerabilityFinder.NVDVulnerability, OSV vulnerabilityFinder.OSVVulnerability) (versions.Semver, error) {
 	if NVD.VulnerableEvidenceType == vulnerabilityFinder.VULNERABLE_EVIDENCE_RANGE {
 		return NVD.VulnerableEvidenceRange.Vulnerable.FixedSemver, nil
@@ -0,0 +1,183 @@
+package patch
+
+import (
+	"slices"
+	"testing"
+
+	sbomTypes "github.com/CodeClarityCE/plugin-sbom-javascript/src/types/sbom/js"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/ecosystem"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/knowledgeStore"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+	vulnerabilityFinder "github.com/CodeClarityCE/plugin-sca-vuln-finder/src/types"
+	"github.com/CodeClarityCE/utility-node-semver/versions"
+)
+
+// mockNpmPatcher returns a patcher answering from a snapshot where lodash 4.17.21 is vulnerable itself
+// and 4.17.22 is clean, picking the oldest clean version
+func mockNpmPatcher() Patcher {
+	store := knowledgeStore.NewSnapshot()
+	store.PackageVersions["npm:lodash"] = []string{"4.17.20", "4.17.21", "4.17.22"}
+	for _, version := range []string{"4.17.20", "4.17.21", "4.17.22"} {
+		store.Releases["npm:lodash@"+version] = knowledgeStore.SnapshotRelease{}
+	}
+	store.ReleaseVulnerabilities["npm:lodash@4.17.20"] = []string{"CVE-2021-23337"}
+	store.ReleaseVulnerabilities["npm:lodash@4.17.21"] = []string{"CVE-2099-0001"}
+	store.ReleaseVulnerabilities["npm:lodash@4.17.22"] = []string{}
+	upgradePolicy := types.UpgradePolicy{VersionSelectionPreference: types.SELECT_OLDEST, MaxMajorJump: patching.UNLIMITED_MAJOR_JUMP}
+	return InitializePatcher(upgradePolicy, ecosystem.Npm{Store: store}, sbomTypes.Output{}, vulnerabilityFinder.Output{})
+}
+
+func TestDirectVulnerableWithoutNVDMatch(t *testing.T) {
+	// A match found only in OSV carries no fixed version, it goes through the candidate search
+	toPatch := patching.ToPatch{DependencyName: "lodash", DependencyVersion: "4.17.20", Vulnerability: vulnerabilityFinder.Vulnerability{
+		VulnerabilityId:    "CVE-2021-23337",
+		AffectedDependency: "lodash",
+		AffectedVersion:    "4.17.20",
+	}}
+
+	patches := mockNpmPatcher().PatchDependencies(map[string][]patching.ToPatch{"lodash@4.17.20": {toPatch}})
+
+	if patch := patches["lodash@4.17.20"]; patch.IsPatchable != patching.FULL || patch.UpdateVersion() != "4.17.22" {
+		t.Errorf("Expected lodash to be fully patched to 4.17.22, got %s %s", patch.IsPatchable, patch.UpdateVersion())
+	}
+}
+
+func TestCandidateVulnerableItself(t *testing.T) {
+	// 4.17.21 has no vulnerable dependency but is vulnerable itself, so it is not a fix
+	score, vulnerabilities, err := mockNpmPatcher().scanCandidate("lodash", "4.17.21")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if score != 1 || len(vulnerabilities) != 1 || vulnerabilities[0].Vulnerability.VulnerabilityId != "CVE-2099-0001" {
+		t.Errorf("Expected the vulnerability of the candidate itself, got %v", vulnerabilities)
+	}
+}
+
+func TestCandidateWithUnknownVulnerabilities(t *testing.T) {
+	patcher := mockNpmPatcher()
+	store := patcher.Ecosystem.(ecosystem.Npm).Store.(*knowledgeStore.Snapshot)
+	delete(store.ReleaseVulnerabilities, "npm:lodash@4.17.22")
+
+	// A release missing from the knowledge is not clean, it cannot be recommended
+	if _, _, err := patcher.scanCandidate("lodash", "4.17.22"); err == nil {
+		t.Error("Expected the scan of a release without vulnerability data to fail")
+	}
+	version, _, err := patcher.findLessVulnerableDependency("lodash", "4.17.20")
+	if version != "4.17.21" || err == nil || err.Error() != "dependency not fully patchable" {
+		t.Errorf("Expected the partial fix 4.17.21, got %s %v", version, err)
+	}
+}
+
+func TestVersionPreference(t *testing.T) {
+	patcher := mockNpmPatcher()
+	store := patcher.Ecosystem.(ecosystem.Npm).Store.(*knowledgeStore.Snapshot)
+	store.PackageVersions["npm:lodash"] = append(store.PackageVersions["npm:lodash"], "4.17.23")
+	store.Releases["npm:lodash@4.17.23"] = knowledgeStore.SnapshotRelease{}
+	store.ReleaseVulnerabilities["npm:lodash@4.17.23"] = []string{}
+
+	if version, _, err := patcher.findLessVulnerableDependency("lodash", "4.17.20"); err != nil || version != "4.17.22" {
+		t.Errorf("Expected the oldest clean version 4.17.22, got %s %v", version, err)
+	}
+	patcher.UpgradePolicy.VersionSelectionPreference = types.SELECT_NEWEST
+	if version, _, err := patcher.findLessVulnerableDependency("lodash", "4.17.20"); err != nil || version != "4.17.23" {
+		t.Errorf("Expected the newest clean version 4.17.23, got %s %v", version, err)
+	}
+}
+
+func TestCandidateBelowSeverityThreshold(t *testing.T) {
+	patcher := mockNpmPatcher()
+	patcher.UpgradePolicy.SeverityThreshold = 7
+	patcher.Vulns = vulnerabilityFinder.Output{WorkSpaces: map[string]vulnerabilityFinder.Workspace{".": {
+		Vulnerabilities: []vulnerabilityFinder.Vulnerability{
+			{VulnerabilityId: "CVE-2099-0001", Severity: vulnerabilityFinder.VulnerabilityMatchSeverity{Severity: 3.1}},
+		},
+	}}}
+
+	// The vulnerability of 4.17.21 is below the threshold, so it is a fix
+	score, vulnerabilities, err := patcher.scanCandidate("lodash", "4.17.21")
+	if err != nil || score != 0 || len(vulnerabilities) != 0 {
+		t.Errorf("Expected the low severity vulnerability to be ignored, got %d %v %v", score, vulnerabilities, err)
+	}
+}
+
+func TestPartialFixScore(t *testing.T) {
+	severities := map[string]float64{"CVE-2021-44906": 9.8, "CVE-2022-24999": 7.5}
+	// One critical vulnerability, against two of medium and unknown severity
+	critical := []patching.ToPatch{mockToPatch("CVE-2021-44906", "minimist")}
+	several := []patching.ToPatch{mockToPatch("CVE-2022-24999", "qs"), mockToPatch("CVE-2024-45590", "body-parser")}
+
+	patcher := Patcher{UpgradePolicy: types.UpgradePolicy{PartialFixVersionSelection: types.SELECT_LOWEST_MAX_SEVERITY}}
+	if patcher.partialFixScore(several, severities) != 7.5 || patcher.partialFixScore(critical, severities) != 9.8 {
+		t.Errorf("Expected the highest severity of each version")
+	}
+
+	patcher.UpgradePolicy.PartialFixVersionSelection = types.SELECT_LOWEST_AVERAGE_SEVERITY
+	if score := patcher.partialFixScore(several, severities); score != 6.25 {
+		t.Errorf("Expected the average severity, unknown ones counting as 5, got %v", score)
+	}
+}
+
+func TestIntroducedSeverities(t *testing.T) {
+	introduced := func(vulnerabilityId string) patching.ToPatch {
+		return patching.ToPatch{Vulnerability: vulnerabilityFinder.Vulnerability{VulnerabilityId: vulnerabilityId}}
+	}
+	patches := map[string]patching.PatchInfo{
+		"npm:lodash@4.17.20": {
+			IsPatchable: patching.PARTIAL,
+			Update:      versions.Semver{Version: "4.17.21"},
+			Introduced:  []patching.ToPatch{introduced("CVE-2021-23337"), introduced("CVE-2099-0001")},
+		},
+	}
+	scoreIntroduced(patches, map[string]float64{"CVE-2021-23337": 7.2})
+	setSeverityDistributions(patches)
+
+	after := patches["npm:lodash@4.17.20"].AfterUpgradeSeverityDist
+	if after.High != 1 || after.Unknown != 1 || after.None != 0 {
+		t.Errorf("Expected one high and one unknown introduced vulnerability, got %+v", after)
+	}
+}
+
+func TestFixedVersionOutsidePolicy(t *testing.T) {
+	// The NVD fixes CVE-2021-23337 in 5.0.0, a major upgrade the policy forbids
+	nvdMatch := vulnerabilityFinder.NVDVulnerability{VulnerableEvidenceType: vulnerabilityFinder.VULNERABLE_EVIDENCE_RANGE}
+	nvdMatch.VulnerableEvidenceRange.Vulnerable.FixedSemver = versions.Semver{Version: "5.0.0"}
+	toPatch := patching.ToPatch{DependencyName: "lodash", DependencyVersion: "4.17.20", Vulnerability: vulnerabilityFinder.Vulnerability{
+		VulnerabilityId:    "CVE-2021-23337",
+		AffectedDependency: "lodash",
+		AffectedVersion:    "4.17.20",
+		NVDMatch:           &nvdMatch,
+		OSVMatch:           &vulnerabilityFinder.OSVVulnerability{},
+	}}
+
+	patcher := mockNpmPatcher()
+	patches := patcher.PatchDependencies(map[string][]patching.ToPatch{"lodash@4.17.20": {toPatch}})
+	if patch := patches["lodash@4.17.20"]; patch.UpdateVersion() != "5.0.0" || !patch.TopLevelVulnerable {
+		t.Errorf("Expected the fixed version 5.0.0, got %s", patch.UpdateVersion())
+	}
+
+	// Without major upgrades, the candidate search is run instead
+	patcher.UpgradePolicy.MaxMajorJump = 0
+	patches = patcher.PatchDependencies(map[string][]patching.ToPatch{"lodash@4.17.20": {toPatch}})
+	if patch := patches["lodash@4.17.20"]; patch.IsPatchable != patching.FULL || patch.UpdateVersion() != "4.17.22" {
+		t.Errorf("Expected lodash to be fully patched to 4.17.22, got %s %s", patch.IsPatchable, patch.UpdateVersion())
+	}
+}
+
+func TestAllowDowngrades(t *testing.T) {
+	patcher := mockNpmPatcher()
+	patcher.UpgradePolicy.VersionSelectionPreference = types.SELECT_NEWEST
+	store := patcher.Ecosystem.(ecosystem.Npm).Store.(*knowledgeStore.Snapshot)
+	store.PackageVersions["npm:lodash"] = append([]string{"4.17.18", "4.17.19"}, store.PackageVersions["npm:lodash"]...)
+
+	if versions, err := patcher.getPossibleVersions("lodash", "4.17.20"); err != nil || len(versions) != 2 {
+		t.Errorf("Expected only the newer versions, got %v %v", versions, err)
+	}
+	// Older versions come after the upgrades, the closest first
+	patcher.UpgradePolicy.AllowDowngrades = true
+	versions, err := patcher.getPossibleVersions("lodash", "4.17.20")
+	expected := []string{"4.17.22", "4.17.21", "4.17.19", "4.17.18"}
+	if err != nil || !slices.Equal(versions, expected) {
+		t.Errorf("Expected %v, got %v %v", expected, versions, err)
+	}
+}
@@ -0,0 +1,128 @@
+package patch
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+)
+
+// matchPattern returns the pattern naming a package, exact names first, then glob patterns in order
+func matchPattern(patterns []string, name string) (string, bool) {
+	if slices.Contains(patterns, name) {
+		return name, true
+	}
+	for _, pattern := range slices.Sorted(slices.Values(patterns)) {
+		if matched, _ := path.Match(pattern, name); matched {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// ignoreRule returns the ignore rule leaving a package out of the remediation, if any
+func (patcher Patcher) ignoreRule(name string) string {
+	if pattern, ok := matchPattern(patcher.UpgradePolicy.Rules.Ignore, name); ok {
+		return "ignore " + pattern
+	}
+	return ""
+}
+
+// hasVersionRule reports whether a pin or a range restricts the versions of a package
+func (patcher Patcher) hasVersionRule(name string) bool {
+	rules := patcher.UpgradePolicy.Rules
+	_, pinned := matchPattern(slices.Collect(maps.Keys(rules.Pins)), name)
+	_, ranged := matchPattern(slices.Collect(maps.Keys(rules.Ranges)), name)
+	return pinned || ranged
+}
+
+// versionRule returns the pin or range rule that forbids moving a package to version, if any
+func (patcher Patcher) versionRule(name string, version string) string {
+	rules := patcher.UpgradePolicy.Rules
+	if pattern, ok := matchPattern(slices.Collect(maps.Keys(rules.Pins)), name); ok {
+		comparison, err := patcher.Ecosystem.Compare(version, rules.Pins[pattern])
+		if err != nil || comparison != 0 {
+			return fmt.Sprintf("pin %s %s", pattern, rules.Pins[pattern])
+		}
+	}
+	if pattern, ok := matchPattern(slices.Collect(maps.Keys(rules.Ranges)), name); ok {
+		satisfies, err := patcher.Ecosystem.Satisfies(version, rules.Ranges[pattern])
+		if err != nil || !satisfies {
+			return fmt.Sprintf("range %s %s", pattern, rules.Ranges[pattern])
+		}
+	}
+	return ""
+}
+
+// blockedRecommendations lists the dependencies the policy kept from being fully patched:
+// ignored ones, and the ones whose pins or ranges excluded a version that the patcher could have picked.
+func (patcher Patcher) blockedRecommendations(patches map[string]patching.PatchInfo, dev bool) []patching.BlockedRecommendation {
+	blocked := []patching.BlockedRecommendation{}
+	for _, dependency := range slices.Sorted(maps.Keys(patches)) {
+		name, _ := patcher.Ecosystem.SplitKey(dependency)
+		rule := patcher.ignoreRule(name)
+		if rule == "" && patches[dependency].IsPatchable != patching.FULL {
+			rule = patcher.rejectedVersionRule(dependency)
+		}
+		if rule != "" {
+			blocked = append(blocked, patching.BlockedRecommendation{Dependency: dependency, Dev: dev, Rule: rule})
+		}
+	}
+	return blocked
+}
+
+// rejectedVersionRule returns the rule that rejects a newer version of a dependency, if any
+func (patcher Patcher) rejectedVersionRule(dependency string) string {
+	name, installed := patcher.Ecosystem.SplitKey(dependency)
+	if !patcher.hasVersionRule(name) {
+		return ""
+	}
+	versions, err := patcher.Ecosystem.Versions(name)
+	if err != nil {
+		return ""
+	}
+	for _, version := range versions {
+		comparison, err := patcher.Ecosystem.Compare(version, installed)
+		if err != nil || comparison <= 0 || patcher.Ecosystem.IsPrerelease(version) {
+			continue
+		}
+		if rule := patcher.versionRule(name, version); rule != "" {
+			return rule
+		}
+	}
+	return ""
+}
+
+// suppression returns the active suppression hiding a vulnerability of a package, if any.
+// path holds the names of the packages it is reached through, from the direct dependency to the package itself.
+func (patcher Patcher) suppression(vulnerabilityId string, name string, path []string) (patching.Suppression, bool) {
+	for _, suppression := range patcher.Suppressions {
+		if suppression.VulnerabilityId != vulnerabilityId {
+			continue
+		}
+		if suppression.Package != "" {
+			if _, ok := matchPattern([]string{suppression.Package}, name); !ok {
+				continue
+			}
+		}
+		if followsPath(suppression.Path, path) {
+			return suppression, true
+		}
+	}
+	return patching.Suppression{}, false
+}
+
+// followsPath reports whether a path starts with packages matching patterns, in order
+func followsPath(patterns []string, path []string) bool {
+	if len(patterns) > len(path) {
+		return false
+	}
+	for i, pattern := range patterns {
+		if _, ok := matchPattern([]string{pattern}, path[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,74 @@
+package patch
+
+import (
+	"slices"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+)
+
+// scoreIntroduced gives the vulnerabilities introduced by upgrades the score the vuln-finder reported for them.
+// Candidate scans only return vulnerability ids, those never reported stay unscored and are counted as unknown.
+func scoreIntroduced(patches map[string]patching.PatchInfo, severities map[string]float64) {
+	for dependency, patch := range patches {
+		for index, toPatch := range patch.Introduced {
+			if toPatch.Vulnerability.Severity.Severity == 0 && toPatch.Vulnerability.Severity.SeverityClass == "" {
+				patch.Introduced[index].Vulnerability.Severity.Severity = severities[toPatch.Vulnerability.VulnerabilityId]
+			}
+		}
+		patches[dependency] = patch
+	}
+}
+
+// setSeverityDistributions counts the severities of the vulnerabilities of every direct dependency
+// before and after its recommended upgrade. Dependencies without an upgrade keep their vulnerabilities.
+func setSeverityDistributions(patches map[string]patching.PatchInfo) {
+	for dependency, patch := range patches {
+		before, after := patching.SeverityDist{}, patching.SeverityDist{}
+		for _, toPatch := range slices.Concat(patch.Patchable, patch.Unpatchable) {
+			before.Add(toPatch.Vulnerability)
+		}
+		if patch.UpdateVersion() == "" {
+			after = before
+		} else {
+			for _, toPatch := range slices.Concat(patch.Unpatchable, patch.Introduced) {
+				after.Add(toPatch.Vulnerability)
+			}
+		}
+		patch.SeverityDist = before
+		patch.AfterUpgradeSeverityDist = after
+		patches[dependency] = patch
+	}
+}
+
+// workspaceSeverityDistributions counts the severities of the distinct vulnerabilities of a workspace
+// before and after all its upgrades, a vulnerability reached through several direct dependencies being counted once.
+func workspaceSeverityDistributions(patches ...map[string]patching.PatchInfo) (patching.SeverityDist, patching.SeverityDist) {
+	before, after := map[string]patching.ToPatch{}, map[string]patching.ToPatch{}
+	for _, patchMap := range patches {
+		for _, patch := range patchMap {
+			for _, toPatch := range slices.Concat(patch.Patchable, patch.Unpatchable) {
+				before[occurenceKey(toPatch)] = toPatch
+			}
+			remaining := patch.Unpatchable
+			if patch.UpdateVersion() == "" {
+				remaining = slices.Concat(patch.Patchable, patch.Unpatchable)
+			}
+			for _, toPatch := range slices.Concat(remaining, patch.Introduced) {
+				after[occurenceKey(toPatch)] = toPatch
+			}
+		}
+	}
+
+	beforeDist, afterDist := patching.SeverityDist{}, patching.SeverityDist{}
+	for _, toPatch := range before {
+		beforeDist.Add(toPatch.Vulnerability)
+	}
+	for _, toPatch := range after {
+		afterDist.Add(toPatch.Vulnerability)
+	}
+	return beforeDist, afterDist
+}
+
+func occurenceKey(toPatch patching.ToPatch) string {
+	return toPatch.Vulnerability.VulnerabilityId + ":" + toPatch.Vulnerability.AffectedDependency + "@" + toPatch.Vulnerability.AffectedVersion
+}
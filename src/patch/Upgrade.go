@@ -1,49 +1,143 @@
 package patch
 
 import (
+	"context"
+
 	sbomTypes "github.com/CodeClarityCE/plugin-sbom-javascript/src/types/sbom/js"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/ecosystem"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/exceptionManager"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/exploitability"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/plan"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/tracing"
 	types "github.com/CodeClarityCE/plugin-sca-patching/src/types"
 	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
 	vulnerabilityFinder "github.com/CodeClarityCE/plugin-sca-vuln-finder/src/types"
-	"github.com/uptrace/bun"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Patcher struct {
 	UpgradePolicy types.UpgradePolicy
-	Knowledge     *bun.DB
+	Ecosystem     ecosystem.Ecosystem
 	Sbom          sbomTypes.Output
 	Vulns         vulnerabilityFinder.Output
+	// Exploitability raises the priority of recommendations fixing exploited vulnerabilities
+	Exploitability exploitability.Dataset
+	// Suppressions are the suppressions of the policy that have not expired
+	Suppressions []patching.Suppression
+	// Previous is the last result of the project, whose unchanged entries are carried forward
+	Previous *patching.Output
+	// VulnerabilityDataVersion is the version of the vulnerability data of the knowledge store,
+	// entries are only carried forward when it did not change
+	VulnerabilityDataVersion string
+	// Errors collects the errors of the analysis
+	Errors *exceptionManager.Collector
+	// Context carries the span the spans of the patcher are recorded under
+	Context       context.Context
+	workspace     string
 	patching_info map[string]patching.PatchInfo
 }
 
-func InitializePatcher(upgradePolicy types.UpgradePolicy, knowledge *bun.DB, sbom sbomTypes.Output, vulns vulnerabilityFinder.Output) Patcher {
+func InitializePatcher(upgradePolicy types.UpgradePolicy, ecosystem ecosystem.Ecosystem, sbom sbomTypes.Output, vulns vulnerabilityFinder.Output) Patcher {
 	return Patcher{
-		UpgradePolicy: upgradePolicy,
-		Knowledge:     knowledge,
-		Sbom:          sbom,
-		Vulns:         vulns,
+		UpgradePolicy:  upgradePolicy,
+		Ecosystem:      ecosystem,
+		Sbom:           sbom,
+		Vulns:          vulns,
+		Exploitability: exploitability.NewDataset(),
+		Errors:         exceptionManager.NewCollector(),
+		Context:        context.Background(),
 	}
 }
 
+// under returns the patcher with its spans, knowledge queries included, recorded under the span of ctx
+func (patcher Patcher) under(ctx context.Context) Patcher {
+	patcher.Context = ctx
+	patcher.Ecosystem = ecosystem.Traced(patcher.Ecosystem, ctx)
+	return patcher
+}
+
 func (patcher Patcher) PatchApplication() map[string]patching.Workspace {
+	ctx, span := tracing.Start(patcher.Context, "PatchApplication", attribute.Int("workspaces", len(patcher.Sbom.WorkSpaces)))
+	defer span.End()
 	workspaceDataMap := map[string]patching.Workspace{}
 
 	// Iterate over each workspace in the Sbom
 	for workspaceKey := range patcher.Sbom.WorkSpaces {
+		workspaceCtx, workspaceSpan := tracing.Start(ctx, "workspace", attribute.String("workspace", workspaceKey))
+		patcher = patcher.under(workspaceCtx)
+		patcher.workspace = workspaceKey
 		// Retrieve the top-level dependencies to patch for the current workspace
-		dependenciesToPatch, devDependenciesToPatch := retrieveTopLevelDependenciesToPatch(patcher.Sbom.WorkSpaces[workspaceKey], patcher.Vulns.WorkSpaces[workspaceKey])
+		dependenciesToPatch, devDependenciesToPatch, suppressed := patcher.retrieveTopLevelDependenciesToPatch(patcher.Sbom.WorkSpaces[workspaceKey], patcher.Vulns.WorkSpaces[workspaceKey])
 
 		// Patch the dependencies and devDependencies
 		patches := patcher.PatchDependencies(dependenciesToPatch)
 		devPatches := patcher.PatchDependencies(devDependenciesToPatch)
 
 		// Create a new Workspace object and add it to the workspaceDataMap
-		workspaceDataMap[workspaceKey] = patching.Workspace{
+		workspace := patching.Workspace{
 			Patches:    patches,
 			DevPatches: devPatches,
+			Upgrades: append(
+				patcher.generateUpgrades(patcher.Sbom.WorkSpaces[workspaceKey].Start.Dependencies, patches),
+				patcher.generateUpgrades(patcher.Sbom.WorkSpaces[workspaceKey].Start.DevDependencies, devPatches)...,
+			),
+			CoordinatedUpgrades: append(
+				patcher.findCoordinatedUpgrades(dependenciesToPatch, patches),
+				patcher.findCoordinatedUpgrades(devDependenciesToPatch, devPatches)...,
+			),
 		}
+		workspace.Suppressed = suppressed
+		patcher.summarizeWorkspace(&workspace)
+		workspaceDataMap[workspaceKey] = workspace
+		workspaceSpan.End()
 	}
 
 	return workspaceDataMap
 
 }
+
+// summarizeWorkspace derives the priorities, severity distributions, blocked recommendations,
+// vulnerability view and plan of a workspace from its patches
+func (patcher Patcher) summarizeWorkspace(workspace *patching.Workspace) {
+	patcher.prioritize(workspace.Patches)
+	patcher.prioritize(workspace.DevPatches)
+	severities := patcher.knownSeverities()
+	scoreIntroduced(workspace.Patches, severities)
+	scoreIntroduced(workspace.DevPatches, severities)
+	setSeverityDistributions(workspace.Patches)
+	setSeverityDistributions(workspace.DevPatches)
+	workspace.Blocked = append(patcher.blockedRecommendations(workspace.Patches, false), patcher.blockedRecommendations(workspace.DevPatches, true)...)
+	workspace.Vulnerabilities = patcher.vulnerabilityView(workspace.Patches, workspace.DevPatches)
+	workspace.SeverityDist, workspace.AfterUpgradeSeverityDist = workspaceSeverityDistributions(workspace.Patches, workspace.DevPatches)
+	workspace.Plan = plan.Optimize(*workspace, patcher.Ecosystem, patcher.Exploitability, patcher.UpgradePolicy.PlanBudget)
+}
+
+// prioritize sets the priority of every recommendation and the reasons behind it
+func (patcher Patcher) prioritize(patches map[string]patching.PatchInfo) {
+	for dependency, patch := range patches {
+		patches[dependency] = patcher.Exploitability.Prioritize(patch)
+	}
+}
+
+// generateUpgrades proposes the manifest constraint changes (package.json, requirements.txt, pyproject.toml)
+// for the direct dependencies that received an update.
+func (patcher Patcher) generateUpgrades(dependencies []sbomTypes.WorkSpaceDependency, patches map[string]patching.PatchInfo) []patching.Upgrades {
+	upgrades := []patching.Upgrades{}
+	for _, dependency := range dependencies {
+		patch, ok := patches[patcher.Ecosystem.Key(dependency.Name, dependency.Version)]
+		if !ok || patch.UpdateVersion() == "" {
+			continue
+		}
+		newConstraint, reapply, err := patcher.Ecosystem.UpdateConstraint(dependency.Constraint, patch.UpdateVersion())
+		if err != nil {
+			continue
+		}
+		upgrades = append(upgrades, patching.Upgrades{
+			Name:          dependency.Name,
+			OldConstraint: dependency.Constraint,
+			NewConstraint: newConstraint,
+			Reapply:       reapply,
+		})
+	}
+	return upgrades
+}
@@ -0,0 +1,88 @@
+package patch
+
+import (
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+	vulnerabilityFinder "github.com/CodeClarityCE/plugin-sca-vuln-finder/src/types"
+)
+
+// vulnerabilityView inverts the per-dependency results of a workspace into a per-vulnerability view,
+// listing for every vulnerability the direct dependencies that have to change to fix its occurrences.
+func (patcher Patcher) vulnerabilityView(patches ...map[string]patching.PatchInfo) map[string]patching.VulnerabilityPatchInfo {
+	view := map[string]patching.VulnerabilityPatchInfo{}
+
+	for _, patchMap := range patches {
+		for dependency, patch := range patchMap {
+			name, version := patcher.Ecosystem.SplitKey(dependency)
+			occurence := func(vulnerabilityId string) patching.VulnerabilityOccurencePatchInfo {
+				if _, ok := view[vulnerabilityId]; !ok {
+					view[vulnerabilityId] = patching.VulnerabilityPatchInfo{
+						Patches: map[string]patching.VulnerabilityOccurencePatchInfo{},
+					}
+				}
+				if occurence, ok := view[vulnerabilityId].Patches[dependency]; ok {
+					return occurence
+				}
+				return patching.VulnerabilityOccurencePatchInfo{
+					DirectDepName:             name,
+					DirectDepInstalledVersion: version,
+					DirectDepUpgradeVersion:   patch.UpdateVersion(),
+					IntroducedOccurences:      []vulnerabilityFinder.Vulnerability{},
+					UnPatchedOccurences:       []vulnerabilityFinder.Vulnerability{},
+					PatchedOccurences:         []vulnerabilityFinder.Vulnerability{},
+				}
+			}
+
+			for _, toPatch := range patch.Patchable {
+				info := occurence(toPatch.Vulnerability.VulnerabilityId)
+				info.PatchedOccurences = append(info.PatchedOccurences, toPatch.Vulnerability)
+				view[toPatch.Vulnerability.VulnerabilityId].Patches[dependency] = info
+			}
+			for _, toPatch := range patch.Unpatchable {
+				info := occurence(toPatch.Vulnerability.VulnerabilityId)
+				info.UnPatchedOccurences = append(info.UnPatchedOccurences, toPatch.Vulnerability)
+				view[toPatch.Vulnerability.VulnerabilityId].Patches[dependency] = info
+			}
+			for _, toPatch := range patch.Introduced {
+				info := occurence(toPatch.Vulnerability.VulnerabilityId)
+				info.IntroducedOccurences = append(info.IntroducedOccurences, toPatch.Vulnerability)
+				view[toPatch.Vulnerability.VulnerabilityId].Patches[dependency] = info
+			}
+		}
+	}
+
+	for vulnerabilityId, vulnerability := range view {
+		patched, unpatched, introduced := 0, 0, 0
+		for dependency, info := range vulnerability.Patches {
+			info.PatchType = occurencePatchType(len(info.PatchedOccurences), len(info.UnPatchedOccurences)+len(info.IntroducedOccurences))
+			vulnerability.Patches[dependency] = info
+			patched += len(info.PatchedOccurences)
+			unpatched += len(info.UnPatchedOccurences)
+			introduced += len(info.IntroducedOccurences)
+		}
+
+		switch {
+		case introduced == 0:
+			vulnerability.IntroductionType = patching.ExistedBefore
+		case patched+unpatched == 0:
+			vulnerability.IntroductionType = patching.NewlyIntroduced
+		default:
+			vulnerability.IntroductionType = patching.Mixed
+		}
+		vulnerability.PatchType = occurencePatchType(patched, unpatched+introduced)
+		view[vulnerabilityId] = vulnerability
+	}
+
+	return view
+}
+
+// occurencePatchType tells whether the occurrences left after the upgrades leave a vulnerability fixed
+func occurencePatchType(patched int, remaining int) patching.PatchType {
+	switch {
+	case remaining == 0:
+		return patching.FULL
+	case patched == 0:
+		return patching.NONE
+	default:
+		return patching.PARTIAL
+	}
+}
@@ -0,0 +1,73 @@
+package patch
+
+import (
+	"testing"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/ecosystem"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+	vulnerabilityFinder "github.com/CodeClarityCE/plugin-sca-vuln-finder/src/types"
+	"github.com/CodeClarityCE/utility-node-semver/versions"
+)
+
+func mockToPatch(vulnerabilityId string, dependency string) patching.ToPatch {
+	return patching.ToPatch{Vulnerability: vulnerabilityFinder.Vulnerability{
+		VulnerabilityId:    vulnerabilityId,
+		AffectedDependency: dependency,
+	}}
+}
+
+func TestVulnerabilityView(t *testing.T) {
+	patcher := Patcher{Ecosystem: ecosystem.Npm{}}
+	patches := map[string]patching.PatchInfo{
+		"express@4.17.1": {
+			IsPatchable: patching.PARTIAL,
+			Patchable:   []patching.ToPatch{mockToPatch("CVE-2022-24999", "qs")},
+			Unpatchable: []patching.ToPatch{mockToPatch("CVE-2024-45590", "body-parser")},
+			Introduced:  []patching.ToPatch{mockToPatch("CVE-2024-43796", "express")},
+		},
+	}
+	devPatches := map[string]patching.PatchInfo{
+		"body-parser@1.19.0": {
+			IsPatchable: patching.FULL,
+			Patchable:   []patching.ToPatch{mockToPatch("CVE-2024-45590", "body-parser")},
+		},
+	}
+
+	view := patcher.vulnerabilityView(patches, devPatches)
+
+	if view["CVE-2022-24999"].PatchType != patching.FULL || view["CVE-2022-24999"].IntroductionType != patching.ExistedBefore {
+		t.Errorf("Expected CVE-2022-24999 to be fully patched, got %+v", view["CVE-2022-24999"])
+	}
+	bodyParser := view["CVE-2024-45590"]
+	if bodyParser.PatchType != patching.PARTIAL || len(bodyParser.Patches) != 2 {
+		t.Errorf("Expected CVE-2024-45590 to be partially patched through 2 dependencies, got %+v", bodyParser)
+	}
+	if bodyParser.Patches["express@4.17.1"].DirectDepName != "express" || bodyParser.Patches["express@4.17.1"].PatchType != patching.NONE {
+		t.Errorf("Unexpected express occurence %+v", bodyParser.Patches["express@4.17.1"])
+	}
+	if view["CVE-2024-43796"].IntroductionType != patching.NewlyIntroduced || view["CVE-2024-43796"].PatchType != patching.NONE {
+		t.Errorf("Expected CVE-2024-43796 to be newly introduced, got %+v", view["CVE-2024-43796"])
+	}
+}
+
+func TestWorkspaceSeverityDistributions(t *testing.T) {
+	critical := mockToPatch("CVE-2021-44906", "minimist")
+	critical.Vulnerability.Severity.Severity = 9.8
+	high := mockToPatch("CVE-2022-24999", "qs")
+	high.Vulnerability.Severity.SeverityClass = "HIGH"
+
+	// minimist is reached through both dependencies, only one of them removes it
+	patches := map[string]patching.PatchInfo{
+		"a@1.0.0": {IsPatchable: patching.FULL, Update: versions.Semver{Version: "2.0.0"}, Patchable: []patching.ToPatch{critical, high}},
+		"b@1.0.0": {IsPatchable: patching.NONE, Unpatchable: []patching.ToPatch{critical}},
+	}
+	setSeverityDistributions(patches)
+	before, after := workspaceSeverityDistributions(patches)
+
+	if patches["a@1.0.0"].SeverityDist.Critical != 1 || patches["a@1.0.0"].AfterUpgradeSeverityDist != (patching.SeverityDist{}) {
+		t.Errorf("Unexpected distributions for a: %+v %+v", patches["a@1.0.0"].SeverityDist, patches["a@1.0.0"].AfterUpgradeSeverityDist)
+	}
+	if before != (patching.SeverityDist{Critical: 1, High: 1}) || after != (patching.SeverityDist{Critical: 1}) {
+		t.Errorf("Unexpected workspace distributions: %+v %+v", before, after)
+	}
+}
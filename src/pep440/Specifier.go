@@ -0,0 +1,329 @@
+package pep440
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Operator is a PEP 440 version comparison operator.
+type Operator string
+
+const (
+	COMPATIBLE    Operator = "~="
+	EQUAL         Operator = "=="
+	NOT_EQUAL     Operator = "!="
+	LESS_EQUAL    Operator = "<="
+	GREATER_EQUAL Operator = ">="
+	LESS          Operator = "<"
+	GREATER       Operator = ">"
+	ARBITRARY     Operator = "==="
+)
+
+// operators is ordered so that longer operators are matched first.
+var operators = []Operator{ARBITRARY, COMPATIBLE, EQUAL, NOT_EQUAL, LESS_EQUAL, GREATER_EQUAL, LESS, GREATER}
+
+// Specifier is a single version clause such as ">=1.2" or "==1.4.*".
+type Specifier struct {
+	Operator Operator
+	Version  string
+	Wildcard bool
+	parsed   Version
+}
+
+// SpecifierSet is a comma separated list of specifiers that must all match.
+type SpecifierSet []Specifier
+
+// ParseSpecifier parses a single version clause.
+func ParseSpecifier(specifier string) (Specifier, error) {
+	specifier = strings.TrimSpace(specifier)
+	for _, operator := range operators {
+		if !strings.HasPrefix(specifier, string(operator)) {
+			continue
+		}
+		version := strings.TrimSpace(strings.TrimPrefix(specifier, string(operator)))
+		parsed := Specifier{Operator: operator, Version: version}
+		if operator == ARBITRARY {
+			return parsed, nil
+		}
+		if strings.HasSuffix(version, ".*") {
+			if operator != EQUAL && operator != NOT_EQUAL {
+				return Specifier{}, fmt.Errorf("invalid pep440 specifier: %q", specifier)
+			}
+			parsed.Wildcard = true
+			version = strings.TrimSuffix(version, ".*")
+			parsed.Version = version
+		}
+		version_parsed, err := Parse(version)
+		if err != nil {
+			return Specifier{}, err
+		}
+		if operator == COMPATIBLE && len(version_parsed.Release) < 2 {
+			return Specifier{}, fmt.Errorf("invalid pep440 specifier: %q", specifier)
+		}
+		parsed.parsed = version_parsed
+		return parsed, nil
+	}
+	return Specifier{}, fmt.Errorf("invalid pep440 specifier: %q", specifier)
+}
+
+// ParseSpecifierSet parses a comma separated specifier list such as ">=1.0,<2".
+// An empty string or "*" yields an empty set, which matches every version.
+func ParseSpecifierSet(specifiers string) (SpecifierSet, error) {
+	set := SpecifierSet{}
+	specifiers = strings.TrimSpace(specifiers)
+	if specifiers == "" || specifiers == "*" {
+		return set, nil
+	}
+	for _, clause := range strings.Split(specifiers, ",") {
+		if strings.TrimSpace(clause) == "" {
+			continue
+		}
+		specifier, err := ParseSpecifier(clause)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, specifier)
+	}
+	return set, nil
+}
+
+// String returns the specifier in its canonical textual form.
+func (s Specifier) String() string {
+	if s.Wildcard {
+		return string(s.Operator) + s.Version + ".*"
+	}
+	return string(s.Operator) + s.Version
+}
+
+// String returns the specifier set joined by commas.
+func (set SpecifierSet) String() string {
+	clauses := make([]string, len(set))
+	for i, specifier := range set {
+		clauses[i] = specifier.String()
+	}
+	return strings.Join(clauses, ",")
+}
+
+// IsPrerelease reports whether the specifier explicitly names a pre-release,
+// in which case pre-releases are allowed to match it.
+func (s Specifier) IsPrerelease() bool {
+	if s.Operator == ARBITRARY || s.Operator == NOT_EQUAL {
+		return false
+	}
+	return s.parsed.IsPrerelease()
+}
+
+// Contains reports whether the version satisfies the specifier.
+// Pre-release filtering is left to SpecifierSet.
+func (s Specifier) Contains(version Version) bool {
+	switch s.Operator {
+	case ARBITRARY:
+		return strings.EqualFold(version.String(), s.Version)
+	case COMPATIBLE:
+		prefix := Version{Epoch: s.parsed.Epoch, Release: s.parsed.Release[:len(s.parsed.Release)-1]}
+		return version.Public().Compare(s.parsed) >= 0 && matchesPrefix(version, prefix)
+	case EQUAL:
+		if s.Wildcard {
+			return matchesPrefix(version, s.parsed)
+		}
+		if len(s.parsed.Local) == 0 {
+			return version.Public().Equal(s.parsed)
+		}
+		return version.Equal(s.parsed)
+	case NOT_EQUAL:
+		return !Specifier{Operator: EQUAL, Version: s.Version, Wildcard: s.Wildcard, parsed: s.parsed}.Contains(version)
+	case LESS_EQUAL:
+		return version.Public().Compare(s.parsed) <= 0
+	case GREATER_EQUAL:
+		return version.Public().Compare(s.parsed) >= 0
+	case LESS:
+		if !version.Public().LessThan(s.parsed) {
+			return false
+		}
+		// "<V" must not match a pre-release of V unless V itself is a pre-release.
+		if !s.parsed.IsPrerelease() && version.IsPrerelease() && version.Base().Equal(s.parsed.Base()) {
+			return false
+		}
+		return true
+	case GREATER:
+		if !s.parsed.LessThan(version.Public()) {
+			return false
+		}
+		// ">V" must not match a post-release or a local version of V.
+		if !s.parsed.IsPostRelease() && version.IsPostRelease() && version.Base().Equal(s.parsed.Base()) {
+			return false
+		}
+		if len(version.Local) > 0 && version.Public().Equal(s.parsed) {
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// matchesPrefix reports whether the release segment of version starts with the release of prefix.
+func matchesPrefix(version Version, prefix Version) bool {
+	if version.Epoch != prefix.Epoch {
+		return false
+	}
+	for i, number := range prefix.Release {
+		if version.segment(i) != number {
+			return false
+		}
+	}
+	return true
+}
+
+// Contains reports whether the version satisfies every specifier of the set.
+// Pre-releases only match when allowPrereleases is set or when one of the
+// specifiers explicitly names a pre-release.
+func (set SpecifierSet) Contains(version Version, allowPrereleases bool) bool {
+	if version.IsPrerelease() && !allowPrereleases && !set.hasPrerelease() {
+		return false
+	}
+	for _, specifier := range set {
+		if !specifier.Contains(version) {
+			return false
+		}
+	}
+	return true
+}
+
+func (set SpecifierSet) hasPrerelease() bool {
+	return slices.ContainsFunc(set, Specifier.IsPrerelease)
+}
+
+// Filter returns the versions that satisfy the set, keeping their order.
+// As in pip, pre-releases are accepted if nothing else matches.
+func (set SpecifierSet) Filter(versions []Version, allowPrereleases bool) []Version {
+	filtered := []Version{}
+	prereleases := []Version{}
+	for _, version := range versions {
+		if set.Contains(version, allowPrereleases) {
+			filtered = append(filtered, version)
+		} else if version.IsPrerelease() && set.Contains(version, true) {
+			prereleases = append(prereleases, version)
+		}
+	}
+	if len(filtered) == 0 {
+		return prereleases
+	}
+	return filtered
+}
+
+// MaxSatisfying returns the highest version that satisfies the set.
+func (set SpecifierSet) MaxSatisfying(versions []Version, allowPrereleases bool) (Version, error) {
+	filtered := set.Filter(versions, allowPrereleases)
+	if len(filtered) == 0 {
+		return Version{}, fmt.Errorf("no version satisfies %q", set.String())
+	}
+	return slices.MaxFunc(filtered, Version.Compare), nil
+}
+
+// Sort sorts the versions in ascending order.
+func Sort(versions []Version) {
+	slices.SortFunc(versions, Version.Compare)
+}
+
+// SortStrings parses and sorts version strings in ascending order,
+// silently dropping the ones that are not valid PEP 440 versions.
+func SortStrings(versions []string) []string {
+	parsed := []Version{}
+	raw := map[string]string{}
+	for _, version := range versions {
+		version_parsed, err := Parse(version)
+		if err != nil {
+			continue
+		}
+		parsed = append(parsed, version_parsed)
+		raw[version_parsed.String()] = version
+	}
+	Sort(parsed)
+	sorted := make([]string, len(parsed))
+	for i, version := range parsed {
+		sorted[i] = raw[version.String()]
+	}
+	return sorted
+}
+
+// requirementPattern splits a PEP 508 requirement into its name, extras and version specifiers.
+var requirementPattern = regexp.MustCompile(`^\s*([A-Za-z0-9][A-Za-z0-9._-]*)\s*(\[[^\]]*\])?\s*\(?([^;()]*)\)?\s*(?:;.*)?$`)
+
+// ParseRequirement splits a PEP 508 requirement string such as
+// "requests[security]>=2.8.1; python_version < '3.8'" into its normalized
+// package name and its specifier set. Markers and extras are ignored.
+func ParseRequirement(requirement string) (string, SpecifierSet, error) {
+	match := requirementPattern.FindStringSubmatch(requirement)
+	if match == nil {
+		return "", nil, fmt.Errorf("invalid pep508 requirement: %q", requirement)
+	}
+	set, err := ParseSpecifierSet(match[3])
+	if err != nil {
+		return "", nil, err
+	}
+	return NormalizeName(match[1]), set, nil
+}
+
+var nameSeparators = regexp.MustCompile(`[-_.]+`)
+
+// NormalizeName returns the PEP 503 normalized form of a project name.
+func NormalizeName(name string) string {
+	return strings.ToLower(nameSeparators.ReplaceAllString(strings.TrimSpace(name), "-"))
+}
+
+// Bump rewrites the set so that it admits version while keeping the style and
+// precision of each clause: pins move to the new version, lower bounds are
+// raised to it and upper bounds that would exclude it are moved past it.
+func (set SpecifierSet) Bump(version Version) SpecifierSet {
+	bumped := SpecifierSet{}
+	public := version.Public()
+	for _, specifier := range set {
+		switch specifier.Operator {
+		case EQUAL, ARBITRARY:
+			if specifier.Wildcard {
+				prefix := truncate(public, len(specifier.parsed.Release))
+				bumped = append(bumped, newSpecifier(EQUAL, prefix, true))
+			} else {
+				bumped = append(bumped, newSpecifier(EQUAL, public, false))
+			}
+		case COMPATIBLE:
+			precision := max(len(specifier.parsed.Release), 2)
+			bumped = append(bumped, newSpecifier(COMPATIBLE, truncate(public, precision), false))
+		case GREATER_EQUAL, GREATER:
+			if specifier.Contains(public) {
+				bumped = append(bumped, newSpecifier(GREATER_EQUAL, public, false))
+			} else {
+				bumped = append(bumped, specifier)
+			}
+		case LESS_EQUAL, LESS:
+			if specifier.Contains(public) {
+				bumped = append(bumped, specifier)
+				continue
+			}
+			precision := max(len(specifier.parsed.Release), 1)
+			ceiling := truncate(public, precision)
+			ceiling.Release[precision-1]++
+			bumped = append(bumped, newSpecifier(LESS, ceiling, false))
+		case NOT_EQUAL:
+			if specifier.Contains(public) {
+				bumped = append(bumped, specifier)
+			}
+		}
+	}
+	return bumped
+}
+
+// truncate returns the base of version with exactly precision release segments.
+func truncate(version Version, precision int) Version {
+	release := make([]int, precision)
+	for i := range release {
+		release[i] = version.segment(i)
+	}
+	return Version{Epoch: version.Epoch, Release: release}
+}
+
+func newSpecifier(operator Operator, version Version, wildcard bool) Specifier {
+	return Specifier{Operator: operator, Version: version.String(), Wildcard: wildcard, parsed: version}
+}
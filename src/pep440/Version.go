@@ -0,0 +1,280 @@
+package pep440
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// versionPattern is the canonical PEP 440 version pattern, as used by the
+// reference implementation in pypa/packaging.
+var versionPattern = regexp.MustCompile(`(?i)^\s*v?` +
+	`(?:(?P<epoch>[0-9]+)!)?` +
+	`(?P<release>[0-9]+(?:\.[0-9]+)*)` +
+	`(?P<pre>[-_\.]?(?P<pre_l>alpha|a|beta|b|preview|pre|c|rc)[-_\.]?(?P<pre_n>[0-9]+)?)?` +
+	`(?P<post>(?:-(?P<post_n1>[0-9]+))|(?:[-_\.]?(?P<post_l>post|rev|r)[-_\.]?(?P<post_n2>[0-9]+)?))?` +
+	`(?P<dev>[-_\.]?(?P<dev_l>dev)[-_\.]?(?P<dev_n>[0-9]+)?)?` +
+	`(?:\+(?P<local>[a-z0-9]+(?:[-_\.][a-z0-9]+)*))?\s*$`)
+
+// PreRelease holds the pre-release segment of a version (a, b or rc).
+type PreRelease struct {
+	Label  string
+	Number int
+}
+
+// Version is a parsed PEP 440 version.
+type Version struct {
+	Epoch   int
+	Release []int
+	Pre     *PreRelease
+	Post    *int
+	Dev     *int
+	Local   []string
+}
+
+// Parse parses a PEP 440 version string.
+// It returns an error if the string is not a valid PEP 440 version.
+func Parse(version string) (Version, error) {
+	match := versionPattern.FindStringSubmatch(version)
+	if match == nil {
+		return Version{}, fmt.Errorf("invalid pep440 version: %q", version)
+	}
+	group := func(name string) string {
+		return match[versionPattern.SubexpIndex(name)]
+	}
+
+	parsed := Version{}
+	if epoch := group("epoch"); epoch != "" {
+		parsed.Epoch, _ = strconv.Atoi(epoch)
+	}
+	for _, part := range strings.Split(group("release"), ".") {
+		number, _ := strconv.Atoi(part)
+		parsed.Release = append(parsed.Release, number)
+	}
+	if label := group("pre_l"); label != "" {
+		number, _ := strconv.Atoi(group("pre_n"))
+		parsed.Pre = &PreRelease{Label: normalizePreLabel(label), Number: number}
+	}
+	if group("post") != "" {
+		number := group("post_n1")
+		if number == "" {
+			number = group("post_n2")
+		}
+		post, _ := strconv.Atoi(number)
+		parsed.Post = &post
+	}
+	if group("dev") != "" {
+		dev, _ := strconv.Atoi(group("dev_n"))
+		parsed.Dev = &dev
+	}
+	if local := group("local"); local != "" {
+		parsed.Local = strings.FieldsFunc(strings.ToLower(local), func(r rune) bool {
+			return r == '.' || r == '-' || r == '_'
+		})
+	}
+	return parsed, nil
+}
+
+// MustParse is like Parse but panics if the version cannot be parsed.
+func MustParse(version string) Version {
+	parsed, err := Parse(version)
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+func normalizePreLabel(label string) string {
+	switch strings.ToLower(label) {
+	case "alpha", "a":
+		return "a"
+	case "beta", "b":
+		return "b"
+	default:
+		return "rc"
+	}
+}
+
+// String returns the normalized form of the version.
+func (v Version) String() string {
+	builder := strings.Builder{}
+	if v.Epoch != 0 {
+		builder.WriteString(strconv.Itoa(v.Epoch) + "!")
+	}
+	builder.WriteString(v.releaseString())
+	if v.Pre != nil {
+		builder.WriteString(v.Pre.Label + strconv.Itoa(v.Pre.Number))
+	}
+	if v.Post != nil {
+		builder.WriteString(".post" + strconv.Itoa(*v.Post))
+	}
+	if v.Dev != nil {
+		builder.WriteString(".dev" + strconv.Itoa(*v.Dev))
+	}
+	if len(v.Local) > 0 {
+		builder.WriteString("+" + strings.Join(v.Local, "."))
+	}
+	return builder.String()
+}
+
+func (v Version) releaseString() string {
+	parts := make([]string, len(v.Release))
+	for i, number := range v.Release {
+		parts[i] = strconv.Itoa(number)
+	}
+	return strings.Join(parts, ".")
+}
+
+// IsPrerelease reports whether the version is a pre-release or a development release.
+func (v Version) IsPrerelease() bool {
+	return v.Pre != nil || v.Dev != nil
+}
+
+// IsPostRelease reports whether the version is a post-release.
+func (v Version) IsPostRelease() bool {
+	return v.Post != nil
+}
+
+// Public returns the version without its local segment.
+func (v Version) Public() Version {
+	v.Local = nil
+	return v
+}
+
+// Base returns the epoch and release segments of the version only.
+func (v Version) Base() Version {
+	return Version{Epoch: v.Epoch, Release: v.Release}
+}
+
+// Major returns the first release segment of the version.
+func (v Version) Major() int {
+	return v.segment(0)
+}
+
+// Minor returns the second release segment of the version, or 0 if it is absent.
+func (v Version) Minor() int {
+	return v.segment(1)
+}
+
+func (v Version) segment(index int) int {
+	if index < len(v.Release) {
+		return v.Release[index]
+	}
+	return 0
+}
+
+// Compare returns -1, 0 or 1 depending on whether v sorts before, equal to or after other.
+func (v Version) Compare(other Version) int {
+	if c := compareInt(v.Epoch, other.Epoch); c != 0 {
+		return c
+	}
+	if c := compareRelease(v.Release, other.Release); c != 0 {
+		return c
+	}
+	if c := compareInt(v.preRank(), other.preRank()); c != 0 {
+		return c
+	}
+	if v.Pre != nil && other.Pre != nil {
+		if c := strings.Compare(v.Pre.Label, other.Pre.Label); c != 0 {
+			return c
+		}
+		if c := compareInt(v.Pre.Number, other.Pre.Number); c != 0 {
+			return c
+		}
+	}
+	if c := compareOptional(v.Post, other.Post, -1); c != 0 {
+		return c
+	}
+	if c := compareOptional(v.Dev, other.Dev, 1); c != 0 {
+		return c
+	}
+	return compareLocal(v.Local, other.Local)
+}
+
+// LessThan reports whether v sorts before other.
+func (v Version) LessThan(other Version) bool {
+	return v.Compare(other) < 0
+}
+
+// Equal reports whether v and other are the same version.
+func (v Version) Equal(other Version) bool {
+	return v.Compare(other) == 0
+}
+
+// preRank orders the pre-release slot: a dev release of a final version
+// sorts before any pre-release, and a final release sorts after all of them.
+func (v Version) preRank() int {
+	if v.Pre == nil && v.Post == nil && v.Dev != nil {
+		return -1
+	}
+	if v.Pre != nil {
+		return 0
+	}
+	return 1
+}
+
+func compareInt(a int, b int) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+// compareOptional compares two optional segments, a missing segment
+// sorting before (missing = -1) or after (missing = 1) any present value.
+func compareOptional(a *int, b *int, missing int) int {
+	if a == nil && b == nil {
+		return 0
+	}
+	if a == nil {
+		return missing
+	}
+	if b == nil {
+		return -missing
+	}
+	return compareInt(*a, *b)
+}
+
+func compareRelease(a []int, b []int) int {
+	length := max(len(a), len(b))
+	for i := range length {
+		x, y := 0, 0
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if c := compareInt(x, y); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareLocal compares local version labels: numeric parts sort after
+// alphanumeric ones and a shorter label sorts first when it is a prefix.
+func compareLocal(a []string, b []string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		x, errX := strconv.Atoi(a[i])
+		y, errY := strconv.Atoi(b[i])
+		switch {
+		case errX == nil && errY == nil:
+			if c := compareInt(x, y); c != 0 {
+				return c
+			}
+		case errX == nil:
+			return 1
+		case errY == nil:
+			return -1
+		default:
+			if c := strings.Compare(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return compareInt(len(a), len(b))
+}
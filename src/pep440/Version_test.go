@@ -0,0 +1,170 @@
+package pep440
+
+import (
+	"testing"
+)
+
+func TestParseNormalizes(t *testing.T) {
+	tests := map[string]string{
+		"1.0":             "1.0",
+		"v1.0.0":          "1.0.0",
+		"1!2.0":           "1!2.0",
+		"1.0alpha1":       "1.0a1",
+		"1.0-beta.2":      "1.0b2",
+		"1.0c1":           "1.0rc1",
+		"1.0-1":           "1.0.post1",
+		"1.0.rev2":        "1.0.post2",
+		"1.0.dev":         "1.0.dev0",
+		"1.0+Ubuntu-1":    "1.0+ubuntu.1",
+		"2.0.0rc1.post2":  "2.0.0rc1.post2",
+		" 1.0.post3.dev4": "1.0.post3.dev4",
+	}
+	for input, expected := range tests {
+		version, err := Parse(input)
+		if err != nil {
+			t.Errorf("Parse(%q) returned error: %v", input, err)
+			continue
+		}
+		if version.String() != expected {
+			t.Errorf("Parse(%q) = %q, expected %q", input, version.String(), expected)
+		}
+	}
+
+	if _, err := Parse("not-a-version"); err == nil {
+		t.Errorf("Expected an error for an invalid version")
+	}
+}
+
+func TestCompareOrdering(t *testing.T) {
+	// Taken from the ordering example of PEP 440.
+	ordered := []string{
+		"1.0.dev456",
+		"1.0a1",
+		"1.0a2.dev456",
+		"1.0a12.dev456",
+		"1.0a12",
+		"1.0b1.dev456",
+		"1.0b2",
+		"1.0b2.post345.dev456",
+		"1.0b2.post345",
+		"1.0rc1.dev456",
+		"1.0rc1",
+		"1.0",
+		"1.0+abc.5",
+		"1.0+abc.7",
+		"1.0+5",
+		"1.0.post456.dev34",
+		"1.0.post456",
+		"1.0.15",
+		"1.1.dev1",
+		"1!0.1",
+	}
+	for i := 0; i < len(ordered)-1; i++ {
+		a, b := MustParse(ordered[i]), MustParse(ordered[i+1])
+		if a.Compare(b) >= 0 {
+			t.Errorf("Expected %s < %s", ordered[i], ordered[i+1])
+		}
+		if b.Compare(a) <= 0 {
+			t.Errorf("Expected %s > %s", ordered[i+1], ordered[i])
+		}
+	}
+
+	if !MustParse("1.0").Equal(MustParse("1.0.0")) {
+		t.Errorf("Expected 1.0 == 1.0.0")
+	}
+}
+
+func TestSpecifierSetContains(t *testing.T) {
+	tests := []struct {
+		specifiers  string
+		version     string
+		prereleases bool
+		expected    bool
+	}{
+		{"~=2.2", "2.3", false, true},
+		{"~=2.2", "3.0", false, false},
+		{"~=1.4.5", "1.4.9", false, true},
+		{"~=1.4.5", "1.5.0", false, false},
+		{"==1.1.*", "1.1.post1", false, true},
+		{"==1.1.*", "1.2", false, false},
+		{"==1.1", "1.1+local", false, true},
+		{"!=1.1", "1.1.0", false, false},
+		{">=1.0,<2", "1.9", false, true},
+		{">=1.0,<2", "2.0rc1", false, false},
+		{">=1.0,<2", "2.0rc1", true, false},
+		{"<2.0rc2", "2.0rc1", false, true},
+		{">1.7", "1.7.post2", false, false},
+		{">1.7.post2", "1.7.post3", false, true},
+		{">=1.0", "2.0a1", false, false},
+		{">=1.0", "2.0a1", true, true},
+		{"", "3.0", false, true},
+		{"===1.0", "1.0", false, true},
+		{"===1.0", "1.0.0", false, false},
+	}
+	for _, test := range tests {
+		set, err := ParseSpecifierSet(test.specifiers)
+		if err != nil {
+			t.Errorf("ParseSpecifierSet(%q) returned error: %v", test.specifiers, err)
+			continue
+		}
+		got := set.Contains(MustParse(test.version), test.prereleases)
+		if got != test.expected {
+			t.Errorf("%q contains %q (prereleases=%t) = %t, expected %t", test.specifiers, test.version, test.prereleases, got, test.expected)
+		}
+	}
+}
+
+func TestFilterFallsBackToPrereleases(t *testing.T) {
+	set, _ := ParseSpecifierSet(">=2.5")
+	versions := []Version{MustParse("2.0"), MustParse("3.0b1"), MustParse("3.0rc1")}
+
+	filtered := set.Filter(versions, false)
+	if len(filtered) != 2 {
+		t.Fatalf("Expected 2 pre-releases, got %d", len(filtered))
+	}
+
+	max, err := set.MaxSatisfying(versions, false)
+	if err != nil || max.String() != "3.0rc1" {
+		t.Errorf("Expected 3.0rc1, got %s (%v)", max, err)
+	}
+}
+
+func TestBump(t *testing.T) {
+	tests := []struct {
+		specifiers string
+		version    string
+		expected   string
+	}{
+		{"==2.19.1", "2.31.0", "==2.31.0"},
+		{"~=2.19", "2.31.0", "~=2.31"},
+		{"~=2.19.0", "2.31.0", "~=2.31.0"},
+		{">=2.19,<3", "2.31.0", ">=2.31.0,<3"},
+		{">=1.0,<2", "2.1", ">=2.1,<3"},
+		{">=1.0,<1.5", "1.6.2", ">=1.6.2,<1.7"},
+		{"==1.*", "2.0.1", "==2.*"},
+		{">=1.0,!=2.0.1", "2.0.1", ">=2.0.1"},
+	}
+	for _, test := range tests {
+		set, _ := ParseSpecifierSet(test.specifiers)
+		got := set.Bump(MustParse(test.version)).String()
+		if got != test.expected {
+			t.Errorf("Bump(%q, %q) = %q, expected %q", test.specifiers, test.version, got, test.expected)
+		}
+	}
+}
+
+func TestParseRequirement(t *testing.T) {
+	name, set, err := ParseRequirement("Requests[security] >=2.8.1, ==2.8.* ; python_version < '3.8'")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if name != "requests" {
+		t.Errorf("Expected name 'requests', got '%s'", name)
+	}
+	if set.String() != ">=2.8.1,==2.8.*" {
+		t.Errorf("Expected '>=2.8.1,==2.8.*', got '%s'", set.String())
+	}
+	if NormalizeName("Zope.Interface__x") != "zope-interface-x" {
+		t.Errorf("Unexpected normalized name: %s", NormalizeName("Zope.Interface__x"))
+	}
+}
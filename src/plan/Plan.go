@@ -0,0 +1,366 @@
+package plan
+
+import (
+	"cmp"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/ecosystem"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/exploitability"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+)
+
+// DEFAULT_SEVERITY is the weight of a vulnerability whose severity is unknown,
+// which is the case of the vulnerabilities found while scanning candidate versions
+const DEFAULT_SEVERITY = 5.0
+
+// MAJOR_JUMP_COST is the cost added for every major version crossed by an upgrade
+const MAJOR_JUMP_COST = 2.0
+
+// BREAKING_CHANGE_COST is the cost added when an upgrade may contain breaking changes
+const BREAKING_CHANGE_COST = 1.0
+
+var leadingNumbers = regexp.MustCompile(`^(?:\d+!)?v?(\d+)(?:\.(\d+))?`)
+
+// Optimize builds the remediation plan of a workspace from its per-dependency results.
+// Every upgrade, and every coordinated upgrade group, is weighted by the priority of what it fixes,
+// its severity amplified by what is known of its exploitation, minus the severity it introduces,
+// and scored by that weight divided by a cost growing with its breaking-change risk.
+// The plan holds the upgrades allowed by the budget with the highest total weight, see selectUpgrades,
+// ranked by score, best first.
+func Optimize(workspace patching.Workspace, packageEcosystem ecosystem.Ecosystem, dataset exploitability.Dataset, budget patching.PlanBudget) patching.RemediationPlan {
+	candidates := []patching.PlannedUpgrade{}
+	candidates = append(candidates, patchCandidates(workspace.Patches, packageEcosystem, false)...)
+	candidates = append(candidates, patchCandidates(workspace.DevPatches, packageEcosystem, true)...)
+	candidates = append(candidates, groupCandidates(workspace, packageEcosystem, dataset)...)
+
+	slices.SortStableFunc(candidates, func(a patching.PlannedUpgrade, b patching.PlannedUpgrade) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(b.Priority, a.Priority); c != 0 {
+			return c
+		}
+		return cmp.Compare(candidateName(a), candidateName(b))
+	})
+
+	allowed := []patching.PlannedUpgrade{}
+	for _, candidate := range candidates {
+		if !budget.NoMajors || !candidate.MajorJump {
+			allowed = append(allowed, candidate)
+		}
+	}
+	selected := selectUpgrades(allowed, budget.MaxUpgrades)
+
+	plan := patching.RemediationPlan{
+		Budget:   budget,
+		Upgrades: []patching.PlannedUpgrade{},
+		Excluded: []patching.PlannedUpgrade{},
+	}
+	for _, candidate := range candidates {
+		index := slices.IndexFunc(allowed, func(other patching.PlannedUpgrade) bool { return candidateName(other) == candidateName(candidate) })
+		if index < 0 || !selected[index] {
+			plan.Excluded = append(plan.Excluded, candidate)
+			continue
+		}
+		candidate.Rank = len(plan.Upgrades) + 1
+		plan.SeverityRemoved += candidate.SeverityRemoved - candidate.SeverityIntroduced
+		plan.Upgrades = append(plan.Upgrades, candidate)
+	}
+	return plan
+}
+
+// selection is a set of candidates and what it achieves
+type selection struct {
+	candidates []int
+	upgrades   int
+	weight     float64
+	score      float64
+}
+
+// weightTolerance absorbs the rounding of weight sums when comparing selections
+const weightTolerance = 1e-9
+
+// beats reports whether a selection weighs more than another, or as much with a higher score
+func (a selection) beats(b selection) bool {
+	if a.weight > b.weight+weightTolerance {
+		return true
+	}
+	return a.weight > b.weight-weightTolerance && a.score > b.score+weightTolerance
+}
+
+func (a selection) plus(b selection) selection {
+	return selection{
+		candidates: append(slices.Clone(a.candidates), b.candidates...),
+		upgrades:   a.upgrades + b.upgrades,
+		weight:     a.weight + b.weight,
+		score:      a.score + b.score,
+	}
+}
+
+// selectUpgrades returns the candidates with the highest total weight using at most maxUpgrades
+// dependency upgrades, 0 for no limit, preferring the higher total score among equal selections.
+// A dependency cannot be upgraded twice, so a coordinated group competes with the upgrades
+// of its members. Candidates sharing dependencies form small clusters whose subsets are enumerated,
+// and the clusters are then combined as a knapsack over the number of upgrades.
+func selectUpgrades(candidates []patching.PlannedUpgrade, maxUpgrades int) map[int]bool {
+	capacity := 0
+	for _, candidate := range candidates {
+		capacity += len(candidate.Dependencies)
+	}
+	if maxUpgrades > 0 {
+		capacity = min(capacity, maxUpgrades)
+	}
+
+	// best[c] is the best selection of the clusters seen so far with at most c upgrades
+	best := make([]selection, capacity+1)
+	for _, cluster := range clusters(candidates) {
+		options := clusterSelections(candidates, cluster, capacity)
+		next := slices.Clone(best)
+		for c := range best {
+			for _, option := range options {
+				if option.upgrades > c {
+					continue
+				}
+				if combined := best[c-option.upgrades].plus(option); combined.beats(next[c]) {
+					next[c] = combined
+				}
+			}
+		}
+		best = next
+	}
+
+	selected := map[int]bool{}
+	for _, index := range best[capacity].candidates {
+		selected[index] = true
+	}
+	return selected
+}
+
+// clusters partitions the candidates into groups sharing dependencies, in the order of the candidates
+func clusters(candidates []patching.PlannedUpgrade) [][]int {
+	clusterOf := map[string]int{}
+	result := [][]int{}
+	for index, candidate := range candidates {
+		merged := -1
+		for dependency := range candidate.Dependencies {
+			if cluster, ok := clusterOf[dependency]; ok && cluster != merged {
+				if merged < 0 {
+					merged = cluster
+				} else {
+					// Both clusters hold the dependencies of this candidate, they become one
+					result[merged] = append(result[merged], result[cluster]...)
+					for other, owner := range clusterOf {
+						if owner == cluster {
+							clusterOf[other] = merged
+						}
+					}
+					result[cluster] = nil
+				}
+			}
+		}
+		if merged < 0 {
+			merged = len(result)
+			result = append(result, []int{})
+		}
+		result[merged] = append(result[merged], index)
+		for dependency := range candidate.Dependencies {
+			clusterOf[dependency] = merged
+		}
+	}
+	nonEmpty := [][]int{}
+	for _, cluster := range result {
+		if len(cluster) > 0 {
+			slices.Sort(cluster)
+			nonEmpty = append(nonEmpty, cluster)
+		}
+	}
+	return nonEmpty
+}
+
+// clusterSelections returns, for every number of upgrades up to capacity, the best subset of a cluster
+// whose candidates do not upgrade the same dependency twice
+func clusterSelections(candidates []patching.PlannedUpgrade, cluster []int, capacity int) []selection {
+	bestByUpgrades := map[int]selection{}
+	var enumerate func(i int, current selection, used map[string]bool)
+	enumerate = func(i int, current selection, used map[string]bool) {
+		if current.upgrades > capacity {
+			return
+		}
+		if i == len(cluster) {
+			if existing, ok := bestByUpgrades[current.upgrades]; !ok || current.beats(existing) {
+				bestByUpgrades[current.upgrades] = current
+			}
+			return
+		}
+		enumerate(i+1, current, used)
+
+		candidate := candidates[cluster[i]]
+		for dependency := range candidate.Dependencies {
+			if used[dependency] {
+				return
+			}
+		}
+		for dependency := range candidate.Dependencies {
+			used[dependency] = true
+		}
+		enumerate(i+1, current.plus(selection{
+			candidates: []int{cluster[i]},
+			upgrades:   len(candidate.Dependencies),
+			weight:     candidate.Priority - candidate.SeverityIntroduced,
+			score:      candidate.Score,
+		}), used)
+		for dependency := range candidate.Dependencies {
+			delete(used, dependency)
+		}
+	}
+	enumerate(0, selection{}, map[string]bool{})
+
+	options := []selection{}
+	for _, upgrades := range slices.Sorted(maps.Keys(bestByUpgrades)) {
+		options = append(options, bestByUpgrades[upgrades])
+	}
+	return options
+}
+
+// patchCandidates turns every direct dependency with a recommended version into a plan candidate
+func patchCandidates(patches map[string]patching.PatchInfo, packageEcosystem ecosystem.Ecosystem, dev bool) []patching.PlannedUpgrade {
+	candidates := []patching.PlannedUpgrade{}
+	for dependency, patch := range patches {
+		version := patch.UpdateVersion()
+		if version == "" {
+			continue
+		}
+		removed := severitySum(patch.Patchable)
+		introduced := severitySum(patch.Introduced)
+		if removed-introduced <= 0 {
+			continue
+		}
+		// Results that were not prioritized weigh their severity alone
+		priority := max(patch.Priority, removed)
+		_, installed := packageEcosystem.SplitKey(dependency)
+		majorJumps, breaking := upgradeRisk(installed, version)
+		candidates = append(candidates, newCandidate(map[string]string{dependency: version}, dev, removed, priority, introduced, majorJumps, breaking))
+	}
+	return candidates
+}
+
+// groupCandidates turns every coordinated upgrade group into a single, indivisible plan candidate
+func groupCandidates(workspace patching.Workspace, packageEcosystem ecosystem.Ecosystem, dataset exploitability.Dataset) []patching.PlannedUpgrade {
+	severities := map[string]float64{}
+	for _, patches := range []map[string]patching.PatchInfo{workspace.Patches, workspace.DevPatches} {
+		for _, patch := range patches {
+			for _, toPatch := range slices.Concat(patch.Patchable, patch.Unpatchable) {
+				severities[toPatch.Vulnerability.VulnerabilityId] = severityOf(toPatch)
+			}
+		}
+	}
+
+	candidates := []patching.PlannedUpgrade{}
+	for _, group := range workspace.CoordinatedUpgrades {
+		removed, priority := 0.0, 0.0
+		for _, vulnerability := range group.Vulnerabilities {
+			vulnerabilityId, _, _ := strings.Cut(vulnerability, ":")
+			severity, ok := severities[vulnerabilityId]
+			if !ok {
+				severity = DEFAULT_SEVERITY
+			}
+			removed += severity
+			priority += severity * dataset.Multiplier(vulnerabilityId)
+		}
+		majorJumps, breaking := 0, false
+		dev := true
+		for dependency, version := range group.Upgrades {
+			_, installed := packageEcosystem.SplitKey(dependency)
+			jumps, risky := upgradeRisk(installed, version)
+			majorJumps += jumps
+			breaking = breaking || risky
+			if _, ok := workspace.DevPatches[dependency]; !ok {
+				dev = false
+			}
+		}
+		candidates = append(candidates, newCandidate(group.Upgrades, dev, removed, priority, 0, majorJumps, breaking))
+	}
+	return candidates
+}
+
+func newCandidate(dependencies map[string]string, dev bool, removed float64, priority float64, introduced float64, majorJumps int, breaking bool) patching.PlannedUpgrade {
+	cost := float64(len(dependencies)) + float64(majorJumps)*MAJOR_JUMP_COST
+	if breaking {
+		cost += BREAKING_CHANGE_COST
+	}
+	return patching.PlannedUpgrade{
+		Dependencies:       dependencies,
+		Dev:                dev,
+		SeverityRemoved:    removed,
+		Priority:           priority,
+		SeverityIntroduced: introduced,
+		MajorJump:          majorJumps > 0,
+		PotentialBreaking:  breaking,
+		Cost:               cost,
+		Score:              (priority - introduced) / cost,
+	}
+}
+
+func severitySum(vulnerabilities []patching.ToPatch) float64 {
+	sum := 0.0
+	for _, vulnerability := range vulnerabilities {
+		sum += severityOf(vulnerability)
+	}
+	return sum
+}
+
+func severityOf(vulnerability patching.ToPatch) float64 {
+	if vulnerability.Vulnerability.Severity.Severity > 0 {
+		return vulnerability.Vulnerability.Severity.Severity
+	}
+	return DEFAULT_SEVERITY
+}
+
+// upgradeRisk returns the number of major versions crossed between two versions,
+// and whether the upgrade may contain breaking changes: a major jump,
+// or a minor jump in the 0.x range where minors are allowed to break.
+func upgradeRisk(installed string, upgrade string) (int, bool) {
+	installedMajor, installedMinor, ok := leadingVersion(installed)
+	upgradeMajor, upgradeMinor, okUpgrade := leadingVersion(upgrade)
+	if !ok || !okUpgrade {
+		return 0, false
+	}
+	majorJumps := max(upgradeMajor-installedMajor, 0)
+	breaking := majorJumps > 0 || (installedMajor == 0 && upgradeMajor == 0 && upgradeMinor != installedMinor)
+	return majorJumps, breaking
+}
+
+// MajorJumps returns the number of major versions crossed by an upgrade
+func MajorJumps(installed string, upgrade string) int {
+	majorJumps, _ := upgradeRisk(installed, upgrade)
+	return majorJumps
+}
+
+// PotentialBreaking reports whether an upgrade may contain breaking changes, see upgradeRisk
+func PotentialBreaking(installed string, upgrade string) bool {
+	_, breaking := upgradeRisk(installed, upgrade)
+	return breaking
+}
+
+func leadingVersion(version string) (int, int, bool) {
+	match := leadingNumbers.FindStringSubmatch(strings.TrimSpace(version))
+	if match == nil {
+		return 0, 0, false
+	}
+	major, _ := strconv.Atoi(match[1])
+	minor, _ := strconv.Atoi(match[2])
+	return major, minor, true
+}
+
+func candidateName(candidate patching.PlannedUpgrade) string {
+	names := []string{}
+	for dependency := range candidate.Dependencies {
+		names = append(names, dependency)
+	}
+	slices.Sort(names)
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,100 @@
+package plan
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/ecosystem"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/exploitability"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+	vulnerabilityFinder "github.com/CodeClarityCE/plugin-sca-vuln-finder/src/types"
+	"github.com/CodeClarityCE/utility-node-semver/versions"
+)
+
+func mockPatch(version string, severities ...float64) patching.PatchInfo {
+	patchable := []patching.ToPatch{}
+	for index, severity := range severities {
+		patchable = append(patchable, patching.ToPatch{Vulnerability: vulnerabilityFinder.Vulnerability{
+			VulnerabilityId: fmt.Sprintf("CVE-%s-%d", version, index),
+			Severity:        vulnerabilityFinder.VulnerabilityMatchSeverity{Severity: severity},
+		}})
+	}
+	return patching.PatchInfo{
+		IsPatchable: patching.FULL,
+		Patchable:   patchable,
+		Update:      versions.Semver{Version: version},
+	}
+}
+
+func TestOptimize(t *testing.T) {
+	workspace := patching.Workspace{
+		Patches: map[string]patching.PatchInfo{
+			"lodash@4.17.15": mockPatch("4.17.21", 7.5),
+			"express@3.21.2": mockPatch("4.19.2", 9.8),
+			"minimist@0.0.8": mockPatch("0.2.4", 5.6),
+			"left-pad@1.0.0": {IsPatchable: patching.NONE},
+			"debug@2.6.8":    mockPatch("2.6.9", 5.3),
+		},
+	}
+
+	result := Optimize(workspace, ecosystem.Npm{}, exploitability.NewDataset(), patching.PlanBudget{})
+	if len(result.Upgrades) != 4 {
+		t.Fatalf("expected 4 planned upgrades, got %d", len(result.Upgrades))
+	}
+	if _, ok := result.Upgrades[0].Dependencies["lodash@4.17.15"]; !ok {
+		t.Errorf("expected lodash to rank first, got %v", result.Upgrades[0].Dependencies)
+	}
+	if !result.Upgrades[3].MajorJump {
+		t.Errorf("expected the major jump of express to rank last, got %v", result.Upgrades[3].Dependencies)
+	}
+
+	result = Optimize(workspace, ecosystem.Npm{}, exploitability.NewDataset(), patching.PlanBudget{NoMajors: true, MaxUpgrades: 2})
+	if len(result.Upgrades) != 2 || len(result.Excluded) != 2 {
+		t.Fatalf("expected 2 planned and 2 excluded upgrades, got %d and %d", len(result.Upgrades), len(result.Excluded))
+	}
+	for _, upgrade := range result.Upgrades {
+		if upgrade.MajorJump {
+			t.Errorf("major jump planned despite the budget: %v", upgrade.Dependencies)
+		}
+	}
+}
+
+func TestOptimizeBeatsGreedy(t *testing.T) {
+	workspace := patching.Workspace{
+		Patches: map[string]patching.PatchInfo{
+			"lodash@4.17.15": mockPatch("4.17.21", 7.5),
+			"express@3.21.2": mockPatch("4.19.2", 9.8),
+		},
+	}
+
+	// lodash has the better score, but express removes more severity with the single allowed upgrade
+	result := Optimize(workspace, ecosystem.Npm{}, exploitability.NewDataset(), patching.PlanBudget{MaxUpgrades: 1})
+	if len(result.Upgrades) != 1 {
+		t.Fatalf("expected 1 planned upgrade, got %d", len(result.Upgrades))
+	}
+	if _, ok := result.Upgrades[0].Dependencies["express@3.21.2"]; !ok {
+		t.Errorf("expected express to be planned, got %v", result.Upgrades[0].Dependencies)
+	}
+	if result.SeverityRemoved != 9.8 {
+		t.Errorf("expected 9.8 severity removed, got %v", result.SeverityRemoved)
+	}
+}
+
+func TestOptimizeRanksExploitedFirst(t *testing.T) {
+	dataset := exploitability.NewDataset()
+	dataset.KEV["CVE-4.17.21-0"] = exploitability.KEVEntry{CVE: "CVE-4.17.21-0", DateAdded: "2024-01-01"}
+	workspace := patching.Workspace{
+		Patches: map[string]patching.PatchInfo{
+			"lodash@4.17.15": dataset.Prioritize(mockPatch("4.17.21", 7.5)),
+			"minimist@1.2.0": dataset.Prioritize(mockPatch("1.2.6", 9.8)),
+		},
+	}
+
+	result := Optimize(workspace, ecosystem.Npm{}, dataset, patching.PlanBudget{MaxUpgrades: 1})
+	if len(result.Upgrades) != 1 {
+		t.Fatalf("expected 1 planned upgrade, got %d", len(result.Upgrades))
+	}
+	if _, ok := result.Upgrades[0].Dependencies["lodash@4.17.15"]; !ok {
+		t.Errorf("expected the fix of the exploited lodash vulnerability to be planned, got %v", result.Upgrades[0].Dependencies)
+	}
+}
@@ -0,0 +1,175 @@
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+)
+
+// Options of the upgrade policy, as named in the plugin config and in the analysis config
+const (
+	VERSION_PREFERENCE    = "version_preference"
+	PARTIAL_FIX_SELECTION = "partial_fix_selection"
+	ALLOW_DOWNGRADES      = "allow_downgrades"
+	ALLOW_PRERELEASES     = "allow_prereleases"
+	MAX_MAJOR_JUMP        = "max_major_jump"
+	SEVERITY_THRESHOLD    = "severity_threshold"
+	ALIGN_WORKSPACES      = "align_workspaces"
+	PLAN_BUDGET           = "plan_budget"
+	RULES                 = "rules"
+)
+
+// Default returns the policy used when nothing is configured
+func Default() patching.UpgradePolicy {
+	return patching.UpgradePolicy{
+		VersionSelectionPreference: patching.SELECT_NEWEST,
+		PartialFixVersionSelection: patching.SELECT_LOWEST_AVERAGE_SEVERITY,
+		AllowDowngrades:            false,
+		AllowPrereleases:           false,
+		MaxMajorJump:               patching.UNLIMITED_MAJOR_JUMP,
+		SeverityThreshold:          0,
+		Rules:                      NoRules(),
+	}
+}
+
+// Resolve builds the policy of an analysis. The defaults of the plugin config
+// (the "default" of each entry of config.json) override Default,
+// and the options chosen for the analysis override both.
+// Every invalid option is reported in the returned error.
+func Resolve(pluginConfig map[string]any, analysisOptions map[string]any) (patching.UpgradePolicy, error) {
+	options := map[string]any{}
+	for name, entry := range pluginConfig {
+		if entry, ok := entry.(map[string]any); ok {
+			if value, ok := entry["default"]; ok && value != nil {
+				options[name] = value
+			}
+		}
+	}
+	for name, value := range analysisOptions {
+		if value != nil {
+			options[name] = value
+		}
+	}
+
+	policy := Default()
+	errs := []error{}
+	for name, value := range options {
+		if err := set(&policy, name, value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s %v: %w", name, value, err))
+		}
+	}
+	// Sorted so the message does not depend on map order
+	slices.SortFunc(errs, func(a error, b error) int { return strings.Compare(a.Error(), b.Error()) })
+	return policy, errors.Join(errs...)
+}
+
+func set(policy *patching.UpgradePolicy, name string, value any) error {
+	var err error
+	switch name {
+	case VERSION_PREFERENCE:
+		var preference string
+		preference, err = stringOption(value, string(patching.SELECT_NEWEST), string(patching.SELECT_OLDEST))
+		policy.VersionSelectionPreference = patching.VersionSelectionPreference(preference)
+	case PARTIAL_FIX_SELECTION:
+		var selection string
+		selection, err = stringOption(value, string(patching.SELECT_LOWEST_MAX_SEVERITY), string(patching.SELECT_LOWEST_AVERAGE_SEVERITY))
+		policy.PartialFixVersionSelection = patching.PartialFixVersionSelection(selection)
+	case ALLOW_DOWNGRADES:
+		policy.AllowDowngrades, err = boolOption(value)
+	case ALLOW_PRERELEASES:
+		policy.AllowPrereleases, err = boolOption(value)
+	case MAX_MAJOR_JUMP:
+		var jump float64
+		jump, err = numberOption(value)
+		if err == nil && (jump != float64(int(jump)) || jump < patching.UNLIMITED_MAJOR_JUMP) {
+			err = fmt.Errorf("expected a whole number of major versions, or %d for no limit", patching.UNLIMITED_MAJOR_JUMP)
+		}
+		policy.MaxMajorJump = int(jump)
+	case SEVERITY_THRESHOLD:
+		policy.SeverityThreshold, err = numberOption(value)
+		if err == nil && (policy.SeverityThreshold < 0 || policy.SeverityThreshold > 10) {
+			err = fmt.Errorf("expected a CVSS score between 0 and 10")
+		}
+	case ALIGN_WORKSPACES:
+		var align bool
+		align, err = boolOption(value)
+		policy.AlignWorkspaces = &align
+	case PLAN_BUDGET:
+		policy.PlanBudget, err = planBudgetOption(value)
+	case RULES:
+		policy.Rules, err = rulesOption(value)
+	default:
+		// Every option of config.json is a policy option, anything else is a typo
+		err = fmt.Errorf("unknown option")
+	}
+	return err
+}
+
+// planBudgetOption reads an object such as {"no_majors": true, "max_upgrades": 5}, or its JSON text
+func planBudgetOption(value any) (patching.PlanBudget, error) {
+	budget := patching.PlanBudget{}
+	if text, ok := value.(string); ok {
+		if err := json.Unmarshal([]byte(text), &value); err != nil {
+			return budget, err
+		}
+	}
+	fields, ok := value.(map[string]any)
+	if !ok {
+		return budget, fmt.Errorf("expected an object with no_majors and max_upgrades")
+	}
+	var err error
+	if noMajors, ok := fields["no_majors"]; ok {
+		if budget.NoMajors, err = boolOption(noMajors); err != nil {
+			return budget, fmt.Errorf("no_majors: %w", err)
+		}
+	}
+	if maxUpgrades, ok := fields["max_upgrades"]; ok {
+		var count float64
+		count, err = numberOption(maxUpgrades)
+		if err == nil && (count != float64(int(count)) || count < 0) {
+			err = fmt.Errorf("expected a whole number of upgrades, or 0 for no limit")
+		}
+		if err != nil {
+			return budget, fmt.Errorf("max_upgrades: %w", err)
+		}
+		budget.MaxUpgrades = int(count)
+	}
+	return budget, nil
+}
+
+func stringOption(value any, allowed ...string) (string, error) {
+	text, ok := value.(string)
+	if !ok || !slices.Contains(allowed, text) {
+		return "", fmt.Errorf("expected one of %s", strings.Join(allowed, ", "))
+	}
+	return text, nil
+}
+
+// Options typed in a form arrive as strings, so "true" and "2" are accepted as well
+
+func boolOption(value any) (bool, error) {
+	switch value := value.(type) {
+	case bool:
+		return value, nil
+	case string:
+		return strconv.ParseBool(value)
+	}
+	return false, fmt.Errorf("expected a boolean")
+}
+
+func numberOption(value any) (float64, error) {
+	switch value := value.(type) {
+	case float64:
+		return value, nil
+	case int:
+		return float64(value), nil
+	case string:
+		return strconv.ParseFloat(value, 64)
+	}
+	return 0, fmt.Errorf("expected a number")
+}
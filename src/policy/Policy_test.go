@@ -0,0 +1,86 @@
+package policy
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+)
+
+func TestResolve(t *testing.T) {
+	pluginConfig := map[string]any{
+		MAX_MAJOR_JUMP:     map[string]any{"type": "number", "default": float64(1)},
+		SEVERITY_THRESHOLD: map[string]any{"type": "number", "default": float64(4)},
+	}
+	analysisOptions := map[string]any{
+		SEVERITY_THRESHOLD: "7",
+		ALLOW_PRERELEASES:  true,
+		VERSION_PREFERENCE: "SELECT_OLDEST",
+	}
+
+	policy, err := Resolve(pluginConfig, analysisOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.MaxMajorJump != 1 || policy.SeverityThreshold != 7 || !policy.AllowPrereleases {
+		t.Errorf("Options were not applied: %+v", policy)
+	}
+	if policy.VersionSelectionPreference != patching.SELECT_OLDEST || policy.PartialFixVersionSelection != patching.SELECT_LOWEST_AVERAGE_SEVERITY {
+		t.Errorf("Unexpected selection options: %+v", policy)
+	}
+
+	if policy.AlignWorkspaces != nil {
+		t.Errorf("Expected the alignment of workspaces to be left to the patcher, got %v", *policy.AlignWorkspaces)
+	}
+	policy, err = Resolve(nil, map[string]any{ALIGN_WORKSPACES: "false"})
+	if err != nil || policy.AlignWorkspaces == nil || *policy.AlignWorkspaces {
+		t.Errorf("Expected the alignment of workspaces to be disabled, got %v %v", policy.AlignWorkspaces, err)
+	}
+
+	policy, err = Resolve(nil, map[string]any{PLAN_BUDGET: map[string]any{"no_majors": true, "max_upgrades": float64(3)}})
+	if err != nil || !policy.PlanBudget.NoMajors || policy.PlanBudget.MaxUpgrades != 3 {
+		t.Errorf("Expected the plan budget to be applied, got %+v %v", policy.PlanBudget, err)
+	}
+	if _, err = Resolve(nil, map[string]any{PLAN_BUDGET: `{"max_upgrades": -1}`}); err == nil {
+		t.Error("Expected a negative number of upgrades to be rejected")
+	}
+
+	if _, err = Resolve(nil, map[string]any{"max_major_jumps": float64(1)}); err == nil || !strings.Contains(err.Error(), "unknown option") {
+		t.Errorf("Expected an unknown option to be rejected, got %v", err)
+	}
+
+	_, err = Resolve(nil, map[string]any{MAX_MAJOR_JUMP: 1.5, SEVERITY_THRESHOLD: float64(11), ALLOW_DOWNGRADES: "maybe"})
+	if err == nil {
+		t.Fatal("Expected invalid options to be rejected")
+	}
+	for _, option := range []string{MAX_MAJOR_JUMP, SEVERITY_THRESHOLD, ALLOW_DOWNGRADES} {
+		if !strings.Contains(err.Error(), option) {
+			t.Errorf("Expected %s to be reported in %q", option, err)
+		}
+	}
+}
+
+func TestResolveConfigDefaults(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("..", "..", "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := struct {
+		Config map[string]any `json:"config"`
+	}{}
+	if err := json.Unmarshal(content, &config); err != nil {
+		t.Fatal(err)
+	}
+	// Every option of the plugin config must be known to the policy
+	for name := range config.Config {
+		if err := set(&patching.UpgradePolicy{}, name, nil); err != nil && strings.Contains(err.Error(), "unknown option") {
+			t.Errorf("Option %s of config.json is unknown to the policy", name)
+		}
+	}
+	if _, err := Resolve(config.Config, nil); err != nil {
+		t.Errorf("Expected the defaults of config.json to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+	"gopkg.in/yaml.v3"
+)
+
+// RULES_FILE is where a project keeps its remediation rules, relative to its root:
+//
+//	pins:
+//	  lodash: 4.17.21
+//	ignore:
+//	  - "@acme/*"
+//	ranges:
+//	  react: "<19.0.0"
+//	suppressions:
+//	  - vulnerability_id: CVE-2022-24999
+//	    package: qs
+//	    path: [express]
+//	    justification: query strings are parsed by the gateway
+//	    expires: 2026-12-31
+const RULES_FILE = ".codeclarity/patching.yaml"
+
+// NoRules returns an empty set of rules
+func NoRules() patching.PolicyRules {
+	return patching.PolicyRules{Pins: map[string]string{}, Ignore: []string{}, Ranges: map[string]string{}, Suppressions: []patching.Suppression{}}
+}
+
+// ParseRules reads rules written in YAML, or in JSON which YAML includes
+func ParseRules(content []byte) (patching.PolicyRules, error) {
+	rules := NoRules()
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	// An empty document decodes to io.EOF
+	if err := decoder.Decode(&rules); err != nil && !errors.Is(err, io.EOF) {
+		return NoRules(), err
+	}
+	// Keys left empty in the file decode to nil
+	if rules.Pins == nil {
+		rules.Pins = map[string]string{}
+	}
+	if rules.Ignore == nil {
+		rules.Ignore = []string{}
+	}
+	if rules.Ranges == nil {
+		rules.Ranges = map[string]string{}
+	}
+	if rules.Suppressions == nil {
+		rules.Suppressions = []patching.Suppression{}
+	}
+	return rules, ValidateRules(rules)
+}
+
+// LoadProjectRules reads the RULES_FILE of a project. A project without one has no rules.
+func LoadProjectRules(projectRoot string) (patching.PolicyRules, error) {
+	if projectRoot == "" {
+		return NoRules(), nil
+	}
+	rulesPath := filepath.Join(projectRoot, RULES_FILE)
+	content, err := os.ReadFile(rulesPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return NoRules(), nil
+	}
+	if err != nil {
+		return NoRules(), err
+	}
+	rules, err := ParseRules(content)
+	if err != nil {
+		return NoRules(), fmt.Errorf("invalid %s: %w", RULES_FILE, err)
+	}
+	return rules, nil
+}
+
+// ValidateRules checks that patterns are valid globs, that pins and ranges are not empty
+// and that suppressions name a vulnerability, a justification and an expiry date
+func ValidateRules(rules patching.PolicyRules) error {
+	errs := []error{}
+	patterns := slices.Concat(rules.Ignore, slices.Collect(maps.Keys(rules.Pins)), slices.Collect(maps.Keys(rules.Ranges)))
+	for _, suppression := range rules.Suppressions {
+		if suppression.Package != "" {
+			patterns = append(patterns, suppression.Package)
+		}
+		patterns = append(patterns, suppression.Path...)
+		errs = append(errs, validateSuppression(suppression)...)
+	}
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("invalid package pattern %q", pattern))
+		}
+	}
+	for pattern, version := range rules.Pins {
+		if strings.TrimSpace(version) == "" {
+			errs = append(errs, fmt.Errorf("pin %s has no version", pattern))
+		}
+	}
+	for pattern, constraint := range rules.Ranges {
+		if strings.TrimSpace(constraint) == "" {
+			errs = append(errs, fmt.Errorf("range %s has no constraint", pattern))
+		}
+	}
+	slices.SortFunc(errs, func(a error, b error) int { return strings.Compare(a.Error(), b.Error()) })
+	return errors.Join(errs...)
+}
+
+func validateSuppression(suppression patching.Suppression) []error {
+	errs := []error{}
+	if strings.TrimSpace(suppression.VulnerabilityId) == "" {
+		errs = append(errs, fmt.Errorf("suppression has no vulnerability id"))
+		return errs
+	}
+	if strings.TrimSpace(suppression.Justification) == "" {
+		errs = append(errs, fmt.Errorf("suppression of %s has no justification", suppression.VulnerabilityId))
+	}
+	if _, err := time.Parse(patching.SUPPRESSION_DATE_FORMAT, suppression.Expires); err != nil {
+		errs = append(errs, fmt.Errorf("suppression of %s has an invalid expiry date %q, expected YYYY-MM-DD", suppression.VulnerabilityId, suppression.Expires))
+	}
+	return errs
+}
+
+// ActiveSuppressions splits suppressions into the ones still applying at now and the expired ones.
+// A suppression applies until the end of its expiry date, in UTC.
+func ActiveSuppressions(suppressions []patching.Suppression, now time.Time) ([]patching.Suppression, []patching.Suppression) {
+	active := []patching.Suppression{}
+	expired := []patching.Suppression{}
+	for _, suppression := range suppressions {
+		expires, err := time.Parse(patching.SUPPRESSION_DATE_FORMAT, suppression.Expires)
+		if err != nil || !now.Before(expires.AddDate(0, 0, 1)) {
+			expired = append(expired, suppression)
+			continue
+		}
+		active = append(active, suppression)
+	}
+	return active, expired
+}
+
+// MergeRules adds the rules of override to base, override winning for packages named in both.
+// The suppressions of both apply.
+func MergeRules(base patching.PolicyRules, override patching.PolicyRules) patching.PolicyRules {
+	merged := NoRules()
+	maps.Copy(merged.Pins, base.Pins)
+	maps.Copy(merged.Pins, override.Pins)
+	maps.Copy(merged.Ranges, base.Ranges)
+	maps.Copy(merged.Ranges, override.Ranges)
+	for _, pattern := range slices.Concat(base.Ignore, override.Ignore) {
+		if !slices.Contains(merged.Ignore, pattern) {
+			merged.Ignore = append(merged.Ignore, pattern)
+		}
+	}
+	merged.Suppressions = slices.Concat(base.Suppressions, override.Suppressions)
+	if merged.Suppressions == nil {
+		merged.Suppressions = []patching.Suppression{}
+	}
+	return merged
+}
+
+// rulesOption accepts rules given as an object or as a YAML document
+func rulesOption(value any) (patching.PolicyRules, error) {
+	if text, ok := value.(string); ok {
+		return ParseRules([]byte(text))
+	}
+	content, err := json.Marshal(value)
+	if err != nil {
+		return NoRules(), err
+	}
+	return ParseRules(content)
+}
@@ -0,0 +1,69 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadProjectRules(t *testing.T) {
+	root := t.TempDir()
+	rules, err := LoadProjectRules(root)
+	if err != nil || len(rules.Pins) != 0 || len(rules.Ignore) != 0 || len(rules.Ranges) != 0 {
+		t.Fatalf("Expected no rules without a rules file, got %+v (%v)", rules, err)
+	}
+
+	content := "pins:\n  lodash: 4.17.21\nignore:\n  - \"@acme/*\"\nranges:\n  react: \"<19.0.0\"\n"
+	if err := os.MkdirAll(filepath.Join(root, ".codeclarity"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, RULES_FILE), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rules, err = LoadProjectRules(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules.Pins["lodash"] != "4.17.21" || rules.Ignore[0] != "@acme/*" || rules.Ranges["react"] != "<19.0.0" {
+		t.Errorf("Unexpected rules %+v", rules)
+	}
+
+	// The analysis config overrides the project
+	merged := MergeRules(rules, must(ParseRules([]byte(`{"ranges": {"react": "<18.3.0"}, "ignore": ["@acme/*", "left-pad"]}`))))
+	if merged.Ranges["react"] != "<18.3.0" || len(merged.Ignore) != 2 || merged.Pins["lodash"] != "4.17.21" {
+		t.Errorf("Unexpected merged rules %+v", merged)
+	}
+
+	if _, err := ParseRules([]byte("pins:\n  lodash: \"\"\nignore:\n  - \"[acme\"\n")); err == nil {
+		t.Errorf("Expected an empty pin and an invalid pattern to be rejected")
+	}
+	if _, err := ParseRules([]byte("pin:\n  lodash: 4.17.21\n")); err == nil {
+		t.Errorf("Expected an unknown key to be rejected")
+	}
+}
+
+func must[T any](value T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+func TestSuppressions(t *testing.T) {
+	rules, err := ParseRules([]byte("suppressions:\n  - vulnerability_id: CVE-2023-26136\n    path: [request]\n    justification: cookie jars are disabled\n    expires: 2026-06-30\n  - vulnerability_id: CVE-2023-28155\n    justification: redirects are disabled\n    expires: 2026-03-31\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	active, expired := ActiveSuppressions(rules.Suppressions, time.Date(2026, time.June, 30, 23, 0, 0, 0, time.UTC))
+	if len(active) != 1 || active[0].VulnerabilityId != "CVE-2023-26136" {
+		t.Errorf("Expected a suppression to apply until the end of its expiry date, got %+v", active)
+	}
+	if len(expired) != 1 || expired[0].VulnerabilityId != "CVE-2023-28155" {
+		t.Errorf("Expected an expired suppression, got %+v", expired)
+	}
+
+	if _, err := ParseRules([]byte("suppressions:\n  - vulnerability_id: CVE-2023-26136\n    expires: 30/06/2026\n")); err == nil {
+		t.Errorf("Expected a suppression without justification or with an invalid date to be rejected")
+	}
+}
@@ -0,0 +1,150 @@
+package resultCache
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	sbomTypes "github.com/CodeClarityCE/plugin-sbom-javascript/src/types/sbom/js"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+	vulnerabilityFinder "github.com/CodeClarityCE/plugin-sca-vuln-finder/src/types"
+	codeclarity "github.com/CodeClarityCE/utility-types/codeclarity_db"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// Inputs are everything the output of an analysis depends on
+type Inputs struct {
+	// PluginVersion changes the key with every release of the plugin, whose results may differ
+	PluginVersion string
+	Language      string
+	Sbom          sbomTypes.Output
+	Vulns         vulnerabilityFinder.Output
+	Policy        patching.UpgradePolicy
+	// ExpiredSuppressions change over time while the policy stays the same
+	ExpiredSuppressions []patching.Suppression
+	// DataVersion is the version of the knowledge data, see knowledgeStore.KnowledgeStore
+	DataVersion string
+	// ExploitabilityVersion is the version of the KEV and EPSS files read on top of the knowledge data
+	ExploitabilityVersion string
+}
+
+// keyContent is hashed into the key. The analysis info of the previous stages is left out,
+// as it holds their timings and changes on every run.
+type keyContent struct {
+	SchemaVersion         string                                   `json:"schema_version"`
+	PluginVersion         string                                   `json:"plugin_version"`
+	Language              string                                   `json:"language"`
+	SbomWorkspaces        map[string]sbomTypes.WorkSpace           `json:"sbom_workspaces"`
+	VulnsWorkspaces       map[string]vulnerabilityFinder.Workspace `json:"vulns_workspaces"`
+	Policy                patching.UpgradePolicy                   `json:"policy"`
+	ExpiredSuppressions   []patching.Suppression                   `json:"expired_suppressions"`
+	DataVersion           string                                   `json:"data_version"`
+	ExploitabilityVersion string                                   `json:"exploitability_version"`
+}
+
+// Key returns the content address of an analysis: analyses with the same key have the same output
+func Key(inputs Inputs) (string, error) {
+	// Maps are encoded with sorted keys, so the encoding is stable
+	content, err := json.Marshal(keyContent{
+		SchemaVersion:         patching.SCHEMA_VERSION,
+		PluginVersion:         inputs.PluginVersion,
+		Language:              inputs.Language,
+		SbomWorkspaces:        inputs.Sbom.WorkSpaces,
+		VulnsWorkspaces:       inputs.Vulns.WorkSpaces,
+		Policy:                inputs.Policy,
+		ExpiredSuppressions:   inputs.ExpiredSuppressions,
+		DataVersion:           inputs.DataVersion,
+		ExploitabilityVersion: inputs.ExploitabilityVersion,
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(content)), nil
+}
+
+// Lookup returns the latest successful result of a plugin stored under a key, if any.
+// The results are searched with the index of migrations/result_cache_key_idx.sql.
+func Lookup(db *bun.DB, plugin string, key string) (patching.Output, uuid.UUID, bool, error) {
+	res := codeclarity.Result{}
+	err := db.NewSelect().Model(&res).
+		Where("plugin = ?", plugin).
+		Where("result->'analysis_info'->>'cache_key' = ?", key).
+		Where("result->'analysis_info'->>'status' = ?", string(codeclarity.SUCCESS)).
+		Order("created_on DESC").
+		Limit(1).
+		Scan(context.Background())
+	if errors.Is(err, sql.ErrNoRows) {
+		return patching.Output{}, uuid.UUID{}, false, nil
+	}
+	if err != nil {
+		return patching.Output{}, uuid.UUID{}, false, err
+	}
+	output := patching.Output{}
+	if err := DecodeOutput(res.Result, &output); err != nil {
+		return patching.Output{}, uuid.UUID{}, false, fmt.Errorf("invalid cached result %s: %w", res.Id, err)
+	}
+	return output, res.Id, true, nil
+}
+
+// LatestForProject returns the latest successful result of a plugin for a project,
+// leaving out the results of the given analysis
+func LatestForProject(db *bun.DB, plugin string, projectId uuid.UUID, analysisId uuid.UUID) (patching.Output, uuid.UUID, bool, error) {
+	res := codeclarity.Result{}
+	err := db.NewSelect().Model(&res).
+		Join(`JOIN analysis ON analysis.id = result."analysisId"`).
+		Where("result.plugin = ?", plugin).
+		Where(`analysis."projectId" = ?`, projectId).
+		Where(`result."analysisId" != ?`, analysisId).
+		Where("result.result->'analysis_info'->>'status' = ?", string(codeclarity.SUCCESS)).
+		Order("result.created_on DESC").
+		Limit(1).
+		Scan(context.Background())
+	if errors.Is(err, sql.ErrNoRows) {
+		return patching.Output{}, uuid.UUID{}, false, nil
+	}
+	if err != nil {
+		return patching.Output{}, uuid.UUID{}, false, err
+	}
+	output := patching.Output{}
+	if err := DecodeOutput(res.Result, &output); err != nil {
+		return patching.Output{}, uuid.UUID{}, false, fmt.Errorf("invalid previous result %s: %w", res.Id, err)
+	}
+	return output, res.Id, true, nil
+}
+
+// Decode reads a stored result into output. Depending on the driver, jsonb columns come back
+// as raw JSON or already decoded.
+func Decode(result any, output any) error {
+	var content []byte
+	switch value := result.(type) {
+	case []byte:
+		content = value
+	case string:
+		content = []byte(value)
+	default:
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		content = encoded
+	}
+	return json.Unmarshal(content, output)
+}
+
+// DecodeOutput reads a stored patching result into output, migrating the results
+// of older schema versions first.
+func DecodeOutput(result any, output *patching.Output) error {
+	stored := map[string]any{}
+	if err := Decode(result, &stored); err != nil {
+		return err
+	}
+	migrated, err := patching.MigrateResult(stored)
+	if err != nil {
+		return err
+	}
+	return Decode(migrated, output)
+}
@@ -0,0 +1,81 @@
+package resultCache
+
+import (
+	"testing"
+
+	sbomTypes "github.com/CodeClarityCE/plugin-sbom-javascript/src/types/sbom/js"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+	vulnerabilityFinder "github.com/CodeClarityCE/plugin-sca-vuln-finder/src/types"
+)
+
+func TestKey(t *testing.T) {
+	inputs := Inputs{
+		PluginVersion: "v0.0.18-alpha",
+		Language:      "JS",
+		Sbom:          sbomTypes.Output{WorkSpaces: map[string]sbomTypes.WorkSpace{".": {}}},
+		Vulns: vulnerabilityFinder.Output{WorkSpaces: map[string]vulnerabilityFinder.Workspace{".": {Vulnerabilities: []vulnerabilityFinder.Vulnerability{
+			{VulnerabilityId: "CVE-2021-23337", AffectedDependency: "lodash", AffectedVersion: "4.17.20"},
+		}}}},
+		Policy:      patching.UpgradePolicy{VersionSelectionPreference: patching.SELECT_NEWEST, Rules: patching.PolicyRules{Pins: map[string]string{"react": "18.3.1", "lodash": "4.17.21"}}},
+		DataVersion: "releases=1",
+	}
+	key := must(Key(inputs))
+	if again := must(Key(inputs)); again != key {
+		t.Errorf("Expected the same inputs to give the same key, got %s and %s", key, again)
+	}
+
+	// The analysis info of the previous stages is left out
+	timed := inputs
+	timed.Vulns.AnalysisInfo.Status = "success"
+	if must(Key(timed)) != key {
+		t.Errorf("Expected the analysis info of the previous stages to be left out of the key")
+	}
+
+	changes := map[string]func(*Inputs){
+		"data version":   func(inputs *Inputs) { inputs.DataVersion = "releases=2" },
+		"exploitability": func(inputs *Inputs) { inputs.ExploitabilityVersion = "kev=2" },
+		"policy":         func(inputs *Inputs) { inputs.Policy.AllowPrereleases = true },
+		"plugin version": func(inputs *Inputs) { inputs.PluginVersion = "v0.0.19-alpha" },
+		"expiry": func(inputs *Inputs) {
+			inputs.ExpiredSuppressions = []patching.Suppression{{VulnerabilityId: "CVE-2021-23337"}}
+		},
+	}
+	for name, change := range changes {
+		changed := inputs
+		change(&changed)
+		if must(Key(changed)) == key {
+			t.Errorf("Expected a change of %s to change the key", name)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	for _, stored := range []any{[]byte(`{"schema_version": "2.0.0"}`), `{"schema_version": "2.0.0"}`, map[string]any{"schema_version": "2.0.0"}} {
+		output := patching.Output{}
+		if err := Decode(stored, &output); err != nil || output.SchemaVersion != "2.0.0" {
+			t.Errorf("Unexpected decoding of %T: %+v (%v)", stored, output, err)
+		}
+	}
+}
+
+func must[T any](value T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+func TestDecodeOutput(t *testing.T) {
+	stored := `{"workspaces":{".":{"patches":{"lodash@4.17.15":{"IsPatchable":"FULL","Update":{"Version":"4.17.21"}}}}}}`
+	output := patching.Output{}
+	if err := DecodeOutput(stored, &output); err != nil {
+		t.Fatal(err)
+	}
+	patch := output.WorkSpaces["."].Patches["lodash@4.17.15"]
+	if patch.IsPatchable != patching.FULL || patch.UpdateVersion() != "4.17.21" {
+		t.Errorf("Expected the 1.0.0 result to be migrated, got %+v", patch)
+	}
+	if output.SchemaVersion != patching.SCHEMA_VERSION {
+		t.Errorf("Expected schema version %s, got %s", patching.SCHEMA_VERSION, output.SchemaVersion)
+	}
+}
@@ -1,44 +1,154 @@
 package patching
 
 import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
 	"time"
 
+	"github.com/CodeClarityCE/plugin-sca-patching/src/ecosystem"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/exceptionManager"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/exploitability"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/knowledgeStore"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/metrics"
 	outputGenerator "github.com/CodeClarityCE/plugin-sca-patching/src/outputGenerator"
 	"github.com/CodeClarityCE/plugin-sca-patching/src/patch"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/policy"
 	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
 	vulnerabilityFinder "github.com/CodeClarityCE/plugin-sca-vuln-finder/src/types"
 	codeclarity "github.com/CodeClarityCE/utility-types/codeclarity_db"
 	"github.com/CodeClarityCE/utility-types/exceptions"
-	exceptionManager "github.com/CodeClarityCE/utility-types/exceptions"
-	"github.com/uptrace/bun"
 
 	sbomTypes "github.com/CodeClarityCE/plugin-sbom-javascript/src/types/sbom/js"
-	"github.com/CodeClarityCE/plugin-sca-patching/src/types"
 )
 
-func Start(knowledge *bun.DB, sbom sbomTypes.Output, vulns vulnerabilityFinder.Output, languageId string, start time.Time) patching.Output {
+// Start patches the project described by the outputs of the previous stages under the given upgrade policy.
+// When previous, the last result of the project, is given, only the entries whose inputs changed are computed again.
+// The errors of the analysis are added to errors and reported in the output.
+// The spans of the patching are recorded under the span of ctx.
+func Start(ctx context.Context, store knowledgeStore.KnowledgeStore, sbom sbomTypes.Output, vulns vulnerabilityFinder.Output, languageId string, upgradePolicy patching.UpgradePolicy, previous *patching.Output, errors *exceptionManager.Collector, start time.Time) patching.Output {
 	// Check if the previous stage was successful
 	if sbom.AnalysisInfo.Status != codeclarity.SUCCESS {
-		// Add an error to the exception manager
-		exceptionManager.AddError(
-			"Execution of the previous stage was unsuccessful, upon which the current stage relies",
+		// Add an error to the errors of the analysis
+		errors.AddError(
 			exceptions.PREVIOUS_STAGE_FAILED,
 			"Execution of the previous stage was unsuccessful, upon which the current stage relies",
-			exceptions.PREVIOUS_STAGE_FAILED,
+			"Execution of the previous stage was unsuccessful, upon which the current stage relies",
 		)
 		// Return a failure output
-		return outputGenerator.FailureOutput(sbom.AnalysisInfo, start)
+		return failureOutput(sbom.AnalysisInfo, upgradePolicy, errors, start)
+	}
+
+	// Knowledge queries are traced under the span of the analysis, the patcher moves them
+	// under the span of the dependency or candidate they are made for
+	if store != nil {
+		store = knowledgeStore.NewTraced(ctx, store)
+	}
+
+	// Select the ecosystem driver matching the language of the project
+	packageEcosystem, err := ecosystem.ForLanguage(languageId, store)
+	if err != nil {
+		errors.AddError(exceptions.GENERIC_ERROR, "", err.Error())
+		return failureOutput(sbom.AnalysisInfo, upgradePolicy, errors, start)
+	}
+
+	// Workspaces are aligned by default when the project has several of them
+	if upgradePolicy.AlignWorkspaces == nil {
+		align := len(sbom.WorkSpaces) > 1
+		upgradePolicy.AlignWorkspaces = &align
 	}
 
-	// Initialize the patcher with specific upgrade policies
-	upgradePolicy := types.UpgradePolicy{
-		VersionSelectionPreference: types.SELECT_NEWEST,
-		PartialFixVersionSelection: types.SELECT_LOWEST_AVERAGE_SEVERITY,
-		AllowDowngrades:            false,
+	// Initialize the patcher with the upgrade policy of the analysis
+	patcher := patch.InitializePatcher(upgradePolicy, packageEcosystem, sbom, vulns)
+	patcher.Errors = errors
+	patcher.Context = ctx
+	patcher.Previous = previous
+	if store != nil {
+		vulnerabilityDataVersion, err := store.VulnerabilityDataVersion()
+		if err != nil {
+			errors.AddWarning(exceptions.GENERIC_ERROR, "", fmt.Sprintf("Error when reading the version of the vulnerability data, all entries are computed again: %s", err))
+		}
+		patcher.VulnerabilityDataVersion = vulnerabilityDataVersion
 	}
+	patcher.Exploitability = loadExploitability(store, vulns, errors)
+	// Expired suppressions no longer hide their vulnerabilities
+	activeSuppressions, expiredSuppressions := policy.ActiveSuppressions(upgradePolicy.Rules.Suppressions, start)
+	patcher.Suppressions = activeSuppressions
+	patchApplicationStart := time.Now()
+	workSpaceData := patcher.PatchApplication()
+	metrics.ObservePhase(metrics.PHASE_PATCH_APPLICATION, patchApplicationStart)
 
-	workSpaceData := patch.InitializePatcher(upgradePolicy, knowledge, sbom, vulns).PatchApplication()
+	alignedUpgrades := []patching.AlignedUpgrade{}
+	if *upgradePolicy.AlignWorkspaces {
+		alignedUpgrades = patcher.AlignWorkspaces(workSpaceData)
+	}
 
 	// Return a success output with the patched data
-	return outputGenerator.SuccessOutput(workSpaceData, sbom.AnalysisInfo, start)
+	output := outputGenerator.SuccessOutput(workSpaceData, sbom.AnalysisInfo, errors, start)
+	output.AlignedUpgrades = alignedUpgrades
+	output.AnalysisInfo.Policy = &upgradePolicy
+	output.AnalysisInfo.ExpiredSuppressions = expiredSuppressions
+	return output
+}
+
+func failureOutput(sbomAnalysisInfo sbomTypes.AnalysisInfo, upgradePolicy patching.UpgradePolicy, errors *exceptionManager.Collector, start time.Time) patching.Output {
+	output := outputGenerator.FailureOutput(sbomAnalysisInfo, errors, start)
+	output.AnalysisInfo.Policy = &upgradePolicy
+	return output
+}
+
+// ExploitabilityFilesVersion digests the content of the KEV and EPSS files loadExploitability reads,
+// so that the results computed with other files are not reused
+func ExploitabilityFilesVersion() (string, error) {
+	digest := sha256.New()
+	for _, variable := range []string{"KEV_FILE", "EPSS_FILE"} {
+		path := os.Getenv(variable)
+		fmt.Fprintf(digest, "%s=%s\n", variable, path)
+		if path == "" {
+			continue
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(digest, file)
+		file.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("%x", digest.Sum(nil)), nil
+}
+
+// loadExploitability loads the KEV and EPSS datasets from the files named by KEV_FILE and EPSS_FILE,
+// falling back to the knowledge store. Missing data only lowers priorities, so errors are not fatal.
+func loadExploitability(store knowledgeStore.KnowledgeStore, vulns vulnerabilityFinder.Output, errors *exceptionManager.Collector) exploitability.Dataset {
+	dataset := exploitability.NewDataset()
+	if path := os.Getenv("KEV_FILE"); path != "" {
+		if err := dataset.LoadKEVFile(path); err != nil {
+			errors.AddWarning(exceptions.GENERIC_ERROR, "", fmt.Sprintf("Error when reading the KEV catalog: %s", err))
+		}
+	}
+	if path := os.Getenv("EPSS_FILE"); path != "" {
+		if err := dataset.LoadEPSSFile(path); err != nil {
+			errors.AddWarning(exceptions.GENERIC_ERROR, "", fmt.Sprintf("Error when reading the EPSS scores: %s", err))
+		}
+	}
+	if store == nil {
+		return dataset
+	}
+
+	vulnerabilityIds := []string{}
+	for _, workspace := range vulns.WorkSpaces {
+		for _, vulnerability := range workspace.Vulnerabilities {
+			vulnerabilityIds = append(vulnerabilityIds, vulnerability.VulnerabilityId)
+		}
+	}
+	defer metrics.ObservePhase(metrics.PHASE_EXPLOITABILITY_LOAD, time.Now())
+	if err := dataset.LoadFromKnowledge(store, vulnerabilityIds); err != nil {
+		errors.AddWarning(exceptions.GENERIC_ERROR, "", fmt.Sprintf("Error when reading exploitability data: %s", err))
+	}
+	return dataset
 }
@@ -0,0 +1,130 @@
+package stageResolver
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	vulnerabilityFinder "github.com/CodeClarityCE/plugin-sca-vuln-finder/src/types"
+	codeclarity "github.com/CodeClarityCE/utility-types/codeclarity_db"
+	"github.com/google/uuid"
+)
+
+// Plugins producing SBOMs are named "<language>-sbom" (js-sbom, python-sbom, php-sbom...)
+// and store the key of their result under SBOM_KEY. Vulnerability finders are named
+// "vuln-finder" or "vuln-finder-<source>" and store theirs under VULN_KEY.
+const (
+	SBOM_SUFFIX = "-sbom"
+	SBOM_KEY    = "sbomKey"
+	VULN_FINDER = "vuln-finder"
+	VULN_KEY    = "vulnKey"
+)
+
+// PreviousStages are the results of the previous stages the patching relies on
+type PreviousStages struct {
+	// SbomProducer is the step that produced the SBOM, and Language the language it was produced for
+	SbomProducer string
+	Language     string
+	SbomKey      uuid.UUID
+	// VulnKeys are the results of every vulnerability finder, in the order of the steps
+	VulnKeys []uuid.UUID
+}
+
+// Resolve finds the results of the previous stages among the steps of an analysis.
+// The first SBOM producer is used, later ones are listed in the returned warnings.
+func Resolve(steps [][]codeclarity.Step) (PreviousStages, []string, error) {
+	stages := PreviousStages{VulnKeys: []uuid.UUID{}}
+	warnings := []string{}
+	errs := []error{}
+	for _, stage := range steps {
+		for _, step := range stage {
+			if isSbomProducer(step.Name) {
+				if stages.SbomProducer != "" {
+					warnings = append(warnings, fmt.Sprintf("ignoring the SBOM of %s, using the one of %s", step.Name, stages.SbomProducer))
+					continue
+				}
+				key, err := resultKey(step, SBOM_KEY)
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				stages.SbomProducer = step.Name
+				stages.Language = strings.ToUpper(strings.TrimSuffix(step.Name, SBOM_SUFFIX))
+				stages.SbomKey = key
+			} else if isVulnFinder(step.Name) {
+				key, err := resultKey(step, VULN_KEY)
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				stages.VulnKeys = append(stages.VulnKeys, key)
+			}
+		}
+	}
+	if stages.SbomProducer == "" && len(errs) == 0 {
+		errs = append(errs, fmt.Errorf("no SBOM producer (*%s) among the steps of the analysis", SBOM_SUFFIX))
+	}
+	if len(stages.VulnKeys) == 0 && len(errs) == 0 {
+		errs = append(errs, fmt.Errorf("no %s among the steps of the analysis", VULN_FINDER))
+	}
+	return stages, warnings, errors.Join(errs...)
+}
+
+func isSbomProducer(name string) bool {
+	return strings.HasSuffix(name, SBOM_SUFFIX) && len(name) > len(SBOM_SUFFIX)
+}
+
+func isVulnFinder(name string) bool {
+	return name == VULN_FINDER || strings.HasPrefix(name, VULN_FINDER+"-")
+}
+
+// resultKey reads the key of the result of a step, stored as a string or as a UUID
+func resultKey(step codeclarity.Step, name string) (uuid.UUID, error) {
+	value, ok := step.Result[name]
+	if !ok || value == nil {
+		return uuid.UUID{}, fmt.Errorf("step %s has no %s in its result", step.Name, name)
+	}
+	switch key := value.(type) {
+	case uuid.UUID:
+		return key, nil
+	case string:
+		parsed, err := uuid.Parse(key)
+		if err != nil {
+			return uuid.UUID{}, fmt.Errorf("step %s has an invalid %s %q: %w", step.Name, name, key, err)
+		}
+		return parsed, nil
+	}
+	return uuid.UUID{}, fmt.Errorf("step %s has a %s of unexpected type %T", step.Name, name, value)
+}
+
+// MergeVulns combines the outputs of several vulnerability finders.
+// A vulnerability reported by several of them for the same release is kept once,
+// and the merged output fails if any of them failed.
+func MergeVulns(outputs []vulnerabilityFinder.Output) vulnerabilityFinder.Output {
+	if len(outputs) == 1 {
+		return outputs[0]
+	}
+	merged := vulnerabilityFinder.Output{WorkSpaces: map[string]vulnerabilityFinder.Workspace{}}
+	seen := map[string]bool{}
+	for i, output := range outputs {
+		if i == 0 || output.AnalysisInfo.Status == codeclarity.FAILURE {
+			merged.AnalysisInfo = output.AnalysisInfo
+		}
+		for workspaceName, workspace := range output.WorkSpaces {
+			mergedWorkspace := merged.WorkSpaces[workspaceName]
+			for _, vulnerability := range workspace.Vulnerabilities {
+				id := strings.Join([]string{workspaceName, vulnerability.VulnerabilityId, vulnerability.AffectedDependency, vulnerability.AffectedVersion}, "\x00")
+				if seen[id] {
+					continue
+				}
+				seen[id] = true
+				mergedWorkspace.Vulnerabilities = append(mergedWorkspace.Vulnerabilities, vulnerability)
+			}
+			if mergedWorkspace.Vulnerabilities == nil {
+				mergedWorkspace.Vulnerabilities = []vulnerabilityFinder.Vulnerability{}
+			}
+			merged.WorkSpaces[workspaceName] = mergedWorkspace
+		}
+	}
+	return merged
+}
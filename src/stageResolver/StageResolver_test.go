@@ -0,0 +1,64 @@
+package stageResolver
+
+import (
+	"testing"
+
+	vulnerabilityFinder "github.com/CodeClarityCE/plugin-sca-vuln-finder/src/types"
+	codeclarity "github.com/CodeClarityCE/utility-types/codeclarity_db"
+	"github.com/google/uuid"
+)
+
+func TestResolve(t *testing.T) {
+	sbomKey := uuid.New()
+	vulnKey := uuid.New()
+	osvKey := uuid.New()
+	steps := [][]codeclarity.Step{
+		{{Name: "php-sbom", Result: map[string]any{"sbomKey": sbomKey.String()}}, {Name: "js-sbom", Result: map[string]any{"sbomKey": uuid.NewString()}}},
+		{{Name: "vuln-finder", Result: map[string]any{"vulnKey": vulnKey.String()}}, {Name: "vuln-finder-osv", Result: map[string]any{"vulnKey": osvKey}}},
+		{{Name: "js-patching"}},
+	}
+
+	stages, warnings, err := Resolve(steps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stages.SbomProducer != "php-sbom" || stages.Language != "PHP" || stages.SbomKey != sbomKey {
+		t.Errorf("Expected the SBOM of php-sbom, got %+v", stages)
+	}
+	if len(warnings) != 1 {
+		t.Errorf("Expected a warning for the ignored SBOM of js-sbom, got %v", warnings)
+	}
+	if len(stages.VulnKeys) != 2 || stages.VulnKeys[0] != vulnKey || stages.VulnKeys[1] != osvKey {
+		t.Errorf("Expected the results of both vulnerability finders, got %v", stages.VulnKeys)
+	}
+}
+
+func TestResolveInvalidSteps(t *testing.T) {
+	cases := map[string][][]codeclarity.Step{
+		"missing sbom":  {{{Name: "vuln-finder", Result: map[string]any{"vulnKey": uuid.NewString()}}}},
+		"missing vulns": {{{Name: "js-sbom", Result: map[string]any{"sbomKey": uuid.NewString()}}}},
+		"invalid key":   {{{Name: "js-sbom", Result: map[string]any{"sbomKey": "not-a-uuid"}}, {Name: "vuln-finder", Result: map[string]any{"vulnKey": uuid.NewString()}}}},
+		"wrong type":    {{{Name: "js-sbom", Result: map[string]any{"sbomKey": 42}}, {Name: "vuln-finder", Result: map[string]any{"vulnKey": uuid.NewString()}}}},
+		"no result":     {{{Name: "js-sbom"}, {Name: "vuln-finder", Result: map[string]any{"vulnKey": uuid.NewString()}}}},
+	}
+	for name, steps := range cases {
+		if _, _, err := Resolve(steps); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestMergeVulns(t *testing.T) {
+	shared := vulnerabilityFinder.Vulnerability{VulnerabilityId: "CVE-2021-23337", AffectedDependency: "lodash", AffectedVersion: "4.17.20"}
+	other := vulnerabilityFinder.Vulnerability{VulnerabilityId: "GHSA-29mw-wpgm-hmr9", AffectedDependency: "lodash", AffectedVersion: "4.17.20"}
+	merged := MergeVulns([]vulnerabilityFinder.Output{
+		{WorkSpaces: map[string]vulnerabilityFinder.Workspace{".": {Vulnerabilities: []vulnerabilityFinder.Vulnerability{shared}}}, AnalysisInfo: vulnerabilityFinder.AnalysisInfo{Status: codeclarity.SUCCESS}},
+		{WorkSpaces: map[string]vulnerabilityFinder.Workspace{".": {Vulnerabilities: []vulnerabilityFinder.Vulnerability{shared, other}}}, AnalysisInfo: vulnerabilityFinder.AnalysisInfo{Status: codeclarity.FAILURE}},
+	})
+	if len(merged.WorkSpaces["."].Vulnerabilities) != 2 {
+		t.Errorf("Expected 2 distinct vulnerabilities, got %d", len(merged.WorkSpaces["."].Vulnerabilities))
+	}
+	if merged.AnalysisInfo.Status != codeclarity.FAILURE {
+		t.Errorf("Expected the merged output to fail, got %s", merged.AnalysisInfo.Status)
+	}
+}
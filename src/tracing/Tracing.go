@@ -0,0 +1,108 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	TRACER_NAME  = "github.com/CodeClarityCE/plugin-sca-patching"
+	SERVICE_NAME = "js-patching"
+)
+
+// Exporters selected by OTEL_TRACES_EXPORTER
+const (
+	EXPORTER_NONE    = "none"
+	EXPORTER_OTLP    = "otlp"
+	EXPORTER_CONSOLE = "console"
+	EXPORTER_FILE    = "file"
+)
+
+// Setup installs the exporter selected by OTEL_TRACES_EXPORTER:
+//   - otlp sends the spans over OTLP/HTTP to OTEL_EXPORTER_OTLP_ENDPOINT, a local collector by default
+//   - console writes them to console
+//   - file writes them to the file named by OTEL_TRACES_FILE
+//
+// Spans are dropped when it is unset or "none".
+// The returned function flushes the remaining spans and must be called before exiting.
+func Setup(ctx context.Context, console io.Writer) (func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var file *os.File
+	switch name := os.Getenv("OTEL_TRACES_EXPORTER"); name {
+	case "", EXPORTER_NONE:
+		return func(context.Context) error { return nil }, nil
+	case EXPORTER_OTLP:
+		otlpExporter, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, err
+		}
+		exporter = otlpExporter
+	case EXPORTER_CONSOLE:
+		consoleExporter, err := stdouttrace.New(stdouttrace.WithWriter(console))
+		if err != nil {
+			return nil, err
+		}
+		exporter = consoleExporter
+	case EXPORTER_FILE:
+		path := os.Getenv("OTEL_TRACES_FILE")
+		if path == "" {
+			return nil, fmt.Errorf("OTEL_TRACES_FILE is required by the %s exporter", EXPORTER_FILE)
+		}
+		created, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		file = created
+		fileExporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		exporter = fileExporter
+	default:
+		return nil, fmt.Errorf("unknown traces exporter %q, expected %s, %s, %s or %s", name, EXPORTER_OTLP, EXPORTER_CONSOLE, EXPORTER_FILE, EXPORTER_NONE)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", SERVICE_NAME))),
+	)
+	otel.SetTracerProvider(provider)
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if file != nil {
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	}, nil
+}
+
+// Start begins a span under the span of ctx, if any. The caller ends it.
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return otel.Tracer(TRACER_NAME).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// End ends a span, marking it as failed when err is not nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
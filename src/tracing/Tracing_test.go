@@ -0,0 +1,38 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+func TestSetup(t *testing.T) {
+	t.Setenv("OTEL_TRACES_EXPORTER", "")
+	shutdown, err := Setup(context.Background(), io.Discard)
+	if err != nil {
+		t.Fatalf("Expected no error without exporter, got %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("Expected no error on shutdown, got %v", err)
+	}
+
+	t.Setenv("OTEL_TRACES_EXPORTER", "jaeger")
+	if _, err := Setup(context.Background(), io.Discard); err == nil {
+		t.Errorf("Expected an error for an unknown exporter")
+	}
+
+	t.Setenv("OTEL_TRACES_EXPORTER", EXPORTER_FILE)
+	t.Setenv("OTEL_TRACES_FILE", "")
+	if _, err := Setup(context.Background(), io.Discard); err == nil {
+		t.Errorf("Expected an error for the file exporter without OTEL_TRACES_FILE")
+	}
+}
+
+func TestStartWithoutContext(t *testing.T) {
+	// Patchers built without InitializePatcher have no context
+	ctx, span := Start(nil, "span")
+	defer End(span, nil)
+	if ctx == nil {
+		t.Errorf("Expected a context")
+	}
+}
@@ -0,0 +1,81 @@
+package patching
+
+import "fmt"
+
+// patchInfoFields maps the Go field names used by 1.0.0 results to their 2.0.0 names
+var patchInfoFields = map[string]string{
+	"TopLevelVulnerable":       "top_level_vulnerable",
+	"IsPatchable":              "is_patchable",
+	"Unpatchable":              "unpatchable",
+	"Patchable":                "patchable",
+	"Introduced":               "introduced",
+	"Patches":                  "patches",
+	"Update":                   "update",
+	"Priority":                 "priority",
+	"PriorityReasons":          "priority_reasons",
+	"SeverityDist":             "severity_dist",
+	"AfterUpgradeSeverityDist": "after_upgrade_severity_dist",
+}
+
+var toPatchFields = map[string]string{
+	"DependencyName":    "dependency_name",
+	"DependencyVersion": "dependency_version",
+	"Path":              "path",
+	"Vulnerability":     "vulnerability",
+}
+
+// MigrateResult upgrades a stored result, as decoded from JSON, to the current schema version.
+// Results without a schema_version are 1.0.0 results.
+func MigrateResult(result map[string]any) (map[string]any, error) {
+	version, _ := result["schema_version"].(string)
+	switch version {
+	case SCHEMA_VERSION:
+		return result, nil
+	case "", "1.0.0":
+		workspaces, _ := result["workspaces"].(map[string]any)
+		for _, workspace := range workspaces {
+			workspaceMap, ok := workspace.(map[string]any)
+			if !ok {
+				continue
+			}
+			for _, key := range []string{"patches", "dev_patches"} {
+				patches, _ := workspaceMap[key].(map[string]any)
+				for dependency, patch := range patches {
+					patches[dependency] = migratePatchInfo(patch)
+				}
+			}
+		}
+		result["schema_version"] = SCHEMA_VERSION
+		return result, nil
+	default:
+		return nil, fmt.Errorf("unsupported schema version %s", version)
+	}
+}
+
+func migratePatchInfo(patch any) any {
+	patchMap, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	patchMap = renameFields(patchMap, patchInfoFields)
+	for _, key := range []string{"unpatchable", "patchable", "introduced"} {
+		toPatchList, _ := patchMap[key].([]any)
+		for i, toPatch := range toPatchList {
+			if toPatchMap, ok := toPatch.(map[string]any); ok {
+				toPatchList[i] = renameFields(toPatchMap, toPatchFields)
+			}
+		}
+	}
+	return patchMap
+}
+
+func renameFields(object map[string]any, names map[string]string) map[string]any {
+	renamed := map[string]any{}
+	for key, value := range object {
+		if name, ok := names[key]; ok {
+			key = name
+		}
+		renamed[key] = value
+	}
+	return renamed
+}
@@ -0,0 +1,86 @@
+package patching
+
+type VersionSelectionPreference string
+
+const (
+	SELECT_NEWEST VersionSelectionPreference = "SELECT_NEWEST"
+	SELECT_OLDEST VersionSelectionPreference = "SELECT_OLDEST"
+)
+
+type PartialFixVersionSelection string
+
+const (
+	SELECT_LOWEST_MAX_SEVERITY     PartialFixVersionSelection = "SELECT_LOWEST_MAX_SEVERITY"
+	SELECT_LOWEST_AVERAGE_SEVERITY PartialFixVersionSelection = "SELECT_LOWEST_AVERAGE_SEVERITY"
+)
+
+// UNLIMITED_MAJOR_JUMP lets upgrades cross any number of major versions
+const UNLIMITED_MAJOR_JUMP = -1
+
+// UpgradePolicy drives the choice of the versions recommended by the patcher.
+// It is echoed in the analysis info of the output.
+type UpgradePolicy struct {
+	AllowDowngrades            bool                       `json:"allow_downgrades"`
+	PartialFixVersionSelection PartialFixVersionSelection `json:"partial_fix_selection"`
+	VersionSelectionPreference VersionSelectionPreference `json:"version_preference"`
+	// AllowPrereleases lets pre-release versions be recommended
+	AllowPrereleases bool `json:"allow_prereleases"`
+	// MaxMajorJump is the number of major versions an upgrade may cross, UNLIMITED_MAJOR_JUMP for no limit
+	MaxMajorJump int `json:"max_major_jump"`
+	// SeverityThreshold ignores the vulnerabilities scoring below it
+	SeverityThreshold float64 `json:"severity_threshold"`
+	// AlignWorkspaces enables the monorepo mode, where shared direct dependencies
+	// are upgraded to the same version across workspaces.
+	// When unset, it is enabled for projects with several workspaces.
+	AlignWorkspaces *bool `json:"align_workspaces,omitempty"`
+	// PlanBudget restricts the upgrades of the remediation plan of each workspace
+	PlanBudget PlanBudget `json:"plan_budget"`
+	// Rules are the pins, ignores and allowed ranges of the project
+	Rules PolicyRules `json:"rules"`
+}
+
+// PolicyRules are the remediation rules of a project, from .codeclarity/patching.yaml or the analysis config.
+// Packages are named by glob patterns such as "@acme/*".
+type PolicyRules struct {
+	// Pins keeps packages on a single version
+	Pins map[string]string `json:"pins" yaml:"pins"`
+	// Ignore leaves packages out of the remediation
+	Ignore []string `json:"ignore" yaml:"ignore"`
+	// Ranges restricts the versions packages may be upgraded to, with constraints of their ecosystem
+	Ranges map[string]string `json:"ranges" yaml:"ranges"`
+	// Suppressions hide accepted risks and false positives until they expire
+	Suppressions []Suppression `json:"suppressions" yaml:"suppressions"`
+}
+
+// SUPPRESSION_DATE_FORMAT is the layout of the expiry date of suppressions
+const SUPPRESSION_DATE_FORMAT = "2006-01-02"
+
+// Suppression hides a vulnerability from the remediation until the end of its expiry date.
+// Package and Path are glob patterns narrowing it to an affected package
+// or to the packages it is reached through, direct dependency first.
+type Suppression struct {
+	VulnerabilityId string   `json:"vulnerability_id" yaml:"vulnerability_id"`
+	Package         string   `json:"package,omitempty" yaml:"package"`
+	Path            []string `json:"path,omitempty" yaml:"path"`
+	Justification   string   `json:"justification" yaml:"justification"`
+	Expires         string   `json:"expires" yaml:"expires"`
+}
+
+// SuppressedVulnerability is an occurrence of a vulnerability hidden by a suppression
+type SuppressedVulnerability struct {
+	VulnerabilityId string `json:"vulnerability_id"`
+	// Dependency is the affected dependency, reached through DirectDependency
+	Dependency       string `json:"dependency"`
+	DirectDependency string `json:"direct_dependency"`
+	Dev              bool   `json:"dev"`
+	Justification    string `json:"justification"`
+	Expires          string `json:"expires"`
+}
+
+// BlockedRecommendation is a direct dependency the policy rules kept from being fully patched
+type BlockedRecommendation struct {
+	Dependency string `json:"dependency"`
+	Dev        bool   `json:"dev"`
+	// Rule is the rule that blocked it, such as "range react <19.0.0"
+	Rule string `json:"rule"`
+}
@@ -0,0 +1,103 @@
+package patching
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+//go:generate go run ../../../tools/schemagen -o ../../../schema/patching-output.schema.json
+
+// SCHEMA_VERSION is the version of the output format.
+// 1.0.0 results carry no version and use Go field names for patches, see MigrateResult.
+const SCHEMA_VERSION = "2.0.0"
+
+const modulePath = "github.com/CodeClarityCE/plugin-sca-patching"
+
+// enums lists the values of the string types used as enumerations in the output
+var enums = map[reflect.Type][]string{
+	reflect.TypeOf(PatchType("")):                  {"", string(FULL), string(PARTIAL), string(NONE)},
+	reflect.TypeOf(IntroductionType("")):           {string(ExistedBefore), string(NewlyIntroduced), string(Mixed)},
+	reflect.TypeOf(VersionSelectionPreference("")): {string(SELECT_NEWEST), string(SELECT_OLDEST)},
+	reflect.TypeOf(PartialFixVersionSelection("")): {string(SELECT_LOWEST_MAX_SEVERITY), string(SELECT_LOWEST_AVERAGE_SEVERITY)},
+	reflect.TypeOf(ErrorSeverity("")):              {string(SEVERITY_ERROR), string(SEVERITY_WARNING)},
+}
+
+// JSONSchema describes Output as a JSON Schema (draft 2020-12).
+// Types owned by other plugins and libraries are described as plain objects,
+// their own documentation being authoritative.
+func JSONSchema() ([]byte, error) {
+	defs := map[string]any{}
+	root := schemaOf(reflect.TypeOf(Output{}), defs)
+	schema := map[string]any{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"title":       "CodeClarity patching output",
+		"description": "Result of the patching plugin, schema version " + SCHEMA_VERSION,
+		"$ref":        root["$ref"],
+		"$defs":       defs,
+	}
+	// The version is pinned so that a result can be checked against the schema it was produced with
+	output := defs["Output"].(map[string]any)
+	output["properties"].(map[string]any)["schema_version"] = map[string]any{"const": SCHEMA_VERSION}
+
+	content, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(content, '\n'), nil
+}
+
+func schemaOf(t reflect.Type, defs map[string]any) map[string]any {
+	if values, ok := enums[t]; ok {
+		return map[string]any{"type": "string", "enum": values}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return map[string]any{"anyOf": []any{schemaOf(t.Elem(), defs), map[string]any{"type": "null"}}}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		// nil slices are encoded as null
+		return map[string]any{"type": []string{"array", "null"}, "items": schemaOf(t.Elem(), defs)}
+	case reflect.Map:
+		return map[string]any{"type": []string{"object", "null"}, "additionalProperties": schemaOf(t.Elem(), defs)}
+	case reflect.Struct:
+		if !strings.HasPrefix(t.PkgPath(), modulePath) {
+			return map[string]any{"type": "object", "description": t.String()}
+		}
+		if _, ok := defs[t.Name()]; !ok {
+			// Registered before walking the fields, for recursive types
+			definition := map[string]any{"type": "object"}
+			defs[t.Name()] = definition
+			properties := map[string]any{}
+			required := []string{}
+			for i := range t.NumField() {
+				field := t.Field(i)
+				name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+				if !field.IsExported() || name == "-" {
+					continue
+				}
+				if name == "" {
+					name = field.Name
+				}
+				properties[name] = schemaOf(field.Type, defs)
+				if !strings.Contains(options, "omitempty") {
+					required = append(required, name)
+				}
+			}
+			definition["properties"] = properties
+			definition["required"] = required
+		}
+		return map[string]any{"$ref": "#/$defs/" + t.Name()}
+	default:
+		return map[string]any{}
+	}
+}
@@ -0,0 +1,48 @@
+package patching
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestSchemaIsUpToDate(t *testing.T) {
+	schema, err := JSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	committed, err := os.ReadFile("../../../schema/patching-output.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(schema) != string(committed) {
+		t.Errorf("schema/patching-output.schema.json is outdated, run go generate ./src/types/patching")
+	}
+}
+
+func TestMigrateResult(t *testing.T) {
+	stored := `{"workspaces":{".":{"patches":{"lodash@4.17.15":{"TopLevelVulnerable":true,"IsPatchable":"FULL","Patchable":[{"DependencyName":"lodash","Path":[]}]}}}}}`
+	result := map[string]any{}
+	if err := json.Unmarshal([]byte(stored), &result); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := MigrateResult(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patch := result["workspaces"].(map[string]any)["."].(map[string]any)["patches"].(map[string]any)["lodash@4.17.15"].(map[string]any)
+	if patch["is_patchable"] != "FULL" || patch["top_level_vulnerable"] != true {
+		t.Errorf("PatchInfo fields were not renamed: %v", patch)
+	}
+	if patch["patchable"].([]any)[0].(map[string]any)["dependency_name"] != "lodash" {
+		t.Errorf("ToPatch fields were not renamed: %v", patch["patchable"])
+	}
+	if result["schema_version"] != SCHEMA_VERSION {
+		t.Errorf("Expected schema version %s, got %v", SCHEMA_VERSION, result["schema_version"])
+	}
+
+	if _, err := MigrateResult(map[string]any{"schema_version": "99.0.0"}); err == nil {
+		t.Errorf("Expected an error for an unknown schema version")
+	}
+}
@@ -1,6 +1,8 @@
 package patching
 
 import (
+	"strings"
+
 	vulnerabilityFinder "github.com/CodeClarityCE/plugin-sca-vuln-finder/src/types"
 	"github.com/CodeClarityCE/utility-node-semver/versions"
 	codeclarity "github.com/CodeClarityCE/utility-types/codeclarity_db"
@@ -21,16 +23,80 @@ type SeverityDist struct {
 	Medium   int `json:"medium"`
 	Low      int `json:"low"`
 	None     int `json:"none"`
+	// Unknown counts the vulnerabilities without a severity class or score
+	Unknown int `json:"unknown"`
+}
+
+// Add counts a vulnerability in the distribution, from its severity class when known and from its CVSS score otherwise
+func (dist *SeverityDist) Add(vulnerability vulnerabilityFinder.Vulnerability) {
+	severity := vulnerability.Severity.Severity
+	switch strings.ToUpper(vulnerability.Severity.SeverityClass) {
+	case "CRITICAL":
+		dist.Critical++
+	case "HIGH":
+		dist.High++
+	case "MEDIUM":
+		dist.Medium++
+	case "LOW":
+		dist.Low++
+	case "NONE":
+		dist.None++
+	default:
+		switch {
+		case severity >= 9:
+			dist.Critical++
+		case severity >= 7:
+			dist.High++
+		case severity >= 4:
+			dist.Medium++
+		case severity > 0:
+			dist.Low++
+		default:
+			dist.Unknown++
+		}
+	}
+}
+
+// Plus returns the sum of two distributions
+func (dist SeverityDist) Plus(other SeverityDist) SeverityDist {
+	return SeverityDist{
+		Critical: dist.Critical + other.Critical,
+		High:     dist.High + other.High,
+		Medium:   dist.Medium + other.Medium,
+		Low:      dist.Low + other.Low,
+		None:     dist.None + other.None,
+		Unknown:  dist.Unknown + other.Unknown,
+	}
 }
 
 type IntroductionType string
 
 const (
-	ExistedBefore   = "EXISTED_BEFORE"
-	NewlyIntroduced = "NEWLY_INTRODUCED"
-	Mixed           = "MIXED"
+	ExistedBefore   IntroductionType = "EXISTED_BEFORE"
+	NewlyIntroduced IntroductionType = "NEWLY_INTRODUCED"
+	Mixed           IntroductionType = "MIXED"
 )
 
+// VulnerabilityOccurencePatchInfo describes the occurrences of a vulnerability
+// reached through one direct dependency, and what its upgrade does to them
+type VulnerabilityOccurencePatchInfo struct {
+	PatchType                 PatchType                           `json:"patch_type"`
+	DirectDepInstalledVersion string                              `json:"direct_dep_installed_version"`
+	DirectDepUpgradeVersion   string                              `json:"direct_dep_upgrade_version"`
+	DirectDepName             string                              `json:"direct_dep_name"`
+	IntroducedOccurences      []vulnerabilityFinder.Vulnerability `json:"introduced_occurences"`
+	UnPatchedOccurences       []vulnerabilityFinder.Vulnerability `json:"unpatched_occurences"`
+	PatchedOccurences         []vulnerabilityFinder.Vulnerability `json:"patched_occurences"`
+}
+
+// VulnerabilityPatchInfo is the remediation of a vulnerability across a workspace,
+// its occurrences being keyed by the direct dependency that has to change
+type VulnerabilityPatchInfo struct {
+	IntroductionType IntroductionType                           `json:"introduction_type"`
+	PatchType        PatchType                                  `json:"patch_type"`
+	Patches          map[string]VulnerabilityOccurencePatchInfo `json:"patches"`
+}
+
 type AnalysisInfo struct {
 	Status                   codeclarity.AnalysisStatus `json:"status"`
 	PrivateErrors            []exceptions.PrivateError  `json:"private_errors"`
@@ -42,6 +108,41 @@ type AnalysisInfo struct {
 	ImportPathSeperator      string                     `json:"import_path_seperator"`
 	DefaultWorkspaceName     string                     `json:"default_workspace_name"`
 	SelfManagedWorkspaceName string                     `json:"self_managed_workspace_name"`
+	// Policy is the upgrade policy the analysis ran with
+	Policy *UpgradePolicy `json:"policy,omitempty"`
+	// Errors are the errors and warnings of the analysis with their context,
+	// PublicErrors and PrivateErrors being their descriptions
+	Errors []AnalysisError `json:"errors,omitempty"`
+	// PluginVersion is the version of the plugin that produced the output
+	PluginVersion string `json:"plugin_version,omitempty"`
+	// PreviousResult is the result an incremental analysis carried unchanged entries forward from
+	PreviousResult string `json:"previous_result,omitempty"`
+	// CacheKey addresses the inputs of the analysis, outputs are only reused for the same key
+	CacheKey string `json:"cache_key,omitempty"`
+	// CacheHit is set when the output was reused from CachedResult, an earlier result with the same CacheKey
+	CacheHit     bool   `json:"cache_hit,omitempty"`
+	CachedResult string `json:"cached_result,omitempty"`
+	// ExpiredSuppressions are the suppressions that no longer apply, their vulnerabilities are reported again
+	ExpiredSuppressions []Suppression `json:"expired_suppressions,omitempty"`
+}
+
+type ErrorSeverity string
+
+const (
+	SEVERITY_ERROR   ErrorSeverity = "ERROR"
+	SEVERITY_WARNING ErrorSeverity = "WARNING"
+)
+
+// AnalysisError is an error met during an analysis.
+// Description is shown to users, Detail is kept for maintainers.
+type AnalysisError struct {
+	Code        exceptions.ERROR_TYPE `json:"code"`
+	Severity    ErrorSeverity         `json:"severity"`
+	Description string                `json:"description,omitempty"`
+	Detail      string                `json:"detail,omitempty"`
+	Workspace   string                `json:"workspace,omitempty"`
+	// Dependency is the dependency being processed, as name@version
+	Dependency string `json:"dependency,omitempty"`
 }
 
 type Upgrades struct {
@@ -52,30 +153,127 @@ type Upgrades struct {
 }
 
 type ToPatch struct {
-	DependencyName    string
-	DependencyVersion string
-	Path              []string
-	Vulnerability     vulnerabilityFinder.Vulnerability
+	DependencyName    string                            `json:"dependency_name"`
+	DependencyVersion string                            `json:"dependency_version"`
+	Path              []string                          `json:"path"`
+	Vulnerability     vulnerabilityFinder.Vulnerability `json:"vulnerability"`
 }
 
 type PatchInfo struct {
-	TopLevelVulnerable bool
-	IsPatchable        string
-	Unpatchable        []ToPatch
-	Patchable          []ToPatch
-	Introduced         []ToPatch
-	Patches            map[string]versions.Semver
-	Update             versions.Semver
+	TopLevelVulnerable bool                       `json:"top_level_vulnerable"`
+	IsPatchable        PatchType                  `json:"is_patchable"`
+	Unpatchable        []ToPatch                  `json:"unpatchable"`
+	Patchable          []ToPatch                  `json:"patchable"`
+	Introduced         []ToPatch                  `json:"introduced"`
+	Patches            map[string]versions.Semver `json:"patches"`
+	Update             versions.Semver            `json:"update"`
+	// Priority weighs the vulnerabilities fixed by their severity and exploitability
+	Priority        float64  `json:"priority"`
+	PriorityReasons []string `json:"priority_reasons"`
+	// Severities of the vulnerabilities of the dependency before and after the upgrade
+	SeverityDist             SeverityDist `json:"severity_dist"`
+	AfterUpgradeSeverityDist SeverityDist `json:"after_upgrade_severity_dist"`
+	// Fingerprint identifies what the entry was computed from, entries with the same fingerprint
+	// are carried forward by incremental analyses
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// Recomputed marks the entries an incremental analysis computed again
+	Recomputed bool `json:"recomputed,omitempty"`
+}
+
+// UpdateVersion returns the version the dependency is upgraded to,
+// or an empty string when no upgrade was found.
+func (patchInfo PatchInfo) UpdateVersion() string {
+	if patchInfo.IsPatchable != FULL && patchInfo.IsPatchable != PARTIAL {
+		return ""
+	}
+	if patchInfo.Update.Version != "" {
+		return patchInfo.Update.Version
+	}
+	return patchInfo.Update.String()
+}
+
+// UpgradeGroup is a set of direct dependency upgrades that must be applied together
+// to remove the vulnerabilities they share through a common transitive dependency
+type UpgradeGroup struct {
+	Upgrades        map[string]string `json:"upgrades"`
+	Vulnerabilities []string          `json:"vulnerabilities"`
+	Remaining       []string          `json:"remaining"`
+}
+
+// PlanBudget restricts the upgrades a remediation plan may contain
+type PlanBudget struct {
+	NoMajors    bool `json:"no_majors"`
+	MaxUpgrades int  `json:"max_upgrades"`
+}
+
+// PlannedUpgrade is a step of a remediation plan: a single upgrade
+// or a coordinated group of upgrades, with the figures it was ranked on
+type PlannedUpgrade struct {
+	Rank            int               `json:"rank"`
+	Dependencies    map[string]string `json:"dependencies"`
+	Dev             bool              `json:"dev"`
+	SeverityRemoved float64           `json:"severity_removed"`
+	// Priority is the severity removed, amplified by the exploitability of the vulnerabilities
+	Priority           float64 `json:"priority"`
+	SeverityIntroduced float64 `json:"severity_introduced"`
+	MajorJump          bool    `json:"major_jump"`
+	PotentialBreaking  bool    `json:"potential_breaking_changes"`
+	Cost               float64 `json:"cost"`
+	Score              float64 `json:"score"`
+}
+
+// RemediationPlan is the ranked list of upgrades chosen for a workspace under a budget
+type RemediationPlan struct {
+	Budget          PlanBudget       `json:"budget"`
+	Upgrades        []PlannedUpgrade `json:"upgrades"`
+	Excluded        []PlannedUpgrade `json:"excluded"`
+	SeverityRemoved float64          `json:"severity_removed"`
 }
 
 type Workspace struct {
-	Patches    map[string]PatchInfo `json:"patches"`
-	DevPatches map[string]PatchInfo `json:"dev_patches"`
+	Patches             map[string]PatchInfo `json:"patches"`
+	DevPatches          map[string]PatchInfo `json:"dev_patches"`
+	Upgrades            []Upgrades           `json:"upgrades"`
+	CoordinatedUpgrades []UpgradeGroup       `json:"coordinated_upgrades"`
+	Plan                RemediationPlan      `json:"plan"`
+	// Vulnerabilities is the same result keyed by vulnerability id
+	Vulnerabilities map[string]VulnerabilityPatchInfo `json:"vulnerabilities"`
+	// Severities of the distinct vulnerabilities of the workspace before and after the upgrades
+	SeverityDist             SeverityDist `json:"severity_dist"`
+	AfterUpgradeSeverityDist SeverityDist `json:"after_upgrade_severity_dist"`
+	// Blocked lists the recommendations held back by the policy rules of the project
+	Blocked []BlockedRecommendation `json:"blocked,omitempty"`
+	// Suppressed lists the vulnerabilities hidden by the suppressions of the policy
+	Suppressed []SuppressedVulnerability `json:"suppressed,omitempty"`
+}
+
+// AlignedUpgrade is a direct dependency shared by several workspaces of a monorepo
+// that is upgraded to the same version everywhere it can be
+type AlignedUpgrade struct {
+	Name       string             `json:"name"`
+	Version    string             `json:"version"`
+	Workspaces []string           `json:"workspaces"`
+	Dependents []string           `json:"dependents"`
+	Blocked    []BlockedWorkspace `json:"blocked"`
+}
+
+// BlockedWorkspace is a workspace that cannot follow an aligned upgrade
+type BlockedWorkspace struct {
+	Workspace  string `json:"workspace"`
+	Version    string `json:"version"`
+	Constraint string `json:"constraint"`
+	Reason     string `json:"reason"`
 }
 
+// Output is the result of the plugin, stored as described by schema/patching-output.schema.json.
+// SchemaVersion changes with every incompatible change of the format, see MigrateResult.
 type Output struct {
-	WorkSpaces   map[string]Workspace `json:"workspaces"`
-	AnalysisInfo AnalysisInfo         `json:"analysis_info"`
+	SchemaVersion            string               `json:"schema_version"`
+	WorkSpaces               map[string]Workspace `json:"workspaces"`
+	AlignedUpgrades          []AlignedUpgrade     `json:"aligned_upgrades"`
+	SeverityDist             SeverityDist         `json:"severity_dist"`
+	AfterUpgradeSeverityDist SeverityDist         `json:"after_upgrade_severity_dist"`
+	AnalysisInfo             AnalysisInfo         `json:"analysis_info"`
 }
 
 func ConvertOutputToMap(output Output) map[string]interface{} {
@@ -87,9 +285,25 @@ func ConvertOutputToMap(output Output) map[string]interface{} {
 		workspace := make(map[string]interface{})
 		workspace["patches"] = workspaceData.Patches
 		workspace["dev_patches"] = workspaceData.DevPatches
+		workspace["upgrades"] = workspaceData.Upgrades
+		workspace["coordinated_upgrades"] = workspaceData.CoordinatedUpgrades
+		workspace["plan"] = workspaceData.Plan
+		workspace["vulnerabilities"] = workspaceData.Vulnerabilities
+		workspace["severity_dist"] = workspaceData.SeverityDist
+		workspace["after_upgrade_severity_dist"] = workspaceData.AfterUpgradeSeverityDist
+		if len(workspaceData.Blocked) > 0 {
+			workspace["blocked"] = workspaceData.Blocked
+		}
+		if len(workspaceData.Suppressed) > 0 {
+			workspace["suppressed"] = workspaceData.Suppressed
+		}
 		workspaces[workspaceName] = workspace
 	}
 	result["workspaces"] = workspaces
+	result["schema_version"] = output.SchemaVersion
+	result["aligned_upgrades"] = output.AlignedUpgrades
+	result["severity_dist"] = output.SeverityDist
+	result["after_upgrade_severity_dist"] = output.AfterUpgradeSeverityDist
 
 	// Convert analysis info
 	result["analysis_info"] = output.AnalysisInfo
@@ -60,41 +60,33 @@ type UnPatchedVersionInternal struct {
 	UpgradedVersion          semverVersionTypes.Semver
 }
 
-type VersionSelectionPreference string
+// Ecosystems supported by the patcher, as passed in the languageId of Start
+const (
+	JS     = "JS"
+	PYTHON = "PYTHON"
+)
+
+// The upgrade policy is echoed in the output, so it lives in the patching package
+type VersionSelectionPreference = patching.VersionSelectionPreference
 
 const (
-	SELECT_NEWEST = "SELECT_NEWEST"
-	SELECT_OLDEST = "SELECT_OLDEST"
+	SELECT_NEWEST = patching.SELECT_NEWEST
+	SELECT_OLDEST = patching.SELECT_OLDEST
 )
 
-type PartialFixVersionSelection string
+type PartialFixVersionSelection = patching.PartialFixVersionSelection
 
 const (
-	SELECT_LOWEST_MAX_SEVERITY     = "SELECT_LOWEST_MAX_SEVERITY"
-	SELECT_LOWEST_AVERAGE_SEVERITY = "SELECT_LOWEST_AVERAGE_SEVERITY"
+	SELECT_LOWEST_MAX_SEVERITY     = patching.SELECT_LOWEST_MAX_SEVERITY
+	SELECT_LOWEST_AVERAGE_SEVERITY = patching.SELECT_LOWEST_AVERAGE_SEVERITY
 )
 
-type UpgradePolicy struct {
-	AllowDowngrades            bool
-	PartialFixVersionSelection PartialFixVersionSelection
-	VersionSelectionPreference VersionSelectionPreference
-}
+type UpgradePolicy = patching.UpgradePolicy
 
-type VulnerabilityOccurencePatchInfo struct {
-	PatchType                 patching.PatchType                `json:"patch_type"`
-	DirectDepInstalledVersion string                            `json:"direct_dep_installed_version"`
-	DirectDepUpgradeVersion   string                            `json:"direct_dep_upgrade_version"`
-	DirectDepName             string                            `json:"direct_dep_name"`
-	IntroducedOccurences      vulnerabilityFinder.Vulnerability `json:"introduced_occurences"`
-	UnPatchedOccurences       vulnerabilityFinder.Vulnerability `json:"unpatched_occurences"`
-	PatchedOccurences         vulnerabilityFinder.Vulnerability `json:"patched_occurences"`
-}
+// The vulnerability-centric view is part of the output, so it lives in the patching package
+type VulnerabilityOccurencePatchInfo = patching.VulnerabilityOccurencePatchInfo
 
-type VulnerabilityPatchInfo struct {
-	IntroductionType patching.IntroductionType                  `json:"introduction_type"`
-	PatchType        patching.PatchType                         `json:"patch_type"`
-	Patches          map[string]VulnerabilityOccurencePatchInfo `json:"potential_breaking_changes"`
-}
+type VulnerabilityPatchInfo = patching.VulnerabilityPatchInfo
 
 type UpgradeWorkSpaceData struct {
 	VulnerabilityPatchInfo    map[string]VulnerabilityPatchInfo
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	patching "github.com/CodeClarityCE/plugin-sca-patching/src"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/exceptionManager"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/knowledgeStore"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/policy"
+	patchingTypes "github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// update rewrites the expected outputs instead of comparing against them:
+// go test ./tests -run TestGolden -update
+var update = flag.Bool("update", false, "rewrite the expected outputs of the golden tests")
+
+// Each fixture holds the outputs of js-sbom and vuln-finder for one lock file format,
+// the knowledge snapshot the analysis needs, the expected patching output,
+// and optionally remediation rules in .codeclarity/patching.yaml.
+var goldenFixtures = []string{"npmv1", "npmv2", "yarnv1", "yarnv2", "yarnv3", "yarnv4", "pnpm"}
+
+// goldenStart is the start time of the golden analyses, so that suppressions expire reproducibly
+var goldenStart = time.Date(2026, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+func TestGolden(t *testing.T) {
+	for _, fixture := range goldenFixtures {
+		t.Run(fixture, func(t *testing.T) {
+			folder := filepath.Join("fixtures", fixture)
+			sbom, err := getSBOM(folder)
+			require.NoError(t, err)
+			vulns, err := getVulns(folder)
+			require.NoError(t, err)
+			store, err := knowledgeStore.LoadSnapshot(filepath.Join(folder, "knowledge.json"))
+			require.NoError(t, err)
+			upgradePolicy := policy.Default()
+			upgradePolicy.Rules, err = policy.LoadProjectRules(folder)
+			require.NoError(t, err)
+
+			actual, err := goldenOutput(patching.Start(context.Background(), store, sbom, vulns, "JS", upgradePolicy, nil, exceptionManager.NewCollector(), goldenStart))
+			require.NoError(t, err)
+
+			expectedPath := filepath.Join(folder, "expected.json")
+			if *update {
+				require.NoError(t, os.WriteFile(expectedPath, actual, 0644))
+				return
+			}
+			expected, err := os.ReadFile(expectedPath)
+			require.NoError(t, err, "run the golden tests with -update to create the expected output")
+			assert.Equal(t, string(expected), string(actual))
+		})
+	}
+}
+
+// goldenOutput serializes an output without its timings, which change on every run
+func goldenOutput(output patchingTypes.Output) ([]byte, error) {
+	output.AnalysisInfo.AnalysisStartTime = ""
+	output.AnalysisInfo.AnalysisEndTime = ""
+	output.AnalysisInfo.AnalysisDeltaTime = 0
+	content, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(content, '\n'), nil
+}
+
+// TestIncremental runs an analysis again on top of its own result, then after changes of the vulnerabilities
+// and of the release data: only the entries whose inputs changed are computed again.
+func TestIncremental(t *testing.T) {
+	folder := filepath.Join("fixtures", "pnpm")
+	sbom, err := getSBOM(folder)
+	require.NoError(t, err)
+	vulns, err := getVulns(folder)
+	require.NoError(t, err)
+	store, err := knowledgeStore.LoadSnapshot(filepath.Join(folder, "knowledge.json"))
+	require.NoError(t, err)
+
+	first := patching.Start(context.Background(), store, sbom, vulns, "JS", policy.Default(), nil, exceptionManager.NewCollector(), goldenStart)
+	second := patching.Start(context.Background(), store, sbom, vulns, "JS", policy.Default(), &first, exceptionManager.NewCollector(), goldenStart)
+	for dependency, patch := range second.WorkSpaces["."].Patches {
+		assert.False(t, patch.Recomputed, dependency)
+		assert.Equal(t, first.WorkSpaces["."].Patches[dependency], patch, dependency)
+	}
+
+	// A new severity score changes the inputs of nth-check only
+	vulnerabilities := vulns.WorkSpaces["."].Vulnerabilities
+	for i := range vulnerabilities {
+		if vulnerabilities[i].AffectedDependency == "nth-check" {
+			vulnerabilities[i].Severity.Severity = 8.1
+		}
+	}
+	third := patching.Start(context.Background(), store, sbom, vulns, "JS", policy.Default(), &second, exceptionManager.NewCollector(), goldenStart)
+	assert.True(t, third.WorkSpaces["."].Patches["nth-check@1.0.2"].Recomputed)
+	assert.False(t, third.WorkSpaces["."].Patches["minimist@1.2.5"].Recomputed)
+	assert.NotEqual(t, second.WorkSpaces["."].Patches["nth-check@1.0.2"].Fingerprint, third.WorkSpaces["."].Patches["nth-check@1.0.2"].Fingerprint)
+
+	// Releases of unrelated packages keep every entry
+	store.Releases["npm:left-pad@1.3.0"] = knowledgeStore.SnapshotRelease{}
+	fourth := patching.Start(context.Background(), store, sbom, vulns, "JS", policy.Default(), &third, exceptionManager.NewCollector(), goldenStart)
+	for dependency, patch := range fourth.WorkSpaces["."].Patches {
+		assert.False(t, patch.Recomputed, dependency)
+	}
+
+	// A change of the release data of a candidate changes the transitive dependencies it resolves to
+	release := store.Releases["npm:minimist@1.2.6"]
+	release.Dependencies = maps.Clone(release.Dependencies)
+	if release.Dependencies == nil {
+		release.Dependencies = map[string]string{}
+	}
+	release.Dependencies["left-pad"] = "^1.3.0"
+	store.Releases["npm:minimist@1.2.6"] = release
+	fifth := patching.Start(context.Background(), store, sbom, vulns, "JS", policy.Default(), &fourth, exceptionManager.NewCollector(), goldenStart)
+	assert.True(t, fifth.WorkSpaces["."].Patches["minimist@1.2.5"].Recomputed)
+	assert.False(t, fifth.WorkSpaces["."].Patches["nth-check@1.0.2"].Recomputed)
+}
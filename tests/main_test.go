@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
 	"os"
 	"testing"
 	"time"
 
 	patching "github.com/CodeClarityCE/plugin-sca-patching/src"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/exceptionManager"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/knowledgeStore"
+	"github.com/CodeClarityCE/plugin-sca-patching/src/policy"
 	"github.com/CodeClarityCE/utility-boilerplates"
 	codeclarity "github.com/CodeClarityCE/utility-types/codeclarity_db"
 	"github.com/stretchr/testify/assert"
@@ -40,17 +44,17 @@ func TestCreateNPMv1(t *testing.T) {
 	}
 	defer cleanup()
 
-	sbom, err := getSBOM("../../js-sbom/tests/npmv1")
+	sbom, err := getSBOM("fixtures/npmv1")
 	if err != nil {
 		t.Errorf("Error getting mock SBOM: %v", err)
 	}
 
-	vulns, err := getVulns("../../js-sbom/tests/npmv1")
+	vulns, err := getVulns("fixtures/npmv1")
 	if err != nil {
 		t.Errorf("Error getting mock SBOM: %v", err)
 	}
 
-	out := patching.Start(pluginBase.DB.Knowledge, sbom, vulns, "JS", time.Now())
+	out := patching.Start(context.Background(), knowledgeStore.Postgres{DB: pluginBase.DB.Knowledge}, sbom, vulns, "JS", policy.Default(), nil, exceptionManager.NewCollector(), time.Now())
 
 	// Assert the expected values
 	assert.NotNil(t, out)
@@ -65,17 +69,17 @@ func TestCreateNPMv2(t *testing.T) {
 	}
 	defer cleanup()
 
-	sbom, err := getSBOM("../../js-sbom/tests/npmv2")
+	sbom, err := getSBOM("fixtures/npmv2")
 	if err != nil {
 		t.Errorf("Error getting mock SBOM: %v", err)
 	}
 
-	vulns, err := getVulns("../../js-sbom/tests/npmv2")
+	vulns, err := getVulns("fixtures/npmv2")
 	if err != nil {
 		t.Errorf("Error getting mock SBOM: %v", err)
 	}
 
-	out := patching.Start(pluginBase.DB.Knowledge, sbom, vulns, "JS", time.Now())
+	out := patching.Start(context.Background(), knowledgeStore.Postgres{DB: pluginBase.DB.Knowledge}, sbom, vulns, "JS", policy.Default(), nil, exceptionManager.NewCollector(), time.Now())
 
 	// Assert the expected values
 	assert.NotNil(t, out)
@@ -90,17 +94,17 @@ func TestCreateYarnv1(t *testing.T) {
 	}
 	defer cleanup()
 
-	sbom, err := getSBOM("../../js-sbom/tests/yarnv1")
+	sbom, err := getSBOM("fixtures/yarnv1")
 	if err != nil {
 		t.Errorf("Error getting mock SBOM: %v", err)
 	}
 
-	vulns, err := getVulns("../../js-sbom/tests/yarnv1")
+	vulns, err := getVulns("fixtures/yarnv1")
 	if err != nil {
 		t.Errorf("Error getting mock SBOM: %v", err)
 	}
 
-	out := patching.Start(pluginBase.DB.Knowledge, sbom, vulns, "JS", time.Now())
+	out := patching.Start(context.Background(), knowledgeStore.Postgres{DB: pluginBase.DB.Knowledge}, sbom, vulns, "JS", policy.Default(), nil, exceptionManager.NewCollector(), time.Now())
 
 	// Assert the expected values
 	assert.NotNil(t, out)
@@ -115,17 +119,17 @@ func TestCreateYarnv2(t *testing.T) {
 	}
 	defer cleanup()
 
-	sbom, err := getSBOM("../../js-sbom/tests/yarnv2")
+	sbom, err := getSBOM("fixtures/yarnv2")
 	if err != nil {
 		t.Errorf("Error getting mock SBOM: %v", err)
 	}
 
-	vulns, err := getVulns("../../js-sbom/tests/yarnv2")
+	vulns, err := getVulns("fixtures/yarnv2")
 	if err != nil {
 		t.Errorf("Error getting mock SBOM: %v", err)
 	}
 
-	out := patching.Start(pluginBase.DB.Knowledge, sbom, vulns, "JS", time.Now())
+	out := patching.Start(context.Background(), knowledgeStore.Postgres{DB: pluginBase.DB.Knowledge}, sbom, vulns, "JS", policy.Default(), nil, exceptionManager.NewCollector(), time.Now())
 
 	// Assert the expected values
 	assert.NotNil(t, out)
@@ -140,17 +144,17 @@ func TestCreateYarnv3(t *testing.T) {
 	}
 	defer cleanup()
 
-	sbom, err := getSBOM("../../js-sbom/tests/yarnv3")
+	sbom, err := getSBOM("fixtures/yarnv3")
 	if err != nil {
 		t.Errorf("Error getting mock SBOM: %v", err)
 	}
 
-	vulns, err := getVulns("../../js-sbom/tests/yarnv3")
+	vulns, err := getVulns("fixtures/yarnv3")
 	if err != nil {
 		t.Errorf("Error getting mock SBOM: %v", err)
 	}
 
-	out := patching.Start(pluginBase.DB.Knowledge, sbom, vulns, "JS", time.Now())
+	out := patching.Start(context.Background(), knowledgeStore.Postgres{DB: pluginBase.DB.Knowledge}, sbom, vulns, "JS", policy.Default(), nil, exceptionManager.NewCollector(), time.Now())
 
 	// Assert the expected values
 	assert.NotNil(t, out)
@@ -165,17 +169,17 @@ func TestCreateYarnv4(t *testing.T) {
 	}
 	defer cleanup()
 
-	sbom, err := getSBOM("../../js-sbom/tests/yarnv3")
+	sbom, err := getSBOM("fixtures/yarnv4")
 	if err != nil {
 		t.Errorf("Error getting mock SBOM: %v", err)
 	}
 
-	vulns, err := getVulns("../../js-sbom/tests/yarnv3")
+	vulns, err := getVulns("fixtures/yarnv4")
 	if err != nil {
 		t.Errorf("Error getting mock SBOM: %v", err)
 	}
 
-	out := patching.Start(pluginBase.DB.Knowledge, sbom, vulns, "JS", time.Now())
+	out := patching.Start(context.Background(), knowledgeStore.Postgres{DB: pluginBase.DB.Knowledge}, sbom, vulns, "JS", policy.Default(), nil, exceptionManager.NewCollector(), time.Now())
 
 	// Assert the expected values
 	assert.NotNil(t, out)
@@ -198,7 +202,7 @@ func TestCreateYarnv4(t *testing.T) {
 // 		b.Errorf("Error getting mock SBOM: %v", err)
 // 	}
 
-// 	out := patching.Start(db_knowledge, sbom, vulns, "JS", time.Now())
+// 	out := patching.Start(context.Background(), db_knowledge, sbom, vulns, "JS", policy.Default(), nil, exceptionManager.NewCollector(), time.Now())
 
 // 	if out.AnalysisInfo.Status != "success" {
 // 		b.Errorf("Expected success, got %v", out.AnalysisInfo.Status)
@@ -0,0 +1,25 @@
+// Command schemagen writes the JSON Schema of the patching output.
+//
+//	go generate ./src/types/patching
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/CodeClarityCE/plugin-sca-patching/src/types/patching"
+)
+
+func main() {
+	output := flag.String("o", "schema/patching-output.schema.json", "file to write the schema to")
+	flag.Parse()
+
+	schema, err := patching.JSONSchema()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*output, schema, 0644); err != nil {
+		log.Fatal(err)
+	}
+}